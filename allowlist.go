@@ -0,0 +1,52 @@
+package main // Define the main package
+
+import (
+	"flag"    // For command-line flag parsing
+	"log"     // For logging refused links
+	"net/url" // For reading link hosts
+	"strings" // For matching host patterns
+)
+
+var (
+	allowedDomains string // Comma-separated hosts documents may be downloaded from
+	allowExternal  bool   // Download from any host
+)
+
+func init() {
+	flag.StringVar(&allowedDomains, "allowed-domains", "hillyard.com,*.hillyard.com", "comma-separated hosts documents may be downloaded from; *.example.com matches subdomains. The -origin-url host is always allowed") // Register the allowlist flag
+	flag.BoolVar(&allowExternal, "allow-external", false, "download documents linked from any host, ignoring -allowed-domains")                                                                                           // Register the opt-out flag
+}
+
+// hostAllowed reports whether a host matches the allowlist or the origin.
+func hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, ".")) // Hosts are case-insensitive
+	if origin, err := url.Parse(originURL); err == nil && strings.EqualFold(origin.Hostname(), host) {
+		return true // The site we search is always trusted
+	}
+	for _, pattern := range strings.Split(allowedDomains, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix, wildcard := strings.CutPrefix(pattern, "*."); wildcard {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if pattern != "" && host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// linkAllowed validates a document link before it is requested: it must be
+// an absolute http(s) URL on an allowed host unless -allow-external is set.
+func linkAllowed(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		log.Printf("refusing %s: not an absolute http(s) URL", link)
+		return false
+	}
+	if allowExternal || hostAllowed(parsed.Hostname()) {
+		return true
+	}
+	log.Printf("refusing %s: %s is not in -allowed-domains (use -allow-external to fetch it anyway)", link, parsed.Hostname())
+	return false
+}
@@ -0,0 +1,207 @@
+package main // Define the main package
+
+import (
+	"crypto/subtle" // For comparing bearer tokens
+	"encoding/json" // For request and response bodies
+	"flag"          // For command-line flag parsing
+	"log"           // For logging API crawls
+	"mime"          // For telling JSON bodies from forms
+	"net/http"      // For the handlers
+	"strconv"       // For crawl IDs
+	"strings"       // For filtering documents
+	"sync"          // For guarding the job list
+	"time"          // For job timestamps
+)
+
+// Modes of a crawl started through the API
+const (
+	crawlModeFull     = "full"     // Search and download, like run
+	crawlModeDiscover = "discover" // Search only, like discover
+)
+
+// States of a crawl started through the API
+const (
+	crawlStatusRunning  = "running"  // Still searching or downloading
+	crawlStatusFinished = "finished" // Done; see the report for how it went
+)
+
+var apiTokenFile string // File holding the bearer token that authorizes the API
+
+func init() {
+	flag.StringVar(&apiTokenFile, "api-token-file", "", "in serve mode, enable the POST /crawls, GET /crawls/{id} and GET /documents API for clients presenting the bearer token stored in this file") // Register the API token flag
+}
+
+// crawlProgress counts what a crawl has done so far.
+type crawlProgress struct {
+	QueriesSearched int64 `json:"queries_searched"` // Search queries fetched
+	LinksDiscovered int64 `json:"links_discovered"` // Unique PDF links considered
+	Downloaded      int64 `json:"downloaded"`       // PDFs written to disk
+	Skipped         int64 `json:"skipped"`          // PDFs already present
+	Failures        int   `json:"failures"`         // Failures recorded
+}
+
+// crawlJob is one crawl started through the API.
+type crawlJob struct {
+	ID         string        `json:"id"`                    // Sequence number within this server process
+	Mode       string        `json:"mode"`                  // crawlModeFull or crawlModeDiscover
+	Status     string        `json:"status"`                // crawlStatusRunning or crawlStatusFinished
+	StartedAt  time.Time     `json:"started_at"`            // When the crawl started
+	FinishedAt *time.Time    `json:"finished_at,omitempty"` // When it finished
+	Progress   crawlProgress `json:"progress"`              // Live while running, final afterwards
+	ExitCode   *int          `json:"exit_code,omitempty"`   // Exit code the run command would have returned
+	Report     *runReport    `json:"report,omitempty"`      // Full run report once finished
+}
+
+// crawlJobs holds the crawls of a server. Only one runs at a time, as
+// crawls share the run counters; the list lives as long as the process.
+type crawlJobs struct {
+	mutex   sync.Mutex  // Guards jobs and every job in it
+	jobs    []*crawlJob // In start order
+	running *crawlJob   // Crawl in progress, nil when idle
+}
+
+// registerAPI adds the crawl and document endpoints.
+func (server *libraryServer) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("POST /crawls", server.apiOnly(server.startCrawl))
+	mux.HandleFunc("GET /crawls", server.apiOnly(server.listCrawls))
+	mux.HandleFunc("GET /crawls/{id}", server.apiOnly(server.showCrawl))
+	mux.HandleFunc("GET /documents", server.apiOnly(server.listDocuments))
+}
+
+// apiOnly rejects requests that do not present the API token.
+func (server *libraryServer) apiOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		presented := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ") // Token sent by the client
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(server.apiToken)) != 1 {
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+// writeAPIJSON answers with value as JSON.
+func writeAPIJSON(writer http.ResponseWriter, status int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(value)
+}
+
+// startCrawl handles POST /crawls with a "mode" of full (default) or
+// discover, as a form field or in a JSON body. It answers 202 with the new
+// crawl, or 409 with the running one.
+func (server *libraryServer) startCrawl(writer http.ResponseWriter, request *http.Request) {
+	mode := request.FormValue("mode")
+	if contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); contentType == "application/json" {
+		var body struct {
+			Mode string `json:"mode"` // full or discover
+		}
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			http.Error(writer, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		mode = body.Mode
+	}
+	if mode == "" {
+		mode = crawlModeFull
+	}
+	if mode != crawlModeFull && mode != crawlModeDiscover {
+		http.Error(writer, "mode must be full or discover", http.StatusBadRequest)
+		return
+	}
+	server.crawlJobs.mutex.Lock()
+	defer server.crawlJobs.mutex.Unlock()
+	if running := server.crawlJobs.running; running != nil {
+		writeAPIJSON(writer, http.StatusConflict, server.crawlJobs.snapshot(running))
+		return
+	}
+	if !lockPass("serve") { // Released by runCrawlJob
+		http.Error(writer, "another instance is writing the data folders; try again later", http.StatusConflict)
+		return
+	}
+	job := &crawlJob{ID: strconv.Itoa(len(server.crawlJobs.jobs) + 1), Mode: mode, Status: crawlStatusRunning, StartedAt: time.Now().UTC()}
+	server.crawlJobs.jobs = append(server.crawlJobs.jobs, job)
+	server.crawlJobs.running = job
+	resetRunState() // Counters and budgets are per crawl, as in daemon mode
+	go server.runCrawlJob(job)
+	writer.Header().Set("Location", "/crawls/"+job.ID)
+	writeAPIJSON(writer, http.StatusAccepted, server.crawlJobs.snapshot(job))
+}
+
+// runCrawlJob crawls into the manifest the server is serving and files
+// the report with the job.
+func (server *libraryServer) runCrawlJob(job *crawlJob) {
+	log.Printf("api: crawl %s (%s) started", job.ID, job.Mode)
+	if job.Mode == crawlModeDiscover {
+		discover(pendingTargets(currentQueue(), queueKindQuery))
+	} else {
+		crawlLibrary()
+	}
+	report := finishRun()
+	unlockPass()
+	finishedAt, code := time.Now().UTC(), report.exitCode()
+	server.crawlJobs.mutex.Lock()
+	job.Status, job.FinishedAt, job.ExitCode, job.Report = crawlStatusFinished, &finishedAt, &code, &report
+	job.Progress = crawlProgress{QueriesSearched: report.QueriesSearched, LinksDiscovered: report.LinksDiscovered, Downloaded: report.Downloaded, Skipped: report.Skipped, Failures: len(report.Failures)}
+	server.crawlJobs.running = nil
+	server.crawlJobs.mutex.Unlock()
+	log.Printf("api: crawl %s finished with status %d", job.ID, code)
+}
+
+// snapshot copies a job, with the live counters while it runs; the caller
+// holds the mutex.
+func (jobs *crawlJobs) snapshot(job *crawlJob) crawlJob {
+	copied := *job
+	if job.Status == crawlStatusRunning {
+		failuresMutex.Lock()
+		recorded := len(failures)
+		failuresMutex.Unlock()
+		copied.Progress = crawlProgress{QueriesSearched: queriesSearched.Load(), LinksDiscovered: linksDiscovered.Load(), Downloaded: documentsSaved.Load(), Skipped: documentsSkipped.Load(), Failures: recorded}
+	}
+	return copied
+}
+
+// listCrawls handles GET /crawls, newest first, without the full reports.
+func (server *libraryServer) listCrawls(writer http.ResponseWriter, request *http.Request) {
+	server.crawlJobs.mutex.Lock()
+	defer server.crawlJobs.mutex.Unlock()
+	list := []crawlJob{}
+	for index := len(server.crawlJobs.jobs) - 1; index >= 0; index-- {
+		job := server.crawlJobs.snapshot(server.crawlJobs.jobs[index])
+		job.Report = nil // Fetch one crawl for its report
+		list = append(list, job)
+	}
+	writeAPIJSON(writer, http.StatusOK, list)
+}
+
+// showCrawl handles GET /crawls/{id}, for polling progress.
+func (server *libraryServer) showCrawl(writer http.ResponseWriter, request *http.Request) {
+	server.crawlJobs.mutex.Lock()
+	defer server.crawlJobs.mutex.Unlock()
+	for _, job := range server.crawlJobs.jobs {
+		if job.ID == request.PathValue("id") {
+			writeAPIJSON(writer, http.StatusOK, server.crawlJobs.snapshot(job))
+			return
+		}
+	}
+	http.NotFound(writer, request)
+}
+
+// listDocuments handles GET /documents: the current manifest entries,
+// optionally only those whose product name or file contains ?q= and, with
+// ?doc_type=, only one document class.
+func (server *libraryServer) listDocuments(writer http.ResponseWriter, request *http.Request) {
+	query := strings.ToLower(request.URL.Query().Get("q"))
+	docType := request.URL.Query().Get("doc_type")
+	entries := []manifestEntry{}
+	for _, entry := range documentManifest.currentEntries() {
+		if query != "" && !strings.Contains(strings.ToLower(entry.Title), query) && !strings.Contains(strings.ToLower(entry.File), query) {
+			continue
+		}
+		if docType != "" && entryDocType(entry).Name != docType {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	writeAPIJSON(writer, http.StatusOK, entries)
+}
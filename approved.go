@@ -0,0 +1,126 @@
+package main // Define the main package
+
+import (
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the drift
+	"log"            // For logging messages and errors
+	"os"             // For exit codes
+	"text/tabwriter" // For aligned console output
+)
+
+// Exit codes of the drift command, for cron jobs and CI gates
+const (
+	driftNone  = 0 // The mirror matches the approved manifest
+	driftFound = 1 // Documents were added, removed or changed
+	driftUsage = 2 // Nothing is pinned yet
+)
+
+var approvedManifestFile string // Pinned manifest of the documents change control approved
+
+func init() {
+	flag.StringVar(&approvedManifestFile, "approved-manifest", "approved-manifest.json", "manifest pinned by the approve command; runs report any drift from it") // Register the approved manifest flag
+}
+
+// driftReport lists how the live mirror differs from the approved manifest.
+type driftReport struct {
+	Added   []documentChange `json:"added"`   // In the mirror but never approved
+	Removed []documentChange `json:"removed"` // Approved but no longer in the mirror
+	Changed []documentChange `json:"changed"` // Approved, but the content is different now
+}
+
+// empty reports whether the mirror matches the approved manifest.
+func (drift driftReport) empty() bool {
+	return len(drift.Added) == 0 && len(drift.Removed) == 0 && len(drift.Changed) == 0
+}
+
+// compareWithApproved diffs live entries against approved ones by source URL.
+func compareWithApproved(live, approved []manifestEntry) driftReport {
+	drift := driftReport{Added: []documentChange{}, Removed: []documentChange{}, Changed: []documentChange{}}
+	approvedByURL := make(map[string]manifestEntry, len(approved))
+	for _, entry := range approved {
+		approvedByURL[entry.URL] = entry
+	}
+	liveURLs := make(map[string]bool, len(live))
+	for _, entry := range live {
+		liveURLs[entry.URL] = true
+		change := documentChange{Title: entry.Title, File: entry.File, URL: entry.URL}
+		pinned, known := approvedByURL[entry.URL]
+		switch {
+		case !known:
+			drift.Added = append(drift.Added, change)
+		case pinned.SHA256 != entry.SHA256:
+			drift.Changed = append(drift.Changed, change)
+		}
+	}
+	for _, entry := range approved {
+		if !liveURLs[entry.URL] {
+			drift.Removed = append(drift.Removed, documentChange{Title: entry.Title, File: entry.File, URL: entry.URL})
+		}
+	}
+	return drift
+}
+
+// currentDrift compares the loaded manifest with the approved one. It
+// returns nil when nothing is pinned, no manifest is loaded or nothing drifted.
+func currentDrift() *driftReport {
+	if documentManifest == nil || !fileExists(approvedManifestFile) { // Change control is opt-in
+		return nil
+	}
+	approved, err := loadManifest(approvedManifestFile)
+	if err != nil {
+		log.Printf("failed to read approved manifest %s: %v", approvedManifestFile, err)
+		return nil
+	}
+	drift := compareWithApproved(documentManifest.currentEntries(), approved.sortedEntries())
+	if drift.empty() {
+		return nil
+	}
+	log.Printf("library drifted from %s: %d added, %d removed, %d changed", approvedManifestFile, len(drift.Added), len(drift.Removed), len(drift.Changed))
+	return &drift
+}
+
+// runApproveCommand pins the current library as the approved manifest.
+func runApproveCommand() {
+	current := mustLoadManifest().currentEntries()
+	approved := &manifest{Entries: make(map[string]*manifestEntry, len(current))}
+	for index := range current {
+		approved.Entries[current[index].URL] = &current[index]
+	}
+	if err := approved.save(approvedManifestFile); err != nil {
+		fatalf("failed to write approved manifest %s: %v", approvedManifestFile, err)
+	}
+	log.Printf("approved %d documents in %s", len(current), approvedManifestFile)
+}
+
+// runDriftCommand prints the drift from the approved manifest and exits 1
+// when there is any.
+func runDriftCommand() {
+	if !fileExists(approvedManifestFile) {
+		log.Printf("no approved manifest at %s; pin one with the approve command", approvedManifestFile)
+		exit(driftUsage)
+	}
+	documentManifest = mustLoadManifest()
+	drift := currentDrift()
+	if drift == nil {
+		drift = &driftReport{Added: []documentChange{}, Removed: []documentChange{}, Changed: []documentChange{}}
+	}
+	if jsonOutput() {
+		printJSON(drift)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "DRIFT\tFILE\tTITLE\tURL")
+		for _, section := range []struct {
+			kind    string
+			changes []documentChange
+		}{{"added", drift.Added}, {"removed", drift.Removed}, {"changed", drift.Changed}} {
+			for _, change := range section.changes {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", section.kind, change.File, change.Title, change.URL)
+			}
+		}
+		writer.Flush() // Print the table
+	}
+	if !drift.empty() {
+		exit(driftFound)
+	}
+	exit(driftNone)
+}
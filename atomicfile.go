@@ -0,0 +1,219 @@
+package main // Define the main package
+
+import (
+	"bufio"         // For batching small writes
+	"flag"          // For command-line flag parsing
+	"fmt"           // For building error messages
+	"io"            // For copying data into the temporary file
+	"log"           // For reporting deferred sync errors
+	"os"            // For file operations
+	"path/filepath" // For splitting the destination path
+	"sync"          // For collecting deferred syncs across workers
+)
+
+// When written files are flushed to disk
+const (
+	fsyncAlways = "always" // Before every rename into place
+	fsyncEnd    = "end"    // Once, at the end of the run
+	fsyncNever  = "never"  // Left to the operating system
+)
+
+var (
+	fsyncPolicy       = fsyncAlways // When files are flushed to disk
+	writeBufferSize   int           // Bytes gathered before each write (0 = write as data arrives)
+	stagingDir        string        // Local folder files are written in before moving to their destination, "" for none
+	deferredSyncs     []string      // Files written this run that -fsync end still has to flush
+	deferredSyncMutex sync.Mutex    // Guards deferredSyncs
+)
+
+func init() {
+	flag.Func("fsync", "when written files are flushed to disk: always (before each rename), end (once at the end of the run) or never; end and never are faster on network shares but a crash may leave empty or partial files (default always)", func(value string) error {
+		if value != fsyncAlways && value != fsyncEnd && value != fsyncNever { // Register the fsync flag
+			return fmt.Errorf("unknown fsync policy %q (want always, end or never)", value)
+		}
+		fsyncPolicy = value
+		return nil
+	})
+	flag.Func("write-buffer", "gather writes into blocks of this size before they reach the disk, e.g. 4MiB, to spare network shares many small writes (default 0, unbuffered)", func(value string) (err error) {
+		size, err := parseByteSize(value) // Register the write buffer flag
+		writeBufferSize = int(size)
+		return err
+	})
+	flag.StringVar(&stagingDir, "staging-dir", "", "local folder files are written in first and then moved to their destination, e.g. when the PDF folder is on a network share") // Register the staging folder flag
+}
+
+// pendingFile is a hidden temporary file next to its destination, or in
+// --staging-dir. Data is streamed into it and it only appears under the
+// destination name once commit succeeds, so readers never see a partial file.
+type pendingFile struct {
+	file     *os.File      // Temporary file being written
+	buffer   *bufio.Writer // Gathers writes with --write-buffer, nil otherwise
+	target   string        // Final path
+	writeErr error         // First error returned by the disk, to tell it apart from read errors
+}
+
+// createPendingFile opens a temporary file in the directory of target, or
+// in --staging-dir.
+func createPendingFile(target string) (*pendingFile, error) {
+	directory := filepath.Dir(target) // Keep the temp file on the same filesystem, so the rename is atomic
+	if stagingDir != "" {
+		directory = stagingDir
+	}
+	file, err := createTempBeside(directory, filepath.Base(target))
+	if err != nil {
+		return nil, err
+	}
+	pending := &pendingFile{file: file, target: target}
+	if writeBufferSize > 0 {
+		pending.buffer = bufio.NewWriterSize(file, writeBufferSize)
+	}
+	return pending, nil
+}
+
+// createTempBeside creates a hidden, shareable temporary file for base in directory.
+func createTempBeside(directory, base string) (*os.File, error) {
+	file, err := os.CreateTemp(directory, "."+base+".*.tmp") // Create a hidden temporary file
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Chmod(0644); err != nil { // CreateTemp uses 0600; the library is shared, like files written with os.WriteFile
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// Write appends to the temporary file.
+func (pending *pendingFile) Write(data []byte) (int, error) {
+	var written int
+	var err error
+	if pending.buffer != nil {
+		written, err = pending.buffer.Write(data)
+	} else {
+		written, err = pending.file.Write(data)
+	}
+	if err != nil && pending.writeErr == nil {
+		pending.writeErr = err
+	}
+	return written, err
+}
+
+// commit flushes the temporary file to disk as --fsync says and renames it
+// into place; a staged file is copied next to its destination first.
+func (pending *pendingFile) commit() error {
+	var err error
+	if pending.buffer != nil {
+		err = pending.buffer.Flush() // The last partial block
+	}
+	if err == nil && fsyncPolicy == fsyncAlways && stagingDir == "" { // Flush the data to disk before renaming
+		err = pending.file.Sync()
+	}
+	if closeErr := pending.file.Close(); err == nil { // Close the file in every case
+		err = closeErr
+	}
+	if err == nil && stagingDir != "" {
+		err = moveStagedFile(pending.file.Name(), pending.target)
+	} else if err == nil {
+		err = os.Rename(pending.file.Name(), pending.target) // Atomically move the file into place
+	}
+	if err != nil {
+		os.Remove(pending.file.Name()) // Do not leave partial temp files behind
+		return err
+	}
+	if fsyncPolicy == fsyncEnd {
+		deferredSyncMutex.Lock()
+		deferredSyncs = append(deferredSyncs, pending.target)
+		deferredSyncMutex.Unlock()
+	}
+	return nil
+}
+
+// moveStagedFile moves a file from --staging-dir to its destination in one
+// sequential copy, through a temporary file beside the destination so the
+// rename there stays atomic.
+func moveStagedFile(staged, target string) error {
+	if err := os.Rename(staged, target); err == nil { // Same filesystem after all
+		if fsyncPolicy == fsyncAlways {
+			return syncFile(target)
+		}
+		return nil
+	}
+	source, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	destination, err := createTempBeside(filepath.Dir(target), filepath.Base(target))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destination, source)
+	if err == nil && fsyncPolicy == fsyncAlways {
+		err = destination.Sync()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(destination.Name(), target)
+	}
+	if err != nil {
+		os.Remove(destination.Name())
+		return err
+	}
+	return os.Remove(staged)
+}
+
+// discard closes and removes the temporary file.
+func (pending *pendingFile) discard() {
+	pending.file.Close()
+	os.Remove(pending.file.Name())
+}
+
+// syncDeferredFiles flushes the files written this run with --fsync end.
+func syncDeferredFiles() {
+	deferredSyncMutex.Lock()
+	files := deferredSyncs
+	deferredSyncs = nil
+	deferredSyncMutex.Unlock()
+	for _, path := range files {
+		if err := syncFile(path); err != nil && !os.IsNotExist(err) { // Removed since, e.g. by pruning
+			log.Printf("failed to flush %s to disk: %v", path, err)
+		}
+	}
+}
+
+// syncFile flushes a written file to disk.
+func syncFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// writeFileAtomically copies reader into a temporary file in the same
+// directory as filePath and renames it into place only after the full
+// expectedSize bytes have been written and flushed to disk (a negative
+// expectedSize accepts any length, for streamed content). A crash part way
+// through therefore never leaves a truncated file at filePath.
+func writeFileAtomically(filePath string, reader io.Reader, expectedSize int64) (int64, error) {
+	pending, err := createPendingFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(pending, reader)                        // Write the data
+	if err == nil && expectedSize >= 0 && written != expectedSize { // Verify the full payload landed
+		err = fmt.Errorf("short write: wrote %d of %d bytes", written, expectedSize)
+	}
+	if err != nil {
+		pending.discard()
+		return 0, err
+	}
+	if err := pending.commit(); err != nil {
+		return 0, err
+	}
+	return written, nil // Report the number of bytes stored
+}
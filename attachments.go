@@ -0,0 +1,178 @@
+package main // Define the main package
+
+import (
+	"crypto/sha256"  // For hashing attached files
+	"encoding/hex"   // For encoding hashes
+	"encoding/json"  // For the attachment registry
+	"errors"         // For registry errors
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing attachments
+	"io"             // For copying attached files
+	"log"            // For logging messages and errors
+	"os"             // For file operations
+	"path/filepath"  // For building local paths
+	"strings"        // For matching product names
+	"sync"           // For guarding the registry
+	"text/tabwriter" // For aligned console output
+	"time"           // For attachment timestamps
+)
+
+// localAttachmentSource marks documents that did not come from the vendor.
+const localAttachmentSource = "local"
+
+var (
+	localDir         string     // Folder holding locally sourced documents
+	attachmentsMutex sync.Mutex // Serializes registry updates
+)
+
+func init() {
+	flag.StringVar(&localDir, "local-dir", "local", "folder for locally sourced supplemental documents (never touched by upstream sync)") // Register the local folder flag
+}
+
+// localAttachment is a locally sourced document attached to a product, such
+// as an internal risk assessment or a translated summary. Attachments live in
+// their own folder, outside PDFs/, so upstream sync, pruning and vacuuming
+// never see them.
+type localAttachment struct {
+	Product      string    `json:"product"`       // Product the document belongs to
+	File         string    `json:"file"`          // Filename inside localDir
+	OriginalName string    `json:"original_name"` // Name of the file that was attached
+	Description  string    `json:"description"`   // Free-text description
+	SHA256       string    `json:"sha256"`        // Hash of the stored file
+	Size         int64     `json:"size"`          // Size of the stored file
+	Source       string    `json:"source"`        // Always localAttachmentSource
+	AddedAt      time.Time `json:"added_at"`      // When the document was attached
+}
+
+// Path of the attachment registry
+func attachmentsRegistryPath() string {
+	return filepath.Join(localDir, "attachments.json") // Registry lives with the files
+}
+
+// loadLocalAttachments reads the attachment registry.
+func loadLocalAttachments() ([]localAttachment, error) {
+	var attachments []localAttachment                      // Registered attachments
+	content, err := os.ReadFile(attachmentsRegistryPath()) // Read the registry
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil // Nothing attached yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &attachments); err != nil {
+		return nil, fmt.Errorf("invalid attachment registry: %w", err)
+	}
+	return attachments, nil
+}
+
+// attachLocalDocument copies sourcePath into the local folder and registers
+// it against product.
+func attachLocalDocument(product, sourcePath, description string) (localAttachment, error) {
+	attachmentsMutex.Lock()
+	defer attachmentsMutex.Unlock()
+	attachments, err := loadLocalAttachments() // Existing registry
+	if err != nil {
+		return localAttachment{}, err
+	}
+	source, err := os.Open(sourcePath) // File to attach
+	if err != nil {
+		return localAttachment{}, err
+	}
+	defer source.Close()
+	info, err := source.Stat() // Size for the atomic write check
+	if err != nil {
+		return localAttachment{}, err
+	}
+	createDirectory(localDir, 0755) // Make sure the folder exists
+	originalName := filepath.Base(sourcePath)
+	filename := sanitizeFilename(product) + "--" + sanitizeFilename(originalName) // Keep the product visible in the name
+	hash := sha256.New()                                                          // Hash while copying
+	written, err := writeFileAtomically(filepath.Join(localDir, filename), io.TeeReader(source, hash), info.Size())
+	if err != nil {
+		return localAttachment{}, err
+	}
+	attachment := localAttachment{
+		Product:      product,
+		File:         filename,
+		OriginalName: originalName,
+		Description:  description,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		Size:         written,
+		Source:       localAttachmentSource,
+		AddedAt:      time.Now().UTC(),
+	}
+	replaced := false // Re-attaching the same file updates its entry
+	for index := range attachments {
+		if attachments[index].File == filename {
+			attachments[index] = attachment
+			replaced = true
+		}
+	}
+	if !replaced {
+		attachments = append(attachments, attachment)
+	}
+	content, err := json.MarshalIndent(attachments, "", "  ") // Encode the registry
+	if err != nil {
+		return localAttachment{}, err
+	}
+	if err := os.WriteFile(attachmentsRegistryPath(), append(content, '\n'), 0644); err != nil {
+		return localAttachment{}, err
+	}
+	return attachment, nil
+}
+
+// localAttachmentsFor returns the attachments registered for a product
+// (case-insensitive); an empty product returns every attachment.
+func localAttachmentsFor(product string) ([]localAttachment, error) {
+	attachments, err := loadLocalAttachments() // Full registry
+	if err != nil || product == "" {
+		return attachments, err
+	}
+	var matching []localAttachment // Attachments for the product
+	for _, attachment := range attachments {
+		if strings.EqualFold(attachment.Product, product) {
+			matching = append(matching, attachment)
+		}
+	}
+	return matching, nil
+}
+
+// runAttachCommand implements `attach add <product> <file> [description]`
+// and `attach list [product]`.
+func runAttachCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: attach add <product> <file> [description] | attach list [product]")
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			fatalln("usage: attach add <product> <file> [description]")
+		}
+		attachment, err := attachLocalDocument(args[1], args[2], strings.Join(args[3:], " "))
+		if err != nil {
+			fatalln(err)
+		}
+		log.Printf("attached %s to %q as %s", attachment.OriginalName, attachment.Product, filepath.Join(localDir, attachment.File))
+	case "list":
+		product := "" // List everything by default
+		if len(args) > 1 {
+			product = args[1]
+		}
+		attachments, err := localAttachmentsFor(product)
+		if err != nil {
+			fatalln(err)
+		}
+		if jsonOutput() {
+			printJSON(attachments)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "PRODUCT\tFILE\tSIZE\tADDED\tDESCRIPTION")
+		for _, attachment := range attachments {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", attachment.Product, attachment.File, attachment.Size, attachment.AddedAt.Format(time.DateOnly), attachment.Description)
+		}
+		writer.Flush() // Print the table
+	default:
+		fatalf("unknown attach command %q", args[0])
+	}
+}
@@ -0,0 +1,206 @@
+package main // Define the main package
+
+import (
+	"encoding/csv"   // For the mapping table format
+	"encoding/json"  // For the lookup endpoint
+	"errors"         // For detecting a missing table
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing results
+	"log"            // For logging messages and errors
+	"net/http"       // For the lookup endpoint
+	"os"             // For file access
+	"sort"           // For ordering the table
+	"strings"        // For normalizing codes
+	"text/tabwriter" // For aligned console output
+)
+
+var barcodesFile string // CSV table mapping scanned UPC/item codes to product names
+
+func init() {
+	flag.StringVar(&barcodesFile, "barcodes-file", "barcodes.csv", "CSV table of barcode,product used to resolve scanned codes to SDS") // Register the barcode table flag
+}
+
+// barcodeMatch is the answer to one scanned code.
+type barcodeMatch struct {
+	Barcode   string          `json:"barcode"`   // Normalized code
+	Product   string          `json:"product"`   // Product the code maps to
+	Documents []manifestEntry `json:"documents"` // SDS of the product in the mirror
+}
+
+// normalizeBarcode keeps the digits and letters of a scanned code and drops
+// leading zeros, so a UPC-A and the same code read as EAN-13 match.
+func normalizeBarcode(code string) string {
+	var normalized strings.Builder
+	for _, character := range strings.ToUpper(code) {
+		if (character >= '0' && character <= '9') || (character >= 'A' && character <= 'Z') {
+			normalized.WriteRune(character) // Scanners and people add spaces and dashes
+		}
+	}
+	return strings.TrimLeft(normalized.String(), "0")
+}
+
+// loadBarcodes reads the mapping table. A missing table is an empty one.
+func loadBarcodes() (map[string]string, error) {
+	barcodes := make(map[string]string) // Normalized code → product
+	file, err := os.Open(barcodesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return barcodes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Tolerate extra columns kept by spreadsheets
+	reader.Comment = '#'        // Allow notes in the table
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if len(record) < 2 || strings.EqualFold(record[0], "barcode") { // Skip short rows and the header
+			continue
+		}
+		if code, product := normalizeBarcode(record[0]), strings.TrimSpace(record[1]); code != "" && product != "" {
+			barcodes[code] = product
+		}
+	}
+	return barcodes, nil
+}
+
+// saveBarcodes writes the mapping table sorted by code.
+func saveBarcodes(barcodes map[string]string) error {
+	codes := make([]string, 0, len(barcodes))
+	for code := range barcodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	var content strings.Builder
+	writer := csv.NewWriter(&content)
+	writer.Write([]string{"barcode", "product"}) // Header for spreadsheet users
+	for _, code := range codes {
+		writer.Write([]string{code, barcodes[code]})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	_, err := writeFileAtomically(barcodesFile, strings.NewReader(content.String()), int64(content.Len()))
+	return err
+}
+
+// lookupBarcode resolves a scanned code to its product and documents. The
+// product is "" when the code is not in the table.
+func lookupBarcode(code string, entries []manifestEntry) (barcodeMatch, error) {
+	match := barcodeMatch{Barcode: normalizeBarcode(code), Documents: []manifestEntry{}}
+	barcodes, err := loadBarcodes() // Re-read so edits apply without a restart
+	if err != nil {
+		return match, err
+	}
+	match.Product = barcodes[match.Barcode]
+	if match.Product != "" {
+		if documents := matchProductDocuments(entries, match.Product); documents != nil {
+			match.Documents = documents
+		}
+	}
+	return match, nil
+}
+
+// serveBarcode answers GET /barcode/{code} with the product and its SDS.
+// Unknown codes and products without an SDS answer 404; the latter are also
+// recorded as document requests.
+func (server *libraryServer) serveBarcode(writer http.ResponseWriter, request *http.Request) {
+	match, err := lookupBarcode(request.PathValue("code"), publishedEntries(documentManifest.currentEntries()))
+	if err != nil {
+		log.Printf("failed to read barcode table %s: %v", barcodesFile, err)
+		http.Error(writer, "barcode table unavailable", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if len(match.Documents) == 0 {
+		status = http.StatusNotFound
+		if match.Product != "" { // Known product with no SDS yet
+			recordDocumentRequest(match.Product, requestSourceLookup, "barcode "+match.Barcode)
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(match)
+}
+
+// runBarcodeCommand maintains the mapping table: "barcode add <code>
+// <product>", "barcode remove <code>", "barcode list" and "barcode lookup
+// <code>", which exits like the has command.
+func runBarcodeCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: barcode add <code> <product> | remove <code> | list | lookup <code>")
+	}
+	barcodes, err := loadBarcodes()
+	if err != nil {
+		fatalf("failed to read barcode table %s: %v", barcodesFile, err)
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 3 || normalizeBarcode(args[1]) == "" {
+			fatalln("usage: barcode add <code> <product>")
+		}
+		product := strings.Join(args[2:], " ") // Unquoted names still work
+		if len(findProductDocuments(product)) == 0 {
+			log.Printf("%q has no SDS in the mirror yet; adding the mapping anyway", product)
+		}
+		barcodes[normalizeBarcode(args[1])] = product
+		if err := saveBarcodes(barcodes); err != nil {
+			fatalln(err)
+		}
+	case "remove":
+		if len(args) < 2 {
+			fatalln("usage: barcode remove <code>")
+		}
+		delete(barcodes, normalizeBarcode(args[1]))
+		if err := saveBarcodes(barcodes); err != nil {
+			fatalln(err)
+		}
+	case "list":
+		codes := make([]string, 0, len(barcodes))
+		for code := range barcodes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		if jsonOutput() {
+			printJSON(barcodes)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "BARCODE\tPRODUCT")
+		for _, code := range codes {
+			fmt.Fprintf(writer, "%s\t%s\n", code, barcodes[code])
+		}
+		writer.Flush() // Print the table
+	case "lookup":
+		if len(args) < 2 {
+			log.Println("usage: barcode lookup <code>")
+			exit(lookupUsage)
+		}
+		match, err := lookupBarcode(args[1], mustLoadManifest().currentEntries())
+		if err != nil {
+			fatalln(err)
+		}
+		if jsonOutput() {
+			printJSON(match)
+		} else {
+			for _, entry := range match.Documents {
+				fmt.Println(entry.localPath())
+			}
+		}
+		if len(match.Documents) == 0 {
+			if match.Product == "" {
+				log.Printf("barcode %s is not in %s", match.Barcode, barcodesFile)
+			} else {
+				log.Printf("barcode %s is %q, which has no SDS in the mirror", match.Barcode, match.Product)
+			}
+			exit(lookupNotFound)
+		}
+	default:
+		fatalf("unknown barcode command %q", args[0])
+	}
+}
@@ -0,0 +1,156 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For printing the table
+	"log"            // For logging the outcome
+	"os"             // For file dates
+	"path/filepath"  // For building local paths
+	"sort"           // For a stable table order
+	"strings"        // For matching names regardless of case
+	"sync"           // For collecting results across workers
+	"text/tabwriter" // For aligning the table
+	"time"           // For download dates
+)
+
+// bootstrappedFile is what reindex manifest found out about one PDF.
+type bootstrappedFile struct {
+	File   string `json:"file"`          // Path inside the PDF folder, with forward slashes
+	Status string `json:"status"`        // "known", "matched", "ambiguous" or "unmatched"
+	URL    string `json:"url,omitempty"` // Catalog URL it was matched to
+}
+
+// catalogFilenames maps the lowercased filenames a crawl would store the
+// discovered links under to those links. Older runs lowercased every name,
+// so case is ignored. Names two links share map to "".
+func catalogFilenames() map[string]string {
+	names := make(map[string]string)
+	add := func(name, link string) {
+		if name == "" {
+			return
+		}
+		name = strings.ToLower(name)
+		if owner, taken := names[name]; taken && owner != link {
+			names[name] = "" // Ambiguous
+			return
+		}
+		names[name] = link
+	}
+	var links []string // Every link the saved search results and the URL list name
+	for _, target := range generateQueries() {
+		links = append(links, savedResultLinks(target)...)
+	}
+	if urlListFile != "" {
+		links = append(links, loadURLList()...)
+	}
+	for _, link := range removeDuplicatesFromSlice(links) {
+		final := link
+		if !hasPDFExtension(link) { // Handler links are named after the PDF they resolved to
+			if resolved, cached := lookupResolvedLink(link); cached && resolved != "" {
+				final = resolved
+			}
+		}
+		docType, title := linkDocType(link), linkTitle(link)
+		language := linkLanguage(link, title+" "+linkLabel(link))
+		for _, name := range []string{plainPDFFilename(final), titledFilename(title, docType.Name, language)} {
+			if name == "" {
+				continue
+			}
+			add(docType.inFolder(name), final)
+			add(disambiguatedFilename(docType.inFolder(name), final), final) // Stored after a collision
+		}
+	}
+	return names
+}
+
+// bootstrapManifest adds the PDFs on disk that the manifest does not list,
+// e.g. from runs made before it existed: every file is hashed on
+// --index-workers workers and matched by name to a link of the saved search
+// results. Unmatched files are reported and left out, so --prune does not
+// take them for documents the catalog dropped.
+func bootstrapManifest() []bootstrappedFile {
+	documentManifest = mustLoadManifest()
+	names := catalogFilenames()
+	files := libraryPDFs()
+	results := make([]bootstrappedFile, 0, len(files))
+	var resultsMutex sync.Mutex // Guards results across workers
+	report := func(result bootstrappedFile) {
+		resultsMutex.Lock()
+		results = append(results, result)
+		resultsMutex.Unlock()
+	}
+	runWorkerPool(files, indexWorkers, func(file string) {
+		if _, known := documentManifest.entryForFile(file); known {
+			report(bootstrappedFile{File: file, Status: "known"})
+			return
+		}
+		link, found := names[strings.ToLower(file)]
+		if !found {
+			report(bootstrappedFile{File: file, Status: "unmatched"})
+			return
+		}
+		if link == "" {
+			report(bootstrappedFile{File: file, Status: "ambiguous"})
+			return
+		}
+		if existing, known := documentManifest.lookup(link); known && existing.Pruned == "" && fileExists(existing.localPath()) { // Stored again under another name
+			report(bootstrappedFile{File: file, Status: "known", URL: link})
+			return
+		}
+		localPath := filepath.Join(outputDir, filepath.FromSlash(file))
+		hash, err := hashFile(localPath)
+		if err != nil {
+			log.Printf("failed to hash %s: %v", file, err)
+			return
+		}
+		info, err := os.Stat(localPath)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		title := linkTitle(link)
+		documentManifest.record(manifestEntry{
+			URL:          link,
+			Title:        title,
+			Category:     linkCategory(link),
+			Language:     linkLanguage(link, title+" "+linkLabel(link)),
+			File:         file,
+			DocType:      linkDocType(link).entryName(),
+			SHA256:       hash,
+			Size:         info.Size(),
+			RevisionDate: linkRevision(link),
+			DownloadedAt: info.ModTime().UTC().Truncate(time.Second), // The best guess at when it was stored
+		})
+		markSeen(link, file)
+		report(bootstrappedFile{File: file, Status: "matched", URL: link})
+	})
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results
+}
+
+// Rebuild the manifest from the PDFs on disk and print what became of them
+func runBootstrapManifestCommand() {
+	results := bootstrapManifest()
+	counts := make(map[string]int) // Status → files
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	if counts["matched"] > 0 {
+		if err := documentManifest.save(manifestFile); err != nil {
+			fatalf("failed to save manifest %s: %v", manifestFile, err)
+		}
+		saveSeenURLs()
+	}
+	log.Printf("reindex manifest: %d files, %d already known, %d matched to catalog links, %d ambiguous, %d unmatched", len(results), counts["known"], counts["matched"], counts["ambiguous"], counts["unmatched"])
+	if jsonOutput() {
+		printJSON(results)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "FILE\tSTATUS\tURL")
+	for _, result := range results {
+		if result.Status != "known" {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", result.File, result.Status, result.URL)
+		}
+	}
+	writer.Flush() // Print the table
+}
@@ -0,0 +1,78 @@
+package main // Define the main package
+
+import (
+	"flag"        // For command-line flag parsing
+	"io"          // For wrapping response bodies
+	"log"         // For logging messages and errors
+	"sync"        // For logging budget exhaustion only once
+	"sync/atomic" // For counters shared between workers
+)
+
+var (
+	requestBudget    int64        // Maximum number of requests sent to the origin per run (0 = unlimited)
+	byteBudget       int64        // Maximum number of response bytes read from the origin per run (0 = unlimited)
+	requestsUsed     atomic.Int64 // Number of requests sent so far
+	bytesUsed        atomic.Int64 // Number of response bytes read so far
+	deferredRequests atomic.Int64 // Number of requests skipped because the budget ran out
+	budgetExhaustion sync.Once    // Ensures the exhaustion message is logged once
+)
+
+func init() {
+	flag.Int64Var(&requestBudget, "request-budget", 0, "maximum number of requests to send to the origin in one run (0 = unlimited)") // Register the request budget flag
+	flag.Int64Var(&byteBudget, "byte-budget", 0, "maximum number of bytes to download from the origin in one run (0 = unlimited)")    // Register the byte budget flag
+}
+
+// budgetExhausted reports whether either per-run budget has been used up.
+func budgetExhausted() bool {
+	if stopRequested.Load() { // The daemon is stopping; the rest waits for its next start
+		return true
+	}
+	if requestBudget > 0 && requestsUsed.Load() >= requestBudget { // Request budget spent
+		return true
+	}
+	if byteBudget > 0 && bytesUsed.Load() >= byteBudget { // Byte budget spent
+		return true
+	}
+	return false // Both budgets still have room
+}
+
+// reserveRequest claims one request from the budget. It returns false when
+// the run is out of budget, in which case the caller must skip the request
+// and leave the work for the next run. A request that is already in flight
+// is always allowed to finish, so the byte budget may be overshot slightly.
+func reserveRequest() bool {
+	if budgetExhausted() { // Nothing left to spend
+		deferRequest() // Count the skipped request
+		return false
+	}
+	if requestsUsed.Add(1) > requestBudget && requestBudget > 0 { // Another worker took the last request
+		requestsUsed.Add(-1) // Give back the unused reservation
+		deferRequest()       // Count the skipped request
+		return false
+	}
+	return true // Budget reserved
+}
+
+// deferRequest records a skipped request and logs the exhaustion once.
+func deferRequest() {
+	deferredRequests.Add(1) // Count the skipped request
+	budgetExhaustion.Do(func() {
+		if stopRequested.Load() {
+			log.Printf("stopping after %d requests; remaining work is left for the next run", requestsUsed.Load())
+			return
+		}
+		log.Printf("run budget exhausted after %d requests and %d bytes; remaining work is left for the next run", requestsUsed.Load(), bytesUsed.Load())
+	})
+}
+
+// budgetReader counts the bytes read through it against the byte budget.
+type budgetReader struct {
+	reader io.Reader // Underlying response body
+}
+
+// Read reads from the underlying reader and charges the bytes to the budget.
+func (budget budgetReader) Read(buffer []byte) (int, error) {
+	readBytes, err := budget.reader.Read(buffer) // Read from the wrapped body
+	bytesUsed.Add(int64(readBytes))              // Charge the bytes to the run
+	return readBytes, err
+}
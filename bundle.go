@@ -0,0 +1,125 @@
+package main // Define the main package
+
+import (
+	"archive/tar"   // For .tar.gz bundles
+	"archive/zip"   // For .zip bundles
+	"compress/gzip" // For compressing tar bundles
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting unknown formats
+	"io"            // For streaming files into the bundle
+	"log"           // For logging messages and errors
+	"os"            // For reading the library
+	"path/filepath" // For naming bundle members
+	"strings"       // For matching extensions and placeholders
+	"time"          // For dated bundle names
+)
+
+var bundlePath string // Where the end-of-run bundle is written ("" = none)
+
+func init() {
+	flag.StringVar(&bundlePath, "archive", "", "at the end of a run, package the PDFs and manifest into this .zip or .tar.gz; YYYYMMDD is replaced with the date, e.g. sds-YYYYMMDD.zip") // Register the bundle flag
+}
+
+// writeRunBundle packages every current document and the manifest into
+// the --archive file, for handing the whole library to auditors.
+func writeRunBundle() {
+	if bundlePath == "" { // Bundles are opt-in
+		return
+	}
+	target := strings.ReplaceAll(bundlePath, "YYYYMMDD", time.Now().Format("20060102")) // Dated name
+	members := []string{manifestFile}                                                   // Manifest first, then the PDFs
+	for _, entry := range documentManifest.currentEntries() {
+		members = append(members, entry.localPath())
+	}
+	reader, writer := io.Pipe() // Stream the bundle straight into the atomic writer
+	defer reader.Close()        // Unblocks the encoder if writing fails early
+	go func() {
+		writer.CloseWithError(writeBundle(writer, target, members))
+	}()
+	written, err := writeFileAtomically(target, reader, -1) // Never leave a half-written bundle
+	if err != nil {
+		log.Printf("failed to write bundle %s: %v", target, err)
+		return
+	}
+	log.Printf("bundled %d documents and the manifest into %s (%d bytes)", len(members)-1, target, written)
+}
+
+// writeBundle writes the files to output in the format named by target's extension.
+func writeBundle(output io.Writer, target string, files []string) error {
+	lower := strings.ToLower(target)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		archive := zip.NewWriter(output)
+		for _, file := range files {
+			if err := addBundleMember(file, func(name string, info os.FileInfo) (io.Writer, error) {
+				header, err := zip.FileInfoHeader(info)
+				if err != nil {
+					return nil, err
+				}
+				header.Name, header.Method = name, zip.Deflate
+				return archive.CreateHeader(header)
+			}); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		compressed := gzip.NewWriter(output)
+		archive := tar.NewWriter(compressed)
+		for _, file := range files {
+			if err := addBundleMember(file, func(name string, info os.FileInfo) (io.Writer, error) {
+				header, err := tar.FileInfoHeader(info, "")
+				if err != nil {
+					return nil, err
+				}
+				header.Name = name
+				return archive, archive.WriteHeader(header)
+			}); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		return compressed.Close()
+	default:
+		return fmt.Errorf("unsupported bundle format %q (use .zip or .tar.gz)", target)
+	}
+}
+
+// libraryRelativePath returns the path of a document inside the PDF or
+// archive folder with forward slashes, or its base name when it is in neither.
+func libraryRelativePath(file string) string {
+	for _, folder := range []string{outputDir, archiveDir} {
+		if relative, err := filepath.Rel(folder, file); err == nil && !strings.HasPrefix(relative, "..") {
+			return filepath.ToSlash(relative)
+		}
+	}
+	return filepath.Base(file)
+}
+
+// addBundleMember copies one file into the bundle. The manifest sits at
+// the top level and PDFs under PDFs/, so the bundle unpacks into the same
+// layout as the mirror.
+func addBundleMember(file string, create func(name string, info os.FileInfo) (io.Writer, error)) error {
+	source, err := os.Open(file)
+	if err != nil {
+		log.Printf("bundle: skipping %s: %v", file, err) // A missing PDF should not sink the whole bundle
+		return nil
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(file) // Manifest at the top level
+	if file != manifestFile {
+		name = "PDFs/" + libraryRelativePath(file) // Document type subfolders included
+	}
+	member, err := create(name, info)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(member, source)
+	return err
+}
@@ -0,0 +1,25 @@
+package main // Define the main package
+
+import (
+	"flag"    // For command-line flag parsing
+	"strings" // For splitting the flag
+
+	"github.com/Strong-Foundation/hillyard-com-documentation/pkg/hillyard" // For canonicalizing links
+)
+
+func init() {
+	flag.Func("tracking-params", "comma-separated query parameters to drop from links before deduplication, in addition to utm_* and the common click ids", func(value string) error {
+		for _, name := range strings.Split(value, ",") { // Register the tracking parameter flag
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				hillyard.TrackingParams[name] = true
+			}
+		}
+		return nil
+	})
+}
+
+// canonicalURL rewrites a link so that links to the same document compare
+// equal; see hillyard.CanonicalURL.
+func canonicalURL(rawURL string) string {
+	return hillyard.CanonicalURL(rawURL)
+}
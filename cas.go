@@ -0,0 +1,196 @@
+package main // Define the main package
+
+import (
+	"errors"        // For telling existing objects apart
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting unknown layouts
+	"io/fs"         // For walking the object store
+	"log"           // For logging messages and errors
+	"os"            // For links and removals
+	"path/filepath" // For building object paths
+	"strings"       // For object names
+)
+
+// Storage layouts of the PDF folder
+const (
+	layoutFlat = "flat" // Every PDF stored once under its readable name
+	layoutCAS  = "cas"  // Content stored under objects/ by hash, readable names are hard links to it
+)
+
+var storageLayout = layoutFlat // How PDFs are stored
+
+func init() {
+	flag.Func("layout", "storage layout of the PDF folder: flat, or cas to keep content under objects/ab/cdef….pdf by SHA-256 with the readable names as hard links (default flat)", func(value string) error {
+		if value != layoutFlat && value != layoutCAS {
+			return fmt.Errorf("unknown layout %q (use flat or cas)", value)
+		}
+		storageLayout = value
+		return nil
+	}) // Register the layout flag
+}
+
+// objectStoreDir is the folder holding content-addressed objects.
+func objectStoreDir() string {
+	return filepath.Join(outputDir, "objects")
+}
+
+// objectPath returns where content with the given hash is stored, fanned
+// out over 256 folders by the first byte like git does.
+func objectPath(hash string) string {
+	return filepath.Join(objectStoreDir(), hash[:2], hash[2:]+".pdf")
+}
+
+// storeObject makes the file at path a view of its object. The first copy
+// of some content becomes the object itself through a second link; later
+// files with the same content are replaced by a link to that object, so
+// renames and duplicates never copy data. Flat layouts are left alone.
+func storeObject(path, hash string) error {
+	if storageLayout != layoutCAS {
+		return nil
+	}
+	return linkObject(path, hash)
+}
+
+// linkObject links path and the object for hash, whichever exists.
+func linkObject(path, hash string) error {
+	object := objectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+		return err
+	}
+	err := os.Link(path, object) // New content becomes the object
+	if !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if viewInfo, err := os.Stat(path); err == nil { // Already a view of the object
+		if objectInfo, err := os.Stat(object); err == nil && os.SameFile(viewInfo, objectInfo) {
+			return nil
+		}
+	}
+	temporary := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".link.tmp")
+	os.Remove(temporary) // Left over by an interrupted relink
+	if err := os.Link(object, temporary); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path) // Swap the copy for the link in one step
+}
+
+// runObjectsCommand handles "objects migrate", "objects verify" and
+// "objects relink".
+func runObjectsCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: objects migrate | verify | relink")
+	}
+	documents := mustLoadManifest()
+	switch args[0] {
+	case "migrate":
+		migrateToObjects(documents)
+	case "verify":
+		if !verifyObjects(documents) {
+			exit(1) // Let scripts notice damaged objects
+		}
+	case "relink":
+		relinkViews(documents)
+	default:
+		fatalln("usage: objects migrate | verify | relink")
+	}
+}
+
+// migrateToObjects moves an existing flat library into the object store.
+// Files that no longer match the manifest are left as they are.
+func migrateToObjects(documents *manifest) {
+	migrated := 0
+	for _, entry := range documents.sortedEntries() {
+		path := entry.localPath()
+		if hash, err := hashFile(path); err != nil || hash != entry.SHA256 {
+			log.Printf("not migrating %s: it does not match the manifest, run verify first", entry.File)
+			continue
+		}
+		if err := linkObject(path, entry.SHA256); err != nil {
+			log.Printf("failed to migrate %s: %v", entry.File, err)
+			continue
+		}
+		migrated++
+	}
+	log.Printf("migrated %d documents into %s; run with -layout cas from now on", migrated, objectStoreDir())
+}
+
+// verifyObjects checks every object against the hash in its name and every
+// document against its object, and reports whether all of them are intact.
+func verifyObjects(documents *manifest) bool {
+	objects, damaged := 0, 0
+	filepath.WalkDir(objectStoreDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pdf") {
+			return nil
+		}
+		objects++
+		expected := filepath.Base(filepath.Dir(path)) + strings.TrimSuffix(entry.Name(), ".pdf") // The name is the hash
+		if hash, err := hashFile(path); err != nil || hash != expected {
+			log.Printf("object %s is damaged", path)
+			damaged++
+		}
+		return nil
+	})
+	for _, entry := range documents.currentEntries() {
+		objectInfo, err := os.Stat(objectPath(entry.SHA256))
+		if err != nil {
+			log.Printf("%s: no object for %s", entry.File, entry.SHA256)
+			damaged++
+			continue
+		}
+		if viewInfo, err := os.Stat(entry.localPath()); err != nil || !os.SameFile(viewInfo, objectInfo) {
+			log.Printf("%s: not linked to its object, run objects relink", entry.File)
+			damaged++
+		}
+	}
+	log.Printf("verified %d objects, %d problems", objects, damaged)
+	return damaged == 0
+}
+
+// relinkViews recreates the readable names from the object store, e.g.
+// after the objects were replicated to another machine with the manifest.
+func relinkViews(documents *manifest) {
+	relinked := 0
+	for _, entry := range documents.sortedEntries() {
+		if entry.Pruned == pruneActionDelete || !fileExists(objectPath(entry.SHA256)) {
+			continue // Nothing to show, or nothing to show it from
+		}
+		path := entry.localPath()
+		if !fileExists(path) { // Create the view from scratch
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil || os.Link(objectPath(entry.SHA256), path) != nil {
+				log.Printf("failed to link %s", path)
+				continue
+			}
+		} else if err := linkObject(path, entry.SHA256); err != nil {
+			log.Printf("failed to link %s: %v", path, err)
+			continue
+		}
+		relinked++
+	}
+	log.Printf("linked %d documents to their objects", relinked)
+}
+
+// removeOrphanedObjects deletes objects no manifest entry refers to, for
+// db vacuum, and returns how many were removed.
+func removeOrphanedObjects() int {
+	documents, err := loadManifest(manifestFile)
+	if err != nil { // Without the manifest every object would look orphaned
+		log.Printf("skipping object store: %v", err)
+		return 0
+	}
+	referenced := make(map[string]bool) // Objects some entry still needs
+	for _, entry := range documents.sortedEntries() {
+		if entry.Pruned != pruneActionDelete { // Deleted documents need no content
+			referenced[objectPath(entry.SHA256)] = true
+		}
+	}
+	removed := 0
+	filepath.WalkDir(objectStoreDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.HasSuffix(entry.Name(), ".pdf") && !referenced[path] {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed
+}
@@ -0,0 +1,127 @@
+package main // Define the main package
+
+import (
+	"flag"          // For command-line flag parsing
+	"io/fs"         // For walking the farm
+	"log"           // For logging the rebuild
+	"os"            // For creating and removing links
+	"path/filepath" // For building link paths
+	"sort"          // For removing emptied folders deepest first
+	"strings"       // For cleaning category names
+)
+
+// uncategorized is the folder of documents whose category is not known.
+const uncategorized = "Uncategorized"
+
+var categoryDir string // Folder of per-category links to the PDFs ("" = off)
+
+func init() {
+	flag.StringVar(&categoryDir, "category-dir", "", "after each run, mirror the PDFs into <dir>/<product category>/ as hard links (symbolic links across file systems), e.g. by-category") // Register the category farm flag
+}
+
+// categoryFolder turns a category into a folder name, keeping it readable.
+func categoryFolder(category string) string {
+	name := strings.Map(func(character rune) rune {
+		if character < ' ' || strings.ContainsRune(`/\:*?"<>|`, character) { // Not allowed in folder names on some platforms
+			return '-'
+		}
+		return character
+	}, strings.TrimSpace(category))
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return uncategorized
+	}
+	return name
+}
+
+// linkDocument links target at path: a hard link, so the farm costs no
+// space, or a symbolic link when the farm is on another file system.
+func linkDocument(target, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if os.Link(target, path) == nil {
+		return nil
+	}
+	absolute, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(absolute, path)
+}
+
+// buildCategoryFarm brings --category-dir in line with the manifest: every
+// current document is linked under its category, keeping its path inside
+// the PDF folder, and links to anything else are removed. Categories come
+// from the search results when they name one, so documents downloaded
+// before categories were recorded pick theirs up here.
+func buildCategoryFarm(documents *manifest) {
+	if categoryDir == "" {
+		return
+	}
+	wanted := make(map[string]string) // Link path → PDF it points at
+	for _, entry := range documents.currentEntries() {
+		if category := linkCategory(entry.URL); category != "" && category != entry.Category { // Recategorized on the site
+			entry.Category = category
+			documents.replace(entry)
+		}
+		path := filepath.Join(categoryDir, categoryFolder(entry.Category), filepath.FromSlash(entry.File))
+		wanted[path] = entry.localPath()
+	}
+	removed, linked := 0, 0
+	var folders []string // Folders that may be empty afterwards
+	filepath.WalkDir(categoryDir, func(path string, item fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Nothing built yet, or unreadable
+		}
+		if item.IsDir() {
+			folders = append(folders, path)
+			return nil
+		}
+		target, keep := wanted[path]
+		if keep {
+			linkInfo, linkErr := os.Stat(path) // Follows symbolic links
+			targetInfo, targetErr := os.Stat(target)
+			keep = linkErr == nil && targetErr == nil && os.SameFile(linkInfo, targetInfo) // The PDF was not replaced since
+		}
+		if keep {
+			delete(wanted, path)
+		} else if os.Remove(path) == nil {
+			removed++
+		}
+		return nil
+	})
+	for path, target := range wanted {
+		if !fileExists(target) { // Missing from the library; verify reports it
+			continue
+		}
+		if err := linkDocument(target, path); err != nil {
+			log.Printf("failed to link %s: %v", path, err)
+			continue
+		}
+		linked++
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(folders))) // Children before their parents
+	for _, folder := range folders {
+		if folder != categoryDir {
+			os.Remove(folder) // Only succeeds when empty
+		}
+	}
+	if linked > 0 || removed > 0 {
+		log.Printf("category folders: %d links added, %d removed in %s", linked, removed, categoryDir)
+	}
+}
+
+// runCategoryFarmCommand rebuilds --category-dir from the manifest without
+// crawling, filling in categories from the saved search results.
+func runCategoryFarmCommand() {
+	if categoryDir == "" {
+		fatalConfig("category-farm needs -category-dir")
+	}
+	documentManifest = mustLoadManifest()
+	discoveredLinks() // Read the saved results for their categories
+	buildCategoryFarm(documentManifest)
+	if err := documentManifest.save(manifestFile); err != nil {
+		log.Printf("failed to save manifest %s: %v", manifestFile, err)
+	}
+}
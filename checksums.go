@@ -0,0 +1,88 @@
+package main // Define the main package
+
+import (
+	"flag"          // For command-line flag parsing
+	"fmt"           // For formatting checksum lines
+	"log"           // For logging messages and errors
+	"os"            // For reading existing sidecars
+	"path/filepath" // For building sidecar paths
+	"strings"       // For parsing the flag and building SHA256SUMS
+)
+
+// Checksum formats written next to the PDFs
+const (
+	checksumSidecar = "sidecar" // <file>.pdf.sha256 per document
+	checksumSums    = "sums"    // One SHA256SUMS file for the folder
+)
+
+var checksumFormats = make(map[string]bool) // Formats selected with -checksums
+
+func init() {
+	flag.Func("checksums", "publish sha256sum-compatible checksums in the PDF folder: sidecar (<file>.pdf.sha256), sums (SHA256SUMS) or both, comma-separated", func(value string) error {
+		for _, format := range strings.Split(value, ",") { // Register the checksum flag
+			format = strings.TrimSpace(format)
+			if format != checksumSidecar && format != checksumSums {
+				return fmt.Errorf("unknown checksum format %q (want sidecar or sums)", format)
+			}
+			checksumFormats[format] = true
+		}
+		return nil
+	})
+}
+
+// writeChecksums brings the checksum files in the PDF folder in line with
+// the manifest, so recipients can check the library with "sha256sum -c".
+// Sidecars are only rewritten when their content changes.
+func writeChecksums(documents *manifest) {
+	if len(checksumFormats) == 0 { // Checksums are opt-in
+		return
+	}
+	var sums strings.Builder // SHA256SUMS content
+	sidecars := 0            // Sidecars written this time
+	for _, entry := range documents.currentEntries() {
+		if !fileExists(filepath.Join(outputDir, entry.File)) { // Only what the folder holds
+			continue
+		}
+		line := entry.SHA256 + "  " + entry.File + "\n" // Two spaces: text mode in sha256sum
+		sums.WriteString(line)
+		if !checksumFormats[checksumSidecar] {
+			continue
+		}
+		sidecar := filepath.Join(outputDir, entry.File+".sha256")
+		if existing, err := os.ReadFile(sidecar); err == nil && string(existing) == line {
+			continue
+		}
+		if _, err := writeFileAtomically(sidecar, strings.NewReader(line), int64(len(line))); err != nil {
+			log.Printf("failed to write checksum %s: %v", sidecar, err)
+			continue
+		}
+		sidecars++
+	}
+	if checksumFormats[checksumSidecar] {
+		removeOrphanedSidecars()
+		if sidecars > 0 {
+			log.Printf("wrote %d checksum sidecars", sidecars)
+		}
+	}
+	if checksumFormats[checksumSums] {
+		target := filepath.Join(outputDir, "SHA256SUMS")
+		if _, err := writeFileAtomically(target, strings.NewReader(sums.String()), int64(sums.Len())); err != nil {
+			log.Printf("failed to write %s: %v", target, err)
+		}
+	}
+}
+
+// Remove sidecars whose PDF is gone, e.g. after pruning
+func removeOrphanedSidecars() {
+	sidecars, err := filepath.Glob(filepath.Join(outputDir, "*.pdf.sha256"))
+	if err != nil {
+		return
+	}
+	nested, _ := filepath.Glob(filepath.Join(outputDir, "*", "*.pdf.sha256")) // Document type subfolders
+	sidecars = append(sidecars, nested...)
+	for _, sidecar := range sidecars {
+		if !fileExists(strings.TrimSuffix(sidecar, ".sha256")) {
+			os.Remove(sidecar)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main // Define the main package
+
+import (
+	"flag" // For printing flag defaults
+	"fmt"  // For printing usage
+	"os"   // For the usage output stream
+	"sort" // For listing commands alphabetically
+)
+
+// command is one subcommand of the tool.
+type command struct {
+	summary string              // One-line description shown in the usage
+	run     func(args []string) // Runs the command with the positional arguments
+}
+
+// commands lists every subcommand by name. Running the tool without a
+// command is the same as `run`.
+var commands = map[string]command{
+	"run":            {"discover and download everything (default)", func(args []string) { exitWithOutcome(runCrawl()) }},
+	"mirror":         {"search, download, verify, index and report in one run with archival defaults", func(args []string) { runMirrorCommand() }},
+	"discover":       {"search pending queries and save the results", func(args []string) { exitWithOutcome(runDiscoverCommand()) }},
+	"download":       {"download every discovered PDF that is not stored yet", func(args []string) { exitWithOutcome(runDownloadCommand()) }},
+	"verify":         {"check stored PDFs against the manifest", func(args []string) { runVerifyCommand() }},
+	"list":           {"list the documents in the manifest", func(args []string) { runListCommand() }},
+	"stats":          {"show library size, pending work and index counts", func(args []string) { runStatsCommand() }},
+	"has":            {"exit 0 if a product has an SDS in the mirror, 1 if not", lookupCommand("has", nil)},
+	"path-of":        {"print the local path of a product's SDS", lookupCommand("path-of", manifestEntry.localPath)},
+	"hash-of":        {"print the SHA-256 of a product's SDS", lookupCommand("hash-of", func(entry manifestEntry) string { return entry.SHA256 })},
+	"review":         {"list and set review states of documents", runReviewCommand},
+	"approve":        {"pin the current library as the approved manifest", func(args []string) { runApproveCommand() }},
+	"drift":          {"compare the library with the approved manifest", func(args []string) { runDriftCommand() }},
+	"filenames":      {"audit stored filenames mangled by the old sanitizer and apply better names", runFilenamesCommand},
+	"diff":           {"compare two manifests or snapshots and list added, removed and revised documents", runDiffCommand},
+	"export":         {"write catalog.csv and catalog.jsonl from the manifest", func(args []string) { runExportCommand() }},
+	"contacts":       {"write a printable emergency contact sheet from the SDS", func(args []string) { runContactsCommand() }},
+	"queue":          {"show or export the pending work", runQueueCommand},
+	"request":        {"record and report products with no SDS in the mirror", runRequestCommand},
+	"barcode":        {"maintain the barcode to product table and look up scanned codes", runBarcodeCommand},
+	"daemon":         {"crawl on a schedule with a background integrity sweep; daemon status, stop, unit, install or uninstall manage it", runDaemonCommand},
+	"db":             {"maintain the data folders: saved search results, seen-URL index and link cache; no database (vacuum, migrate-results)", runDBCommand},
+	"attach":         {"manage locally sourced supplemental documents", runAttachCommand},
+	"index":          {"extract PDF text and update the search index", func(args []string) { runIndexCommand() }},
+	"category-farm":  {"rebuild the -category-dir folders of links to the PDFs by product category", func(args []string) { runCategoryFarmCommand() }},
+	"coverage":       {"show what each search query found and which ones hit the result cap", func(args []string) { runCoverageCommand() }},
+	"search":         {"search the text of the indexed PDFs, e.g. search sodium hypochlorite", runSearchCommand},
+	"reindex":        {"rebuild the search index from scratch; reindex manifest rebuilds the manifest from the PDFs on disk", runReindexCommand},
+	"objects":        {"migrate to, verify and relink the content-addressed store of -layout cas", runObjectsCommand},
+	"serve":          {"serve the library over HTTP", func(args []string) { runServeCommand() }},
+	"doctor":         {"check folders, free space, manifest, index and the origin", func(args []string) { runDoctorCommand() }},
+	"support-bundle": {"collect sanitized config, logs, reports and diagnostics into a zip for bug reports", runSupportBundleCommand},
+	"history":        {"show the summaries of past runs, optionally only the last N", runHistoryCommand},
+	"snapshot":       {"create, sign and verify the chain of manifest snapshots", runSnapshotCommand},
+	"versions":       {"list the superseded revisions kept by -keep-versions, optionally of the named documents", runVersionsCommand},
+}
+
+// crawlCommands search and download and take no arguments.
+var crawlCommands = map[string]bool{"run": true, "mirror": true, "discover": true, "download": true}
+
+// Print the command list followed by the flag defaults
+func printUsage() {
+	output := flag.CommandLine.Output() // Usage goes to stderr
+	fmt.Fprintf(output, "usage: %s [command] [flags] [arguments]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands)) // Sorted command names
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(output, "  %-15s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(output, "\nrun, discover, download and mirror exit with 0 on success, 1 when some requests failed,\n2 on a configuration error, 3 when the search results list no documents at all and 4 when\nanother instance is using the same data folders.")
+	fmt.Fprintln(output, "\nflags:")
+	flag.PrintDefaults()
+}
@@ -0,0 +1,79 @@
+package main // Define the main package
+
+import (
+	"bufio"          // For peeking at the deflate header
+	"compress/flate" // For raw deflate bodies
+	"compress/gzip"  // For gzip bodies
+	"compress/zlib"  // For zlib-wrapped deflate bodies
+	"context"        // For the search timeout
+	"flag"           // For command-line flag parsing
+	"fmt"            // For rejecting unknown encodings
+	"io"             // For wrapping bodies
+	"net/http"       // For building search requests
+	"strings"        // For parsing Content-Encoding
+
+	"github.com/andybalholm/brotli" // For brotli bodies
+)
+
+// searchEncodings are the content codings search requests accept.
+const searchEncodings = "gzip, deflate, br"
+
+var disableCompression bool // Ask for and accept uncompressed responses only
+
+func init() {
+	flag.BoolVar(&disableCompression, "disable-compression", false, "ask servers for uncompressed responses, e.g. to read search results off the wire while debugging") // Register the compression flag
+}
+
+// fetchSearchResponse requests a search result page within --search-timeout,
+// accepting gzip, deflate and brotli unless --disable-compression is set. Cancelling
+// ctx, e.g. with the -tui skip and stop keys, abandons the request.
+func fetchSearchResponse(ctx context.Context, url string) (*http.Response, error) {
+	startSession() // Log in before the first request
+	request, err := http.NewRequestWithContext(context.WithValue(ctx, phaseTimeoutKey{}, searchTimeout), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if disableCompression {
+		request.Header.Set("Accept-Encoding", "identity")
+	} else { // Set explicitly, so the body is decoded here rather than by the transport
+		request.Header.Set("Accept-Encoding", searchEncodings)
+	}
+	return currentFetcher().Do(request)
+}
+
+// decodeBody wraps a response body in the decoders its Content-Encoding
+// names, applied in reverse order, and refuses codings it cannot decode
+// rather than let compressed bytes be saved as results.
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for index := len(codings) - 1; index >= 0; index-- { // The last coding was applied last
+		switch coding := strings.ToLower(strings.TrimSpace(codings[index])); coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			decoded, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, err
+			}
+			body = decoded
+		case "deflate":
+			body = deflateReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+		}
+	}
+	return body, nil
+}
+
+// deflateReader decodes a deflate body, which servers send either wrapped
+// in zlib as the standard says or as raw deflate.
+func deflateReader(body io.Reader) io.Reader {
+	buffered := bufio.NewReader(body)
+	if header, err := buffered.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 { // A zlib header
+		if decoded, err := zlib.NewReader(buffered); err == nil {
+			return decoded
+		}
+	}
+	return flate.NewReader(buffered)
+}
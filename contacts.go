@@ -0,0 +1,241 @@
+package main // Define the main package
+
+import (
+	"bufio"         // For reading the facility contact list
+	"bytes"         // For rendering the sheet
+	"flag"          // For command-line flag parsing
+	"html/template" // For the printable sheet
+	"log"           // For logging messages and errors
+	"os"            // For file access
+	"path/filepath" // For building the output path
+	"regexp"        // For finding phone numbers
+	"sort"          // For ordering the contacts
+	"strings"       // For text handling
+	"sync"          // For merging worker results
+	"time"          // For the generation date
+)
+
+// sectionOneLimit caps how much text is searched when a document has no
+// recognizable Section 1 heading.
+const sectionOneLimit = 4000
+
+var (
+	facilityName         string // Printed at the top of the contact sheet
+	facilityContactsFile string // Facility's own emergency numbers, one "Label: number" per line
+	sectionOneStartRegex = regexp.MustCompile(`(?i)section\s*1\b`)
+	sectionTwoStartRegex = regexp.MustCompile(`(?i)section\s*2\b`)
+	emergencyWordRegex   = regexp.MustCompile(`(?i)emergenc|chemtrec|canutec|infotrac|poison|24[\s-]*h`)
+	phoneNumberRegex     = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)|\d{3})[\s.-]?\d{3}[\s.-]?\d{4}|1-800-[A-Z0-9-]{7,}`)
+	knownResponders      = []string{"CHEMTREC", "CANUTEC", "INFOTRAC", "Poison Control"} // Named in place of a generic label
+)
+
+func init() {
+	flag.StringVar(&facilityName, "facility-name", "", "facility name printed on the emergency contact sheet")                                                                        // Register the facility name flag
+	flag.StringVar(&facilityContactsFile, "facility-contacts", "", "file of the facility's own emergency numbers, one \"Label: number\" per line, listed first on the contact sheet") // Register the facility contacts flag
+}
+
+// emergencyContact is one number on the sheet.
+type emergencyContact struct {
+	Label    string   `json:"label"`              // Who answers
+	Number   string   `json:"number"`             // Number as printed in the SDS
+	Products []string `json:"products,omitempty"` // Products whose SDS list it, empty for facility numbers
+}
+
+// contactSheet is everything printed on the sheet.
+type contactSheet struct {
+	Facility    string             `json:"facility"`     // Facility name
+	GeneratedAt time.Time          `json:"generated_at"` // When the sheet was built
+	Facilities  []emergencyContact `json:"facility_contacts"`
+	Vendors     []emergencyContact `json:"vendor_contacts"` // Numbers found in the SDS, most widely used first
+}
+
+// loadFacilityContacts reads the facility list, defaulting to the US
+// emergency and poison control numbers.
+func loadFacilityContacts() ([]emergencyContact, error) {
+	if facilityContactsFile == "" {
+		return []emergencyContact{{Label: "Emergency services", Number: "911"}, {Label: "Poison Control (US)", Number: "1-800-222-1222"}}, nil
+	}
+	file, err := os.Open(facilityContactsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var contacts []emergencyContact
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") { // Blank lines and comments
+			continue
+		}
+		label, number, found := strings.Cut(line, ":")
+		if !found {
+			log.Printf("ignoring facility contact without \"Label: number\": %q", line)
+			continue
+		}
+		contacts = append(contacts, emergencyContact{Label: strings.TrimSpace(label), Number: strings.TrimSpace(number)})
+	}
+	return contacts, scanner.Err()
+}
+
+// documentText returns the text of a stored document, reusing the text
+// extracted by the index command when there is one.
+func documentText(entry manifestEntry) (string, error) {
+	if text, err := os.ReadFile(extractedTextPath(entry.SHA256)); err == nil {
+		return string(text), nil
+	}
+	return extractPDFText(entry.localPath())
+}
+
+// sectionOne returns the identification section of an SDS, where the
+// supplier's emergency number is printed.
+func sectionOne(text string) string {
+	if start := sectionOneStartRegex.FindStringIndex(text); start != nil {
+		text = text[start[0]:]
+		if end := sectionTwoStartRegex.FindStringIndex(text); end != nil {
+			text = text[:end[0]]
+		}
+	}
+	if len(text) > sectionOneLimit {
+		text = text[:sectionOneLimit]
+	}
+	return text
+}
+
+// findEmergencyNumbers returns the numbers printed shortly after an
+// emergency keyword, labelled with the responder when it is named.
+func findEmergencyNumbers(section string) []emergencyContact {
+	var contacts []emergencyContact
+	seen := make(map[string]bool) // Digits already found
+	for _, keyword := range emergencyWordRegex.FindAllStringIndex(section, -1) {
+		window := section[keyword[0]:min(len(section), keyword[0]+160)] // The number follows the keyword closely
+		number := phoneNumberRegex.FindString(window)
+		if number == "" || seen[phoneDigits(number)] {
+			continue
+		}
+		seen[phoneDigits(number)] = true
+		label := "Emergency"
+		for _, responder := range knownResponders {
+			if strings.Contains(strings.ToUpper(window), strings.ToUpper(responder)) {
+				label = responder
+				break
+			}
+		}
+		contacts = append(contacts, emergencyContact{Label: label, Number: strings.TrimSpace(number)})
+	}
+	return contacts
+}
+
+// Keep only the digits and letters of a number, for grouping
+func phoneDigits(number string) string {
+	return strings.TrimPrefix(normalizeBarcode(number), "1") // Same rules as codes; drop the US country code
+}
+
+// buildContactSheet collects the emergency numbers of every mirrored SDS.
+func buildContactSheet() (contactSheet, error) {
+	facilities, err := loadFacilityContacts()
+	if err != nil {
+		return contactSheet{}, err
+	}
+	entries := mustLoadManifest().currentEntries()
+	byFile := make(map[string]manifestEntry, len(entries))
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		byFile[entry.File] = entry
+		files = append(files, entry.File)
+	}
+	byNumber := make(map[string]*emergencyContact) // Digits → contact
+	var mutex sync.Mutex                           // Guards byNumber
+	runWorkerPool(files, indexWorkers, func(file string) {
+		entry := byFile[file]
+		text, err := documentText(entry)
+		if err != nil {
+			log.Printf("failed to extract text from %s: %v", file, err)
+			return
+		}
+		product := entry.Title // Prefer the product name
+		if product == "" {
+			product = strings.TrimSuffix(file, ".pdf")
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, found := range findEmergencyNumbers(sectionOne(text)) {
+			contact, known := byNumber[phoneDigits(found.Number)]
+			if !known {
+				contact = &found
+				byNumber[phoneDigits(found.Number)] = contact
+			} else if contact.Label == "Emergency" { // A later SDS may name the responder
+				contact.Label = found.Label
+			}
+			contact.Products = append(contact.Products, product)
+		}
+	})
+	sheet := contactSheet{Facility: facilityName, GeneratedAt: time.Now().UTC(), Facilities: facilities, Vendors: []emergencyContact{}}
+	for _, contact := range byNumber {
+		sort.Strings(contact.Products)
+		sheet.Vendors = append(sheet.Vendors, *contact)
+	}
+	sort.Slice(sheet.Vendors, func(i, j int) bool {
+		if len(sheet.Vendors[i].Products) != len(sheet.Vendors[j].Products) {
+			return len(sheet.Vendors[i].Products) > len(sheet.Vendors[j].Products)
+		}
+		return sheet.Vendors[i].Number < sheet.Vendors[j].Number
+	})
+	return sheet, nil
+}
+
+// contactSheetTemplate renders the sheet for printing on one or two pages.
+var contactSheetTemplate = template.Must(template.New("contacts").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Emergency contacts{{with .Facility}} — {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 1.5cm; }
+h1 { border-bottom: 4px solid #c00; padding-bottom: 0.2em; }
+table { width: 100%; border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.35em; border-bottom: 1px solid #999; vertical-align: top; }
+.number { font-size: 1.3em; font-weight: bold; white-space: nowrap; }
+.products { font-size: 0.85em; }
+footer { font-size: 0.8em; color: #555; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Emergency contacts{{with .Facility}} — {{.}}{{end}}</h1>
+<h2>Facility</h2>
+<table>
+{{range .Facilities}}<tr><td>{{.Label}}</td><td class="number">{{.Number}}</td></tr>
+{{end}}</table>
+<h2>Product emergency lines (from SDS Section 1)</h2>
+<table>
+<tr><th>Responder</th><th>Number</th><th>Products</th></tr>
+{{range .Vendors}}<tr><td>{{.Label}}</td><td class="number">{{.Number}}</td><td class="products">{{range $index, $product := .Products}}{{if $index}}, {{end}}{{$product}}{{end}}</td></tr>
+{{else}}<tr><td colspan="3">No emergency numbers were found in the mirrored SDS.</td></tr>
+{{end}}</table>
+<footer>Generated {{.GeneratedAt.Format "2006-01-02"}} from the SDS library. Always confirm against the SDS of the product involved.</footer>
+</body>
+</html>
+`))
+
+// runContactsCommand writes emergency-contacts.html to the export folder,
+// or prints the sheet as JSON with -output json.
+func runContactsCommand() {
+	sheet, err := buildContactSheet()
+	if err != nil {
+		fatalf("failed to read facility contacts %s: %v", facilityContactsFile, err)
+	}
+	if jsonOutput() {
+		printJSON(sheet)
+		return
+	}
+	var content bytes.Buffer
+	if err := contactSheetTemplate.Execute(&content, sheet); err != nil {
+		fatalln(err)
+	}
+	createDirectory(exportDir, 0755)
+	target := filepath.Join(exportDir, "emergency-contacts.html")
+	if _, err := writeFileAtomically(target, &content, int64(content.Len())); err != nil {
+		fatalln(err)
+	}
+	log.Printf("wrote %s: %d facility and %d product emergency numbers", target, len(sheet.Facilities), len(sheet.Vendors))
+}
@@ -0,0 +1,144 @@
+package main // Define the main package
+
+import (
+	"bufio"              // For reading the cookie and login files
+	"flag"               // For command-line flag parsing
+	"fmt"                // For login errors
+	"log"                // For logging the session setup
+	"net/http"           // For cookies and the login request
+	"net/http/cookiejar" // For the session cookie jar
+	"net/url"            // For cookie URLs and login forms
+	"os"                 // For opening the files
+	"strconv"            // For cookie expiry times
+	"strings"            // For parsing lines
+	"sync"               // For setting the session up once
+	"time"               // For cookie expiry times
+
+	"golang.org/x/net/publicsuffix" // Keeps cookies from being shared across unrelated sites
+)
+
+var (
+	cookieFile      string         // Netscape cookies.txt loaded into the jar
+	loginURL        string         // Form the crawler logs in through before its first request
+	loginFieldsFile string         // Fields posted to loginURL, one name=value per line
+	sessionOnce     sync.Once      // Loads cookies and logs in before the first request
+	cookieJar       *cookiejar.Jar // Cookies of the session, shared by every request
+)
+
+func init() {
+	flag.StringVar(&cookieFile, "cookie-file", "", "load session cookies from this Netscape-format cookies.txt, e.g. exported from a logged-in browser")              // Register the cookie file flag
+	flag.StringVar(&loginURL, "login-url", "", "log in by posting -login-fields-file to this URL before the first request, for documents behind a distributor login") // Register the login URL flag
+	flag.StringVar(&loginFieldsFile, "login-fields-file", "", "form fields posted to -login-url, one name=value per line, e.g. username=... and password=...")        // Register the login fields flag
+	cookieJar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})                                                                             // Never fails
+}
+
+// startSession loads --cookie-file and logs in through --login-url, once,
+// before the first crawl request. Failures are logged: public documents can
+// still be fetched without the session.
+func startSession() {
+	sessionOnce.Do(func() {
+		if cookieFile != "" {
+			if loaded, err := loadCookieFile(cookieFile); err != nil {
+				log.Printf("failed to load cookies from %s: %v", cookieFile, err)
+			} else {
+				log.Printf("loaded %d cookies from %s", loaded, cookieFile)
+			}
+		}
+		if loginURL != "" {
+			if err := logIn(); err != nil {
+				log.Printf("login at %s failed: %v", sanitizeURL(loginURL), err)
+			} else {
+				log.Printf("logged in at %s", sanitizeURL(loginURL))
+			}
+		}
+	})
+}
+
+// loadCookieFile adds the cookies of a Netscape cookies.txt to the jar: one
+// cookie per line as domain, include-subdomains, path, secure, expiry, name
+// and value separated by tabs. Expired cookies are skipped.
+func loadCookieFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	loaded := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "#HttpOnly_") // curl marks HttpOnly cookies this way
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		cookie := &http.Cookie{Name: fields[5], Value: fields[6], Path: fields[2], Secure: fields[3] == "TRUE"}
+		host := strings.TrimPrefix(fields[0], ".")
+		if fields[1] == "TRUE" { // Sent to subdomains too
+			cookie.Domain = host
+		}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		cookieJar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{cookie})
+		loaded++
+	}
+	return loaded, scanner.Err()
+}
+
+// loginFields reads --login-fields-file.
+func loginFields() (url.Values, error) {
+	fields := url.Values{}
+	if loginFieldsFile == "" {
+		return fields, nil
+	}
+	file, err := os.Open(loginFieldsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		fields.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return fields, scanner.Err()
+}
+
+// logIn posts the login form; the session cookies it sets land in the jar.
+func logIn() error {
+	fields, err := loginFields()
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, loginURL, strings.NewReader(fields.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := currentFetcher().Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("server answered %s", response.Status)
+	}
+	if len(cookieJar.Cookies(request.URL)) == 0 {
+		return fmt.Errorf("no session cookie was set")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main // Define the main package
+
+import (
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the table
+	"log"            // For the summary after discovery
+	"os"             // For writing to stdout
+	"sort"           // For listing capped queries first
+	"strings"        // For abbreviating suggestions
+	"text/tabwriter" // For aligned columns
+)
+
+// capSharedBy is how many queries must return the same, largest number of
+// links before that number is taken to be the API's per-query result cap.
+const capSharedBy = 3
+
+var resultCap int // Most links the search returns per query (0 = detect)
+
+func init() {
+	flag.IntVar(&resultCap, "result-cap", 0, "most documents the search returns for one query; queries reaching it are reported as capped (0 = detect from the saved results)") // Register the result cap flag
+}
+
+// queryCoverage is what one searched query contributed to the catalog.
+type queryCoverage struct {
+	Query     string   `json:"query"`               // Query target
+	Links     int      `json:"links"`               // Document links it returned
+	Exclusive int      `json:"exclusive"`           // Links no other query returned
+	Capped    bool     `json:"capped"`              // Reached the result cap or the page limit, so results are likely missing
+	Truncated bool     `json:"truncated,omitempty"` // Pagination stopped before the last page
+	Suggested []string `json:"suggested,omitempty"` // Narrower queries that would find the rest
+}
+
+// catalogCoverage estimates how much of the catalog the searches reached.
+type catalogCoverage struct {
+	QueriesSearched int             `json:"queries_searched"` // Queries with a successful saved result
+	QueriesPending  int             `json:"queries_pending"`  // Queries not searched yet, or failed
+	Documents       int             `json:"documents"`        // Unique document links found
+	ResultCap       int             `json:"result_cap"`       // Per-query cap, given or detected (0 = none seen)
+	CappedQueries   int             `json:"capped_queries"`   // Queries whose results are likely incomplete
+	Queries         []queryCoverage `json:"queries"`          // Capped queries first, then by links
+}
+
+// measureCoverage reads every saved search result and flags the queries
+// whose results were cut off by the result cap or --max-search-pages.
+func measureCoverage() catalogCoverage {
+	var coverage catalogCoverage
+	foundBy := make(map[string]int) // Link → queries returning it
+	var searched []searchResult
+	for _, query := range generateQueries() {
+		result, found := loadSearchResult(query)
+		if !found || !result.searched() {
+			coverage.QueriesPending++
+			continue
+		}
+		searched = append(searched, result)
+		for _, link := range result.Links {
+			foundBy[link.URL]++
+		}
+	}
+	coverage.QueriesSearched, coverage.Documents = len(searched), len(foundBy)
+	coverage.ResultCap = resultCap
+	if coverage.ResultCap == 0 {
+		coverage.ResultCap = detectResultCap(searched)
+	}
+	coverage.Queries = []queryCoverage{}
+	for _, result := range searched {
+		entry := queryCoverage{Query: result.Query, Links: len(result.Links), Truncated: result.Truncated}
+		for _, link := range result.Links {
+			if foundBy[link.URL] == 1 {
+				entry.Exclusive++
+			}
+		}
+		entry.Capped = result.Truncated || (coverage.ResultCap > 0 && entry.Links >= coverage.ResultCap)
+		if entry.Capped {
+			coverage.CappedQueries++
+			for _, character := range queryCharset { // One more character narrows the search
+				entry.Suggested = append(entry.Suggested, result.Query+string(character))
+			}
+		}
+		coverage.Queries = append(coverage.Queries, entry)
+	}
+	sort.SliceStable(coverage.Queries, func(i, j int) bool {
+		if coverage.Queries[i].Capped != coverage.Queries[j].Capped {
+			return coverage.Queries[i].Capped
+		}
+		return coverage.Queries[i].Links > coverage.Queries[j].Links
+	})
+	return coverage
+}
+
+// detectResultCap returns the largest number of links returned by a query
+// when at least capSharedBy queries returned exactly that many: an API
+// without a cap rarely gives several queries the same, largest count.
+func detectResultCap(searched []searchResult) int {
+	largest, sharedBy := 0, 0
+	for _, result := range searched {
+		switch count := len(result.Links); {
+		case count > largest:
+			largest, sharedBy = count, 1
+		case count == largest:
+			sharedBy++
+		}
+	}
+	if largest == 0 || sharedBy < capSharedBy {
+		return 0
+	}
+	return largest
+}
+
+// logCoverage summarizes the coverage after discovery.
+func logCoverage() {
+	coverage := measureCoverage()
+	log.Printf("coverage: %d documents from %d searched queries, %d queries pending", coverage.Documents, coverage.QueriesSearched, coverage.QueriesPending)
+	if coverage.CappedQueries > 0 {
+		log.Printf("coverage: %d queries reached the result cap or page limit and likely miss documents; run coverage for narrower queries", coverage.CappedQueries)
+	}
+}
+
+// runCoverageCommand prints what each searched query found and which ones
+// need narrower queries, e.g. for a --queries-file.
+func runCoverageCommand() {
+	coverage := measureCoverage()
+	if jsonOutput() {
+		printJSON(coverage)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "QUERY\tLINKS\tEXCLUSIVE\tCAPPED\tSUGGESTED")
+	for _, entry := range coverage.Queries {
+		suggested := strings.Join(entry.Suggested, " ")
+		if len(entry.Suggested) > 4 { // The full list is in -output json
+			suggested = strings.Join(entry.Suggested[:4], " ") + fmt.Sprintf(" … (%d)", len(entry.Suggested))
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%t\t%s\n", entry.Query, entry.Links, entry.Exclusive, entry.Capped, suggested)
+	}
+	writer.Flush() // Print the table
+	capNote := "none detected"
+	if coverage.ResultCap > 0 {
+		capNote = fmt.Sprint(coverage.ResultCap)
+	}
+	log.Printf("%d documents from %d searched queries (%d pending); result cap %s; %d queries capped", coverage.Documents, coverage.QueriesSearched, coverage.QueriesPending, capNote, coverage.CappedQueries)
+}
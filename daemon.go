@@ -0,0 +1,268 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For atomic state writes
+	"crypto/sha256" // For throttled hash checks
+	"encoding/hex"  // For encoding hashes
+	"encoding/json" // For the sweep state file
+	"flag"          // For command-line flag parsing
+	"io"            // For hashing through the limiter
+	"log"           // For logging messages and errors
+	"net/http"      // For dead-link and revision checks
+	"os"            // For reading stored documents
+	"path/filepath" // For the state file path
+	"sync"          // For resetting per-run state
+	"time"          // For schedules
+)
+
+// Kinds of integrity findings
+const (
+	findingMissing       = "missing"        // Stored file is gone
+	findingHashMismatch  = "hash_mismatch"  // Stored file no longer matches the manifest
+	findingDeadLink      = "dead_link"      // Source URL answers 404 or 410
+	findingStaleRevision = "stale_revision" // Source reports a newer Last-Modified than the stored copy
+)
+
+var (
+	crawlInterval  time.Duration // Time between crawls in daemon mode
+	sweepPeriod    time.Duration // Time over which the sweep visits every document once
+	sweepBandwidth int64         // Disk read rate of the sweep in bytes per second
+	digestInterval time.Duration // Time between integrity digests
+)
+
+func init() {
+	flag.DurationVar(&crawlInterval, "crawl-interval", 24*time.Hour, "daemon mode: time between crawls")                                            // Register the crawl interval flag
+	flag.DurationVar(&sweepPeriod, "sweep-period", 72*time.Hour, "daemon mode: spread one integrity sweep over the whole library across this long") // Register the sweep period flag
+	flag.Func("sweep-bandwidth", "daemon mode: disk read rate of the integrity sweep, e.g. 1MB/s (default 1MB/s)", func(value string) (err error) {
+		sweepBandwidth, err = parseByteRate(value) // Register the sweep IO throttle flag
+		return err
+	})
+	flag.DurationVar(&digestInterval, "digest-interval", 7*24*time.Hour, "daemon mode: time between integrity digests sent to the -notify channels") // Register the digest interval flag
+	sweepBandwidth = 1e6                                                                                                                             // 1MB/s unless configured
+}
+
+// integrityFinding is one problem the sweep found.
+type integrityFinding struct {
+	Kind    string    `json:"kind"`             // One of the finding kinds
+	File    string    `json:"file"`             // Filename inside the PDF folder
+	URL     string    `json:"url"`              // Source URL
+	Detail  string    `json:"detail,omitempty"` // Extra context
+	FoundAt time.Time `json:"found_at"`         // When it was last seen
+}
+
+// sweepState survives restarts so a sweep spread over days resumes where it
+// stopped and findings are not lost before the digest goes out.
+type sweepState struct {
+	Cursor       int                `json:"cursor"`         // Index of the next document to check
+	Findings     []integrityFinding `json:"findings"`       // Findings since the last digest
+	DigestSentAt time.Time          `json:"digest_sent_at"` // When the last digest was sent
+}
+
+// Path of the sweep state file
+func sweepStatePath() string {
+	return filepath.Join(givenFolder, "sweep-state.json") // Lives next to the search results
+}
+
+// Load the sweep state, starting fresh if there is none
+func loadSweepState() *sweepState {
+	state := &sweepState{DigestSentAt: time.Now()} // First digest one interval from now
+	if content, err := os.ReadFile(sweepStatePath()); err == nil {
+		json.Unmarshal(content, state) // A corrupt state just restarts the sweep
+	}
+	return state
+}
+
+// Persist the sweep state
+func (state *sweepState) save() {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := writeFileAtomically(sweepStatePath(), bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Println(err)
+	}
+}
+
+// Add a finding, replacing an earlier one of the same kind for the same file
+func (state *sweepState) addFinding(finding integrityFinding) {
+	log.Printf("integrity sweep: %s: %s %s", finding.File, finding.Kind, finding.Detail)
+	for index, existing := range state.Findings {
+		if existing.File == finding.File && existing.Kind == finding.Kind {
+			state.Findings[index] = finding
+			return
+		}
+	}
+	state.Findings = append(state.Findings, finding)
+}
+
+// runDaemonCommand crawls every --crawl-interval and runs the integrity
+// sweep in the background until it is stopped by SIGTERM, Ctrl+C or
+// `daemon stop`; a crawl in progress then defers its remaining work to the
+// next start. `daemon status`, `daemon stop` and `daemon unit` manage it,
+// and on Windows `daemon install` and `daemon uninstall` register it as a
+// service.
+func runDaemonCommand(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "status":
+			runDaemonStatusCommand()
+		case "stop":
+			runDaemonStopCommand()
+		case "unit":
+			runDaemonUnitCommand()
+		case "install":
+			installDaemonService()
+		case "uninstall":
+			uninstallDaemonService()
+		default:
+			fatalConfig("usage: daemon [status | stop | unit | install | uninstall]")
+		}
+		return
+	}
+	if runAsService(runDaemon) { // Started by the Windows service control manager
+		return
+	}
+	runDaemon()
+}
+
+// runDaemon is the daemon loop; it returns once the daemon has stopped.
+func runDaemon() {
+	stopped := watchServiceStop()
+	status := &daemonStatus{PID: os.Getpid(), StartedAt: time.Now().UTC()}
+	status.update(func() { status.State = daemonStarting })
+	go status.beat()
+	go func() {
+		<-stopped
+		status.update(func() {
+			if status.State != daemonStopped { // Unless the loop got there first
+				status.State = daemonStopping
+			}
+		})
+	}()
+	go runIntegritySweep(loadSweepState())
+	notifyService("READY=1")
+	keepServiceAlive()
+	for !stopRequested.Load() {
+		wait := crawlInterval
+		if lockPass("daemon") { // Only while crawling, so scheduled runs and other writers get their turn
+			resetRunState() // Counters and budgets are per crawl
+			status.update(func() { status.State = daemonCrawling })
+			report := runCrawl()
+			unlockPass()
+			status.update(func() { status.LastCrawlAt, status.LastCrawlDocs = time.Now().UTC(), report.Downloaded })
+		} else {
+			wait = min(wait, time.Minute) // Another writer has the folders, try again soon
+		}
+		status.update(func() { status.State, status.NextCrawlAt = daemonIdle, time.Now().UTC().Add(wait) })
+		if stopRequested.Load() {
+			break
+		}
+		log.Printf("daemon: next crawl in %s", wait)
+		select {
+		case <-stopped:
+		case <-time.After(wait):
+		}
+	}
+	status.update(func() { status.State = daemonStopped })
+	log.Println("daemon: stopped")
+}
+
+// resetRunState clears the per-run counters, budgets and change lists so
+// every crawl of a long-running process reports only its own work.
+func resetRunState() {
+	runStartedAt = time.Now()
+	for _, counter := range []interface{ Store(int64) }{&queriesSearched, &linksDiscovered, &documentsSaved, &documentsSkipped, &documentBytesSaved, &requestsUsed, &bytesUsed, &deferredRequests, &rateLimitPauses} {
+		counter.Store(0)
+	}
+	budgetExhaustion = sync.Once{}
+	quotaExhausted.Store(false)
+	failuresMutex.Lock()
+	failures = nil
+	failuresMutex.Unlock()
+	documentChangesMutex.Lock()
+	newDocuments, updatedDocuments = nil, nil
+	documentChangesMutex.Unlock()
+	resetTraffic()
+}
+
+// runIntegritySweep checks one document at a time, pacing itself so the
+// whole library is visited once per --sweep-period, and sends a digest of
+// the findings every --digest-interval.
+func runIntegritySweep(state *sweepState) {
+	limiter := newRateLimiter(sweepBandwidth) // Keeps the sweep from competing with crawls for disk
+	for {
+		documents, err := loadManifest(manifestFile) // Re-read, crawls add documents
+		var entries []manifestEntry
+		if err == nil {
+			entries = documents.currentEntries()
+		}
+		pause := sweepPeriod // Nothing to check: look again later
+		if len(entries) > 0 {
+			if state.Cursor >= len(entries) { // Start the next pass
+				state.Cursor = 0
+			}
+			sweepEntry(state, entries[state.Cursor], limiter)
+			state.Cursor++
+			pause = sweepPeriod / time.Duration(len(entries))
+		}
+		if time.Since(state.DigestSentAt) >= digestInterval {
+			sendIntegrityDigest(state)
+		}
+		state.save()
+		time.Sleep(max(pause, time.Second))
+	}
+}
+
+// sweepEntry checks one document on disk and against its source.
+func sweepEntry(state *sweepState, entry manifestEntry, limiter *rateLimiter) {
+	finding := integrityFinding{File: entry.File, URL: entry.URL, FoundAt: time.Now().UTC()}
+	file, err := os.Open(entry.localPath())
+	if err != nil {
+		finding.Kind, finding.Detail = findingMissing, err.Error()
+		state.addFinding(finding)
+		return
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, throttledReader{reader: file, limiters: []*rateLimiter{limiter}})
+	file.Close()
+	if sum := hex.EncodeToString(hash.Sum(nil)); err == nil && sum != entry.SHA256 {
+		finding.Kind, finding.Detail = findingHashMismatch, "file "+sum+", manifest "+entry.SHA256
+		state.addFinding(finding)
+	}
+	if entry.Source == manualSource { // Uploads have no source to check
+		return
+	}
+	resp, err := fetchURL(http.MethodHead, entry.URL)
+	if err != nil { // Transient, try again next pass
+		return
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		finding.Kind, finding.Detail = findingDeadLink, resp.Status
+		state.addFinding(finding)
+	case resp.StatusCode == http.StatusOK:
+		if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && entry.RevisionDate != "" {
+			if revision := lastModified.UTC().Format(time.DateOnly); revision > entry.RevisionDate {
+				finding.Kind, finding.Detail = findingStaleRevision, "upstream "+revision+", stored "+entry.RevisionDate
+				state.addFinding(finding)
+			}
+		}
+	}
+}
+
+// sendIntegrityDigest sends the findings collected since the last digest to
+// the -notify channels and starts a new collection.
+func sendIntegrityDigest(state *sweepState) {
+	report := runReport{
+		Kind:              reportKindDigest,
+		StartedAt:         state.DigestSentAt.UTC(),
+		FinishedAt:        time.Now().UTC(),
+		Duration:          time.Since(state.DigestSentAt).Round(time.Second),
+		IntegrityFindings: append([]integrityFinding{}, state.Findings...),
+	}
+	log.Printf("integrity digest: %d findings since %s", len(report.IntegrityFindings), report.StartedAt.Format(time.DateOnly))
+	sendNotifications(report)
+	state.Findings, state.DigestSentAt = nil, time.Now()
+}
@@ -0,0 +1,120 @@
+package main // Define the main package
+
+import (
+	"io/fs"         // For walking the storage folders
+	"log"           // For logging messages and errors
+	"os"            // For removing files
+	"path/filepath" // For walking the storage folders
+	"regexp"        // For recognizing leftover temp files
+	"strings"       // For checking empty result files
+)
+
+// tempFileRegex matches the temporary files written by writeFileAtomically.
+var tempFileRegex = regexp.MustCompile(`^\..+\.[0-9]+\.tmp$`)
+
+// runDBCommand implements `db vacuum` and `db migrate-results`. There is no
+// database behind them: both work on the files under -assets-dir and
+// -pdf-dir (saved search results, the seen-URL index, the handler link
+// cache and the PDFs).
+func runDBCommand(args []string) {
+	if len(args) == 0 {
+		fatalConfig("usage: db vacuum | db migrate-results")
+	}
+	switch args[0] {
+	case "vacuum":
+		vacuumStore()
+	case "migrate-results":
+		migrateSearchResults()
+	default:
+		fatalConfig("usage: db vacuum | db migrate-results")
+	}
+}
+
+// vacuumStore compacts the on-disk store: it deletes temp files left behind
+// by interrupted writes, drops empty search result files so those queries
+// are searched again, prunes cached handler resolutions that no saved search
+// result refers to any more, removes objects of the cas layout that no
+// document needs, and reports the size before and after.
+func vacuumStore() {
+	before := directorySize(givenFolder) + directorySize(outputDir) // Size before compaction
+	removedTemp := 0                                                // Interrupted writes removed
+	for _, directory := range []string{givenFolder, outputDir} {
+		filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && tempFileRegex.MatchString(entry.Name()) {
+				if os.Remove(path) == nil { // Delete the leftover
+					removedTemp++
+				}
+			}
+			return nil // Keep walking past unreadable entries
+		})
+	}
+	removedEmpty := 0 // Empty search results removed
+	for _, query := range generateQueries() {
+		content, err := os.ReadFile(queryResultPath(query)) // Saved result file for the query
+		if err != nil {
+			continue
+		}
+		result, structured := structuredResult(content)
+		empty := structured && len(result.Links) == 0 && result.searched()
+		if empty || strings.TrimSpace(string(content)) == "" { // Also raw dumps written before results were structured
+			if os.Remove(queryResultPath(query)) == nil { // The query will be searched again
+				removedEmpty++
+			}
+		}
+	}
+	referenced := make(map[string]bool) // Handler links still present in saved results
+	for _, link := range discoveredLinks() {
+		referenced[link] = true
+	}
+	resolvedLinksMutex.Lock()
+	loadResolvedLinksLocked() // Make sure the cache is loaded
+	prunedLinks := 0          // Orphaned cache rows removed
+	for link := range resolvedLinks {
+		if !referenced[link] {
+			delete(resolvedLinks, link)
+			prunedLinks++
+		}
+	}
+	reachable := make(map[string]bool) // PDFs the remaining handler links lead to
+	for _, finalURL := range resolvedLinks {
+		reachable[finalURL] = true
+	}
+	for finalURL := range dispositionNames {
+		if !reachable[finalURL] { // Its name can no longer be needed
+			delete(dispositionNames, finalURL)
+			prunedLinks++
+		}
+	}
+	resolvedLinksMutex.Unlock()
+	if prunedLinks > 0 {
+		saveResolvedLinks() // Rewrite the compacted cache
+	}
+	prunedSeen := pruneSeenURLs() // Seen links whose file is gone
+	if prunedSeen > 0 {
+		saveSeenURLs() // Rewrite the compacted index
+	}
+	removedObjects := removeOrphanedObjects()                      // Content no document refers to
+	after := directorySize(givenFolder) + directorySize(outputDir) // Size after compaction
+	log.Printf("vacuum removed %d temp files, %d empty search results, %d orphaned cached links, %d stale seen links and %d orphaned objects", removedTemp, removedEmpty, prunedLinks, prunedSeen, removedObjects)
+	log.Printf("store size: %d bytes before, %d bytes after (%d bytes reclaimed)", before, after, before-after)
+}
+
+// directorySize returns the total size of the regular files under a
+// directory. Objects of the cas layout are links to the readable files and
+// are not counted twice.
+func directorySize(directory string) int64 {
+	var total int64 // Running total
+	filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && path == objectStoreDir() {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return nil // Skip folders and unreadable entries
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size() // Add the file size
+		}
+		return nil
+	})
+	return total
+}
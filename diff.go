@@ -0,0 +1,127 @@
+package main // Define the main package
+
+import (
+	"encoding/json"  // For telling snapshots from manifests
+	"fmt"            // For printing the differences
+	"log"            // For usage errors
+	"os"             // For reading the files and exit codes
+	"strconv"        // For snapshot numbers
+	"text/tabwriter" // For aligned console output
+)
+
+// manifestRevision is a document whose content changed between two manifests.
+type manifestRevision struct {
+	Title           string `json:"title,omitempty"`             // Product name
+	File            string `json:"file"`                        // Filename inside the PDF folder
+	URL             string `json:"url"`                         // Source URL
+	OldRevisionDate string `json:"old_revision_date,omitempty"` // Revision date in the older manifest
+	NewRevisionDate string `json:"new_revision_date,omitempty"` // Revision date in the newer manifest
+	OldSHA256       string `json:"old_sha256"`                  // Hash in the older manifest
+	NewSHA256       string `json:"new_sha256"`                  // Hash in the newer manifest
+}
+
+// manifestDiff lists how the catalog changed from one manifest to another.
+type manifestDiff struct {
+	Added   []documentChange   `json:"added"`   // Only in the newer manifest
+	Removed []documentChange   `json:"removed"` // Only in the older manifest
+	Revised []manifestRevision `json:"revised"` // In both, with different content
+}
+
+// diffManifests compares the current documents of two manifests by URL.
+func diffManifests(older, newer []manifestEntry) manifestDiff {
+	drift := compareWithApproved(newer, older) // Added and removed are the same question
+	difference := manifestDiff{Added: drift.Added, Removed: drift.Removed, Revised: []manifestRevision{}}
+	olderByURL := make(map[string]manifestEntry, len(older))
+	for _, entry := range older {
+		olderByURL[entry.URL] = entry
+	}
+	for _, entry := range newer {
+		if previous, known := olderByURL[entry.URL]; known && previous.SHA256 != entry.SHA256 {
+			difference.Revised = append(difference.Revised, manifestRevision{
+				Title:           entry.Title,
+				File:            entry.File,
+				URL:             entry.URL,
+				OldRevisionDate: previous.RevisionDate,
+				NewRevisionDate: entry.RevisionDate,
+				OldSHA256:       previous.SHA256,
+				NewSHA256:       entry.SHA256,
+			})
+		}
+	}
+	return difference
+}
+
+// loadDocuments reads the current documents of a manifest file, a snapshot
+// file, or the snapshot with the given sequence number.
+func loadDocuments(source string) ([]manifestEntry, error) {
+	if sequence, err := strconv.Atoi(source); err == nil { // diff 12 means snapshot 12
+		source = snapshotPath(sequence)
+	}
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	var frozen snapshot
+	if err := json.Unmarshal(content, &frozen); err == nil && frozen.Documents != nil {
+		return currentOnly(frozen.Documents), nil
+	}
+	documents, err := loadManifest(source)
+	if err != nil {
+		return nil, err
+	}
+	return documents.currentEntries(), nil
+}
+
+// currentOnly drops pruned entries, like manifest.currentEntries.
+func currentOnly(entries []manifestEntry) []manifestEntry {
+	var current []manifestEntry
+	for _, entry := range entries {
+		if entry.Pruned == "" {
+			current = append(current, entry)
+		}
+	}
+	return current
+}
+
+// runDiffCommand handles "diff <older> [newer]": it compares two manifests
+// or snapshots, the newer defaulting to the current manifest, and exits 1
+// when the catalog changed, like drift.
+func runDiffCommand(args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		log.Printf("usage: diff <older manifest, snapshot file or snapshot number> [newer, default -manifest]")
+		exit(driftUsage)
+	}
+	newerSource := manifestFile
+	if len(args) == 2 {
+		newerSource = args[1]
+	}
+	older, err := loadDocuments(args[0])
+	if err != nil {
+		fatalf("failed to read %s: %v", args[0], err)
+	}
+	newer, err := loadDocuments(newerSource)
+	if err != nil {
+		fatalf("failed to read %s: %v", newerSource, err)
+	}
+	difference := diffManifests(older, newer)
+	if jsonOutput() {
+		printJSON(difference)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "CHANGE\tFILE\tTITLE\tREVISION\tURL")
+		for _, change := range difference.Added {
+			fmt.Fprintf(writer, "added\t%s\t%s\t\t%s\n", change.File, change.Title, change.URL)
+		}
+		for _, change := range difference.Removed {
+			fmt.Fprintf(writer, "removed\t%s\t%s\t\t%s\n", change.File, change.Title, change.URL)
+		}
+		for _, revision := range difference.Revised {
+			fmt.Fprintf(writer, "revised\t%s\t%s\t%s → %s\t%s\n", revision.File, revision.Title, revision.OldRevisionDate, revision.NewRevisionDate, revision.URL)
+		}
+		writer.Flush() // Print the table
+	}
+	log.Printf("%d added, %d removed, %d revised", len(difference.Added), len(difference.Removed), len(difference.Revised))
+	if len(difference.Added)+len(difference.Removed)+len(difference.Revised) > 0 {
+		exit(driftFound)
+	}
+}
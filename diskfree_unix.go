@@ -0,0 +1,17 @@
+//go:build unix
+
+package main // Define the main package
+
+import (
+	"syscall" // For querying filesystem statistics
+)
+
+// freeDiskSpace returns the number of bytes available to this user on the
+// filesystem holding path.
+func freeDiskSpace(path string) (uint64, error) {
+	var stats syscall.Statfs_t // Filesystem statistics
+	if err := syscall.Statfs(path, &stats); err != nil {
+		return 0, err
+	}
+	return uint64(stats.Bavail) * uint64(stats.Bsize), nil // Blocks available to unprivileged users
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package main // Define the main package
+
+import (
+	"syscall" // For calling into kernel32
+	"unsafe"  // For passing pointers to the Windows API
+)
+
+// getDiskFreeSpaceEx is kernel32's GetDiskFreeSpaceExW.
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// freeDiskSpace returns the number of bytes available to this user on the
+// volume holding path, including UNC shares.
+func freeDiskSpace(path string) (uint64, error) {
+	pathPointer, err := syscall.UTF16PtrFromString(path) // Windows wants UTF-16
+	if err != nil {
+		return 0, err
+	}
+	var availableBytes uint64 // Bytes available to the calling user
+	result, _, callErr := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPointer)), uintptr(unsafe.Pointer(&availableBytes)), 0, 0)
+	if result == 0 {
+		return 0, callErr
+	}
+	return availableBytes, nil
+}
@@ -0,0 +1,151 @@
+package main // Define the main package
+
+import (
+	"flag"        // For command-line flag parsing
+	"fmt"         // For size errors and failure details
+	"log"         // For logging messages and errors
+	"net/http"    // For HEAD probes
+	"strings"     // For telling sizes from rates
+	"sync/atomic" // For totals shared between workers
+)
+
+var (
+	maxTotalBytes  int64              // Quota on the total size of the PDF folder (0 = unlimited)
+	maxFileSize    = int64(256 << 20) // Largest single download kept (0 = unlimited)
+	minFreeSpace   int64              // Free bytes the PDF folder's filesystem must keep (0 = no floor)
+	spaceCheck     bool               // Probe Content-Length before downloading
+	storedAtStart  int64              // Size of the PDF folder when downloads started
+	quotaExhausted atomic.Bool        // Set once the quota stops further downloads
+	quotaReserved  atomic.Int64       // Bytes being stored right now, not yet in documentBytesSaved
+)
+
+func init() {
+	flag.Int64Var(&maxTotalBytes, "max-total-bytes", 0, "quota on the total size of the PDF folder in bytes (0 = unlimited)")              // Register the quota flag
+	flag.BoolVar(&spaceCheck, "space-check", false, "probe Content-Length of pending downloads and refuse to start if they would not fit") // Register the pre-check flag
+	flag.Func("max-file-size", "skip downloads bigger than this, e.g. 100MB (0 = no limit, default 256MiB)", func(value string) (err error) {
+		maxFileSize, err = parseByteSize(value) // Register the file size flag
+		return err
+	})
+	flag.Func("min-free-space", "stop downloading once the PDF folder's filesystem would have less free space than this, e.g. 1GB (default no floor)", func(value string) (err error) {
+		minFreeSpace, err = parseByteSize(value) // Register the free space flag
+		return err
+	})
+}
+
+// parseByteSize parses sizes such as "256MiB", "1GB" or "100000".
+func parseByteSize(value string) (int64, error) {
+	if strings.HasSuffix(value, "/s") { // Rates belong to the bandwidth flags
+		return 0, fmt.Errorf("invalid size %q (examples: 256MiB, 1GB)", value)
+	}
+	size, err := parseByteRate(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q (examples: 256MiB, 1GB)", value)
+	}
+	return size, nil
+}
+
+// fileSizeAllowed reports whether a download of size bytes may be kept.
+func fileSizeAllowed(size int64) bool {
+	return maxFileSize <= 0 || size <= maxFileSize
+}
+
+// freeSpaceAllows reports whether a download of size bytes (0 when not
+// known) leaves --min-free-space free. Once it would not, every further
+// download is stopped, like the quota does, and the run reports a failure.
+func freeSpaceAllows(link string, size int64) bool {
+	if minFreeSpace <= 0 { // No floor configured
+		return true
+	}
+	free, err := freeDiskSpace(outputDir)
+	if err != nil { // Unknown, do not stop the run over it
+		return true
+	}
+	if int64(free)-max(size, 0) >= minFreeSpace {
+		return true
+	}
+	if quotaExhausted.CompareAndSwap(false, true) { // Report the stop once
+		log.Printf("stopping downloads: %d bytes free on %s, below the %d byte --min-free-space", free, outputDir, minFreeSpace)
+		recordFailure(failureKindDownload, link, reasonLowDiskSpace, fmt.Sprintf("%d bytes free, %d needed", free, minFreeSpace))
+	}
+	return false
+}
+
+// estimateDownloadSize sends a HEAD request for every link, unless the
+// pre-flight already did, and sums the reported Content-Length. Links
+// without a length are counted as unknown.
+func estimateDownloadSize(links []string) (total int64, unknown int64) {
+	var totalBytes, unknownLinks atomic.Int64 // Shared between probe workers
+	runWorkerPool(links, searchConcurrency, func(link string) {
+		probe := probeLink(link)
+		if probe.Status != http.StatusOK || probe.Length < 0 {
+			unknownLinks.Add(1) // Size cannot be known ahead of time
+			return
+		}
+		totalBytes.Add(probe.Length)
+	})
+	return totalBytes.Load(), unknownLinks.Load()
+}
+
+// checkDiskSpace decides whether the pending downloads may start. With
+// --space-check it estimates their size and refuses to start if they would
+// not fit on disk or would exceed --max-total-bytes.
+func checkDiskSpace(links []string) bool {
+	storedAtStart = directorySize(outputDir) // Existing library size for the quota
+	if maxTotalBytes > 0 && storedAtStart >= maxTotalBytes {
+		log.Printf("PDF folder already holds %d bytes, at or over the %d byte quota; not downloading", storedAtStart, maxTotalBytes)
+		return false
+	}
+	if !spaceCheck { // Pre-check is opt-in
+		return true
+	}
+	var pending []string // Links without a local copy
+	for _, link := range links {
+		if localPath := localPDFPath(link); localPath == "" || !fileExists(localPath) {
+			pending = append(pending, link)
+		}
+	}
+	if len(pending) == 0 { // Nothing to download
+		return true
+	}
+	estimated, unknown := estimateDownloadSize(pending) // Expected download size
+	log.Printf("pending downloads: %d links, about %d bytes (%d without a known size)", len(pending), estimated, unknown)
+	if free, err := freeDiskSpace(outputDir); err != nil {
+		log.Printf("could not determine free disk space for %s: %v", outputDir, err)
+	} else if uint64(estimated) > free {
+		log.Printf("refusing to start: downloads need about %d bytes but only %d bytes are free on %s", estimated, free, outputDir)
+		return false
+	}
+	if maxTotalBytes > 0 && storedAtStart+estimated > maxTotalBytes {
+		log.Printf("refusing to start: %d stored + %d pending bytes would exceed the %d byte quota", storedAtStart, estimated, maxTotalBytes)
+		return false
+	}
+	return true
+}
+
+// reserveQuota reserves size bytes of the quota for a file about to be
+// stored, and stops all further downloads once they would not fit. Callers
+// that got the reservation release it with releaseQuota once the bytes are
+// counted in documentBytesSaved or the file was not kept.
+func reserveQuota(size int64) bool {
+	if maxTotalBytes <= 0 { // No quota configured
+		quotaReserved.Add(size)
+		return true
+	}
+	for {
+		reserved := quotaReserved.Load()
+		if storedAtStart+documentBytesSaved.Load()+reserved+size > maxTotalBytes {
+			if quotaExhausted.CompareAndSwap(false, true) { // Log the stop once
+				log.Printf("stopping downloads: the %d byte quota has been reached", maxTotalBytes)
+			}
+			return false
+		}
+		if quotaReserved.CompareAndSwap(reserved, reserved+size) { // Otherwise another worker reserved first
+			return true
+		}
+	}
+}
+
+// releaseQuota returns bytes reserved by reserveQuota.
+func releaseQuota(size int64) {
+	quotaReserved.Add(-size)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReserveQuotaConcurrent(t *testing.T) {
+	setGlobal(t, &maxTotalBytes, 1000)
+	setGlobal(t, &storedAtStart, 100)
+	resetRunState()
+	t.Cleanup(resetRunState)
+	var granted sync.WaitGroup
+	var mutex sync.Mutex
+	reserved := 0
+	for range 50 { // Only 9 files of 100 bytes fit next to the 100 stored
+		granted.Add(1)
+		go func() {
+			defer granted.Done()
+			if reserveQuota(100) {
+				mutex.Lock()
+				reserved++
+				mutex.Unlock()
+			}
+		}()
+	}
+	granted.Wait()
+	if reserved != 9 {
+		t.Errorf("reserved %d files, want 9", reserved)
+	}
+	releaseQuota(100) // One download failed
+	if !reserveQuota(100) {
+		t.Error("released bytes were not available again")
+	}
+	for range 9 {
+		releaseQuota(100)
+	}
+}
@@ -0,0 +1,180 @@
+package main // Define the main package
+
+import (
+	"bufio"          // For reading the request log line by line
+	"encoding/json"  // For the request log format
+	"errors"         // For detecting a missing log
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the report
+	"log"            // For logging messages and errors
+	"os"             // For file access
+	"os/user"        // For recording who asked
+	"sort"           // For ordering the report
+	"strings"        // For normalizing product names
+	"text/tabwriter" // For aligned console output
+	"time"           // For request timestamps
+)
+
+// Where a document request came from
+const (
+	requestSourceManual = "manual" // Recorded with request add
+	requestSourceLookup = "lookup" // A has/path-of/hash-of lookup found nothing
+)
+
+var requestsFile string // Append-only log of requested products
+
+func init() {
+	flag.StringVar(&requestsFile, "requests-file", "document-requests.jsonl", "log of products users asked for that have no SDS in the mirror") // Register the request log flag
+}
+
+// documentRequest is one time somebody looked for a product without an SDS.
+type documentRequest struct {
+	Product     string    `json:"product"`        // Product name as asked for
+	RequestedBy string    `json:"requested_by"`   // Login of the user who asked
+	Source      string    `json:"source"`         // requestSourceManual or requestSourceLookup
+	Note        string    `json:"note,omitempty"` // Free-text context for the safety team
+	RequestedAt time.Time `json:"requested_at"`   // When it was asked for
+}
+
+// requestSummary aggregates the requests for one product.
+type requestSummary struct {
+	Product    string    `json:"product"`     // Product name as first asked for
+	Requests   int       `json:"requests"`    // How often it was asked for
+	Requesters []string  `json:"requesters"`  // Distinct users who asked
+	FirstAsked time.Time `json:"first_asked"` // Oldest request
+	LastAsked  time.Time `json:"last_asked"`  // Newest request
+	Available  bool      `json:"available"`   // The mirror has an SDS for it by now
+}
+
+// recordDocumentRequest appends a request to the log. Failures are only
+// logged, so a read-only log never breaks a lookup.
+func recordDocumentRequest(product, source, note string) {
+	requestedBy := "unknown" // Fallback when the user cannot be determined
+	if current, err := user.Current(); err == nil {
+		requestedBy = current.Username
+	}
+	content, err := json.Marshal(documentRequest{Product: product, RequestedBy: requestedBy, Source: source, Note: note, RequestedAt: time.Now().UTC()})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	file, err := os.OpenFile(requestsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // One line per request
+	if err != nil {
+		log.Printf("failed to record request for %q: %v", product, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		log.Printf("failed to record request for %q: %v", product, err)
+	}
+}
+
+// loadDocumentRequests reads the request log, skipping malformed lines.
+func loadDocumentRequests() ([]documentRequest, error) {
+	file, err := os.Open(requestsFile) // Read the log
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil // Nobody asked for anything yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var requests []documentRequest
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var request documentRequest
+		if json.Unmarshal(scanner.Bytes(), &request) == nil && request.Product != "" {
+			requests = append(requests, request)
+		}
+	}
+	return requests, scanner.Err()
+}
+
+// summarizeDocumentRequests groups requests by product name (ignoring case
+// and surrounding space), most requested first.
+func summarizeDocumentRequests(requests []documentRequest) []requestSummary {
+	byProduct := make(map[string]*requestSummary) // Normalized name → summary
+	var order []string                            // Normalized names in first-seen order
+	for _, request := range requests {
+		key := strings.ToLower(strings.TrimSpace(request.Product))
+		summary, known := byProduct[key]
+		if !known {
+			summary = &requestSummary{Product: strings.TrimSpace(request.Product), FirstAsked: request.RequestedAt}
+			byProduct[key] = summary
+			order = append(order, key)
+		}
+		summary.Requests++
+		if request.RequestedAt.After(summary.LastAsked) {
+			summary.LastAsked = request.RequestedAt
+		}
+		if request.RequestedAt.Before(summary.FirstAsked) {
+			summary.FirstAsked = request.RequestedAt
+		}
+		if !containsString(summary.Requesters, request.RequestedBy) {
+			summary.Requesters = append(summary.Requesters, request.RequestedBy)
+		}
+	}
+	entries := mustLoadManifest().currentEntries() // What the mirror holds now
+	summaries := make([]requestSummary, 0, len(order))
+	for _, key := range order {
+		summary := byProduct[key]
+		summary.Available = len(matchProductDocuments(entries, summary.Product)) > 0 // Resolved since it was asked for
+		summaries = append(summaries, *summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Requests > summaries[j].Requests })
+	return summaries
+}
+
+// Report whether a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+// runRequestCommand handles "request add <product> [note]" and
+// "request report [all]". The report lists products still missing from the
+// mirror unless "all" is given.
+func runRequestCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: request add <product> [note] | request report [all]")
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			fatalln("usage: request add <product> [note]")
+		}
+		if len(findProductDocuments(args[1])) > 0 {
+			log.Printf("%q already has an SDS in the mirror; recording the request anyway", args[1])
+		}
+		recordDocumentRequest(args[1], requestSourceManual, strings.Join(args[2:], " "))
+		log.Printf("recorded request for %q in %s", args[1], requestsFile)
+	case "report":
+		requests, err := loadDocumentRequests()
+		if err != nil {
+			fatalln(err)
+		}
+		summaries := []requestSummary{} // Rows to print
+		for _, summary := range summarizeDocumentRequests(requests) {
+			if !summary.Available || (len(args) > 1 && args[1] == "all") {
+				summaries = append(summaries, summary)
+			}
+		}
+		if jsonOutput() {
+			printJSON(summaries)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "PRODUCT\tREQUESTS\tREQUESTERS\tFIRST\tLAST\tAVAILABLE")
+		for _, summary := range summaries {
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%t\n", summary.Product, summary.Requests, strings.Join(summary.Requesters, ","),
+				summary.FirstAsked.Format(time.DateOnly), summary.LastAsked.Format(time.DateOnly), summary.Available)
+		}
+		writer.Flush() // Print the table
+	default:
+		fatalf("unknown request command %q", args[0])
+	}
+}
@@ -0,0 +1,103 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For check details
+	"io"             // For printing into the support bundle
+	"net/http"       // For probing the origin
+	"os"             // For write probes and the exit status
+	"path/filepath"  // For probe file names
+	"runtime"        // For the platform line
+	"text/tabwriter" // For aligned columns
+)
+
+// Results of a doctor check
+const (
+	doctorOK   = "ok"   // Nothing to do
+	doctorWarn = "warn" // Works, but worth a look
+	doctorFail = "fail" // Runs will not work properly
+)
+
+// doctorCheck is the outcome of one health check.
+type doctorCheck struct {
+	Check  string `json:"check"`  // What was checked
+	Status string `json:"status"` // doctorOK, doctorWarn or doctorFail
+	Detail string `json:"detail"` // What was found
+}
+
+// runDoctorChecks checks the setup a run depends on: writable folders, free
+// space, a readable manifest and index, and a reachable origin.
+func runDoctorChecks() []doctorCheck {
+	checks := []doctorCheck{{"platform", doctorOK, fmt.Sprintf("%s/%s, %s", runtime.GOOS, runtime.GOARCH, runtime.Version())}}
+	for _, folder := range []string{givenFolder, outputDir} {
+		checks = append(checks, checkWritable(folder))
+	}
+	if free, err := freeDiskSpace(outputDir); err != nil {
+		checks = append(checks, doctorCheck{"free space", doctorWarn, err.Error()})
+	} else if free < 1<<30 { // Less than a GiB left
+		checks = append(checks, doctorCheck{"free space", doctorWarn, fmt.Sprintf("%d bytes free on %s", free, outputDir)})
+	} else {
+		checks = append(checks, doctorCheck{"free space", doctorOK, fmt.Sprintf("%d bytes free on %s", free, outputDir)})
+	}
+	if documents, err := loadManifest(manifestFile); err != nil {
+		checks = append(checks, doctorCheck{"manifest", doctorFail, err.Error()})
+	} else {
+		checks = append(checks, doctorCheck{"manifest", doctorOK, fmt.Sprintf("%d documents in %s", len(documents.currentEntries()), manifestFile)})
+	}
+	if index, err := loadTermIndex(); err != nil {
+		checks = append(checks, doctorCheck{"index", doctorWarn, err.Error()})
+	} else {
+		checks = append(checks, doctorCheck{"index", doctorOK, fmt.Sprintf("%d documents indexed", len(index.Documents))})
+	}
+	checks = append(checks, checkOrigin())
+	return checks
+}
+
+// checkWritable creates and removes a probe file in folder.
+func checkWritable(folder string) doctorCheck {
+	check := "writable " + folder
+	probe, err := os.CreateTemp(folder, ".doctor-*")
+	if err != nil {
+		return doctorCheck{check, doctorFail, err.Error()}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return doctorCheck{check, doctorOK, filepath.Dir(probe.Name())}
+}
+
+// checkOrigin sends a HEAD request to -origin-url.
+func checkOrigin() doctorCheck {
+	resp, err := fetchURL(http.MethodHead, originURL)
+	if err != nil {
+		return doctorCheck{"origin", doctorFail, err.Error()}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return doctorCheck{"origin", doctorWarn, fmt.Sprintf("%s answered %s", sanitizeURL(originURL), resp.Status)}
+	}
+	return doctorCheck{"origin", doctorOK, fmt.Sprintf("%s answered %s", sanitizeURL(originURL), resp.Status)}
+}
+
+// runDoctorCommand prints the checks and exits with status 1 if any failed.
+func runDoctorCommand() {
+	checks := runDoctorChecks()
+	if jsonOutput() {
+		printJSON(checks)
+	} else {
+		writeDoctorTable(os.Stdout, checks)
+	}
+	for _, check := range checks {
+		if check.Status == doctorFail {
+			exit(1) // Let scripts notice a broken setup
+		}
+	}
+}
+
+// writeDoctorTable prints the checks as a table.
+func writeDoctorTable(output io.Writer, checks []doctorCheck) {
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "CHECK\tSTATUS\tDETAIL")
+	for _, check := range checks {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", check.Check, check.Status, check.Detail)
+	}
+	writer.Flush() // Print the table
+}
@@ -0,0 +1,176 @@
+package main // Define the main package
+
+import (
+	"flag"    // For command-line flag parsing
+	"fmt"     // For rejecting unknown types
+	"strings" // For parsing the flags and query targets
+	"sync"    // For guarding the link table across workers
+
+	"github.com/Strong-Foundation/hillyard-com-documentation/pkg/hillyard" // For the search pages of each type
+)
+
+// documentType is a class of document the site publishes, with the search
+// that lists it and the subfolder of the assets and PDF folders it is kept
+// in. Safety data sheets stay at the top level as they always have.
+type documentType struct {
+	Name       string // Name used in --doc-types, query targets and titled filenames
+	SearchPath string // Search results page on the origin
+	Folder     string // Subfolder for its results and PDFs ("" = top level)
+}
+
+// documentTypes are the document classes that can be mirrored. The paths
+// follow the layout of the SDS search and can be corrected with
+// --doc-type-path if the site moves them.
+var documentTypes = []documentType{
+	{Name: string(hillyard.SDS), SearchPath: hillyard.SDS.SearchPath()},
+	{Name: string(hillyard.TDS), SearchPath: hillyard.TDS.SearchPath(), Folder: "tds"},
+	{Name: string(hillyard.Literature), SearchPath: hillyard.Literature.SearchPath(), Folder: "literature"},
+	{Name: string(hillyard.Label), SearchPath: hillyard.Label.SearchPath(), Folder: "labels"},
+}
+
+var (
+	selectedDocTypes  = []string{"sds"}         // Document classes mirrored by this run
+	linkDocTypes      = make(map[string]string) // Document URL → name of the type whose search found it
+	linkDocTypesMutex sync.Mutex                // Guards linkDocTypes
+)
+
+func init() {
+	flag.Func("doc-types", "comma-separated document classes to mirror: sds, tds (technical data sheets), literature, label (default sds)", func(value string) error {
+		selectedDocTypes = nil
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if _, known := documentTypeByName(name); !known {
+				return fmt.Errorf("unknown document type %q", name)
+			}
+			selectedDocTypes = append(selectedDocTypes, name)
+		}
+		return nil
+	}) // Register the document type flag
+	flag.Func("doc-type-path", "override the search path of a document class as type=/path, e.g. tds=/tds/search/results; may be repeated", func(value string) error {
+		name, searchPath, found := strings.Cut(value, "=")
+		if !found || !strings.HasPrefix(searchPath, "/") {
+			return fmt.Errorf("expected type=/path, got %q", value)
+		}
+		for index := range documentTypes {
+			if documentTypes[index].Name == name {
+				documentTypes[index].SearchPath = searchPath
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown document type %q", name)
+	}) // Register the search path flag
+}
+
+// documentTypeByName looks a document type up by name.
+func documentTypeByName(name string) (documentType, bool) {
+	for _, docType := range documentTypes {
+		if docType.Name == name {
+			return docType, true
+		}
+	}
+	return documentType{}, false
+}
+
+// searchURL is the search results page of the type on the origin.
+func (docType documentType) searchURL() string {
+	return strings.TrimSuffix(originURL, "/") + docType.SearchPath
+}
+
+// entryName is the type as recorded in the manifest, where SDS are unmarked.
+func (docType documentType) entryName() string {
+	if docType.Folder == "" {
+		return ""
+	}
+	return docType.Name
+}
+
+// entryDocType returns the document type a manifest entry was stored as.
+func entryDocType(entry manifestEntry) documentType {
+	if docType, known := documentTypeByName(entry.DocType); known {
+		return docType
+	}
+	return documentTypes[0]
+}
+
+// inFolder places a filename in the type's subfolder of the PDF folder.
+func (docType documentType) inFolder(name string) string {
+	if docType.Folder == "" {
+		return name
+	}
+	return docType.Folder + "/" + name
+}
+
+// typedQueries repeats the queries for every selected document type. SDS
+// queries are plain; the others are prefixed with the type, e.g. "tds:ab",
+// so queue files and failure reports say which search they belong to.
+func typedQueries(queries []string) []string {
+	var targets []string
+	for _, name := range selectedDocTypes {
+		for _, query := range queries {
+			if name == "sds" {
+				targets = append(targets, query)
+			} else {
+				targets = append(targets, name+":"+query)
+			}
+		}
+	}
+	return targets
+}
+
+// splitQueryTarget separates the document type from a query target.
+func splitQueryTarget(target string) (documentType, string) {
+	for _, docType := range documentTypes[1:] { // Everything but the unprefixed SDS
+		if query, found := strings.CutPrefix(target, docType.Name+":"); found {
+			return docType, query
+		}
+	}
+	return documentTypes[0], target
+}
+
+// savedResultLinks returns the document links in a query's saved results
+// and remembers which document type they belong to.
+func savedResultLinks(target string) []string {
+	result, found := loadSearchResult(target)
+	if !found { // Not searched yet
+		return nil
+	}
+	rememberLinkTitles(result.Links) // Keep the titles for the manifest
+	var links []string
+	for _, link := range result.Links {
+		links = append(links, link.URL)
+	}
+	docType, _ := splitQueryTarget(target)
+	linkDocTypesMutex.Lock()
+	defer linkDocTypesMutex.Unlock()
+	for _, link := range links {
+		if known := linkDocTypes[link]; known == "" || docType.Name == "sds" { // A link listed as an SDS stays one
+			linkDocTypes[link] = docType.Name
+		}
+	}
+	return links
+}
+
+// linkDocType returns the type of a discovered link, SDS when unknown.
+func linkDocType(link string) documentType {
+	linkDocTypesMutex.Lock()
+	name := linkDocTypes[link]
+	linkDocTypesMutex.Unlock()
+	if docType, known := documentTypeByName(name); known {
+		return docType
+	}
+	return documentTypes[0]
+}
+
+// validLibraryName reports whether a name requested from the library is a
+// PDF at the top level or in a document type's subfolder, and nothing else.
+func validLibraryName(name string) bool {
+	folder, file, nested := strings.Cut(name, "/")
+	if !nested {
+		folder, file = "", name
+	}
+	known := folder == ""
+	for _, docType := range documentTypes {
+		known = known || (docType.Folder != "" && docType.Folder == folder)
+	}
+	return known && !strings.ContainsAny(file, `/\`) && !strings.HasPrefix(file, ".") && strings.HasSuffix(file, ".pdf")
+}
@@ -0,0 +1,150 @@
+package main // Define the main package
+
+import (
+	"bytes"           // For building the message
+	"encoding/base64" // For the attachment
+	"encoding/csv"    // For the change report
+	"errors"          // For missing configuration
+	"flag"            // For command-line flag parsing
+	"fmt"             // For message headers
+	"mime"            // For encoding the subject
+	"mime/multipart"  // For the attachment
+	"net"             // For splitting the server address
+	"net/smtp"        // For sending the mail
+	"net/textproto"   // For part headers
+	"strings"         // For the recipient list
+	"time"            // For the Date header
+)
+
+var (
+	smtpAddress  string // Mail server as host:port
+	smtpUsername string // Login for the mail server ("" = none)
+	smtpPassword string // Password for the mail server
+	emailFrom    string // Sender address
+	emailTo      string // Comma-separated recipients
+)
+
+func init() {
+	flag.StringVar(&smtpAddress, "smtp-addr", "", "mail server the email notification channel sends through, as host:port, e.g. smtp.example.com:587")         // Register the SMTP server flag
+	flag.StringVar(&smtpUsername, "smtp-username", "", "login for -smtp-addr; leave empty for a relay without authentication")                                 // Register the SMTP login flag
+	flag.StringVar(&smtpPassword, "smtp-password", "", "password for -smtp-username")                                                                          // Register the SMTP password flag
+	flag.StringVar(&emailFrom, "email-from", "", "sender address of notification emails")                                                                      // Register the sender flag
+	flag.StringVar(&emailTo, "email-to", "", "comma-separated recipients of notification emails, e.g. the EHS officers; the change report is attached as CSV") // Register the recipients flag
+}
+
+// emailNotifier mails the message with the new and updated documents
+// attached as CSV, for readers who live in their inbox.
+type emailNotifier struct {
+	recipients []string // Addresses to send to
+}
+
+// Build the email channel from its flags
+func newEmailNotifier() (Notifier, error) {
+	if smtpAddress == "" || emailFrom == "" || emailTo == "" {
+		return nil, errors.New("-smtp-addr, -email-from and -email-to are required")
+	}
+	var recipients []string
+	for _, address := range strings.Split(emailTo, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	return emailNotifier{recipients: recipients}, nil
+}
+
+// Name returns the channel name.
+func (emailNotifier) Name() string { return "email" }
+
+// Notify sends the message, upgrading to TLS when the server offers it.
+func (notifier emailNotifier) Notify(report runReport, message string) error {
+	content, err := buildEmail(report, message, notifier.recipients)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if smtpUsername != "" {
+		host, _, _ := net.SplitHostPort(smtpAddress)
+		auth = smtp.PlainAuth("", smtpUsername, smtpPassword, host) // Refuses to send the password without TLS, except to localhost
+	}
+	return smtp.SendMail(smtpAddress, auth, emailFrom, notifier.recipients, content)
+}
+
+// emailSubject summarizes the report in the subject line.
+func emailSubject(report runReport) string {
+	if report.Kind == reportKindDigest {
+		return fmt.Sprintf("Hillyard mirror integrity digest: %d findings", len(report.IntegrityFindings))
+	}
+	return fmt.Sprintf("Hillyard mirror: %d new, %d revised documents", len(report.NewDocuments), len(report.UpdatedDocuments))
+}
+
+// buildEmail renders a multipart message: the text, then the change report
+// as changes.csv, or the findings as findings.csv for a digest.
+func buildEmail(report runReport, message string, recipients []string) ([]byte, error) {
+	attachmentName, attachment := "changes.csv", changeReportCSV(report)
+	if report.Kind == reportKindDigest {
+		attachmentName, attachment = "findings.csv", findingsCSV(report.IntegrityFindings)
+	}
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	text, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	text.Write([]byte(strings.ReplaceAll(message, "\n", "\r\n") + "\r\n"))
+	file, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/csv; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachmentName)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 { // Mail lines stay short
+		file.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	file.Write([]byte(encoded + "\r\n"))
+	parts.Close()
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "From: %s\r\n", emailFrom)
+	fmt.Fprintf(&content, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&content, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", emailSubject(report)))
+	fmt.Fprintf(&content, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&content, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&content, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", parts.Boundary())
+	content.Write(body.Bytes())
+	return content.Bytes(), nil
+}
+
+// changeReportCSV lists the new, revised and drifted documents of a run.
+func changeReportCSV(report runReport) []byte {
+	var content bytes.Buffer
+	writer := csv.NewWriter(&content)
+	writer.Write([]string{"change", "title", "file", "url"})
+	sections := map[string][]documentChange{"new": report.NewDocuments, "revised": report.UpdatedDocuments}
+	order := []string{"new", "revised"}
+	if report.Drift != nil { // Change control wants to see exactly what drifted
+		sections["not_approved"], sections["approved_but_removed"], sections["changed_since_approval"] = report.Drift.Added, report.Drift.Removed, report.Drift.Changed
+		order = append(order, "not_approved", "approved_but_removed", "changed_since_approval")
+	}
+	for _, change := range order {
+		for _, document := range sections[change] {
+			writer.Write([]string{change, document.Title, document.File, document.URL})
+		}
+	}
+	writer.Flush()
+	return content.Bytes()
+}
+
+// findingsCSV lists the findings of an integrity digest.
+func findingsCSV(findings []integrityFinding) []byte {
+	var content bytes.Buffer
+	writer := csv.NewWriter(&content)
+	writer.Write([]string{"kind", "file", "url", "detail", "found_at"})
+	for _, finding := range findings {
+		writer.Write([]string{finding.Kind, finding.File, finding.URL, finding.Detail, finding.FoundAt.Format(time.RFC3339)})
+	}
+	writer.Flush()
+	return content.Bytes()
+}
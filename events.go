@@ -0,0 +1,81 @@
+package main // Define the main package
+
+import (
+	"encoding/json" // For encoding events
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting unknown destinations
+	"io"            // For the event destination
+	"os"            // For stdout and the events file
+	"sync"          // For serializing events across workers
+	"time"          // For event timestamps
+)
+
+// Types of the events written to --events
+const (
+	eventDiscovered        = "discovered"         // A document link was found in search results
+	eventDownloadStarted   = "download_started"   // A PDF download request is being sent
+	eventDownloadCompleted = "download_completed" // A PDF was stored
+	eventDownloadFailed    = "download_failed"    // A PDF download failed
+)
+
+var (
+	eventsDestination string     // "", "stdout" or "file"
+	eventsFile        string     // Where --events=file appends events
+	eventsWriter      io.Writer  // Open event destination, nil when events are off
+	eventsMutex       sync.Mutex // Serializes writes across workers
+)
+
+func init() {
+	flag.Func("events", "emit newline-delimited JSON events as they happen: stdout or file (see -events-file)", func(value string) error {
+		if value != "stdout" && value != "file" { // Register the event stream flag
+			return fmt.Errorf("unknown event destination %q (want stdout or file)", value)
+		}
+		eventsDestination = value
+		return nil
+	})
+	flag.StringVar(&eventsFile, "events-file", "events.jsonl", "file that -events=file appends events to") // Register the events file flag
+}
+
+// event is one line of the event stream.
+type event struct {
+	Type   string    `json:"type"`             // One of the event types above
+	Time   time.Time `json:"time"`             // When it happened
+	URL    string    `json:"url"`              // Document URL
+	File   string    `json:"file,omitempty"`   // Filename inside the PDF folder, once stored
+	Size   int64     `json:"size,omitempty"`   // Bytes stored
+	SHA256 string    `json:"sha256,omitempty"` // Hash of the stored file
+	Reason string    `json:"reason,omitempty"` // Failure reason code
+	Error  string    `json:"error,omitempty"`  // Error message or status line
+}
+
+// openEventStream opens the --events destination.
+func openEventStream() {
+	switch eventsDestination {
+	case "stdout":
+		if jsonOutput() { // Stdout carries the result then
+			fatalConfig("-events stdout cannot be combined with -output json; use -events file")
+		}
+		eventsWriter = os.Stdout
+	case "file":
+		file, err := os.OpenFile(eventsFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fatalConfig("failed to open events file %s: %v", eventsFile, err)
+		}
+		eventsWriter = file // Closed when the process exits
+	}
+}
+
+// emitEvent writes one event as a line of JSON, when events are on.
+func emitEvent(happened event) {
+	if eventsWriter == nil {
+		return
+	}
+	happened.Time = time.Now().UTC()
+	line, err := json.Marshal(happened)
+	if err != nil {
+		return
+	}
+	eventsMutex.Lock()
+	eventsWriter.Write(append(line, '\n')) // One write per line, so readers never see half an event
+	eventsMutex.Unlock()
+}
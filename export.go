@@ -0,0 +1,96 @@
+package main // Define the main package
+
+import (
+	"encoding/csv"  // For catalog.csv
+	"encoding/json" // For catalog.jsonl
+	"flag"          // For command-line flag parsing
+	"log"           // For logging messages and errors
+	"os"            // For creating the export files
+	"path/filepath" // For building export paths
+	"strconv"       // For formatting sizes
+	"strings"       // For joining hazard codes
+)
+
+var exportDir string // Folder the catalog exports are written to
+
+func init() {
+	flag.StringVar(&exportDir, "export-dir", ".", "folder where export writes catalog.csv and catalog.jsonl") // Register the export folder flag
+}
+
+// catalogRow is one exported SDS.
+type catalogRow struct {
+	ProductName  string   `json:"product_name"`  // Product name from the search results
+	URL          string   `json:"url"`           // Source URL
+	LocalPath    string   `json:"local_path"`    // Where the PDF is stored
+	SHA256       string   `json:"sha256"`        // Hash of the stored PDF
+	Size         int64    `json:"size"`          // Size in bytes
+	RevisionDate string   `json:"revision_date"` // Revision date reported by the server
+	HazardCodes  []string `json:"hazard_codes"`  // GHS hazard statements, filled in by index
+	SignalWord   string   `json:"signal_word"`   // "Danger" or "Warning", filled in by index
+}
+
+// Build the exported rows from the manifest
+func catalogRows(documents *manifest) []catalogRow {
+	var rows []catalogRow // One row per document
+	for _, entry := range documents.currentEntries() {
+		rows = append(rows, catalogRow{
+			ProductName:  entry.Title,
+			URL:          entry.URL,
+			LocalPath:    entry.localPath(),
+			SHA256:       entry.SHA256,
+			Size:         entry.Size,
+			RevisionDate: entry.RevisionDate,
+			HazardCodes:  entry.HazardCodes,
+			SignalWord:   entry.SignalWord,
+		})
+	}
+	return rows
+}
+
+// runExportCommand writes catalog.csv and catalog.jsonl from the manifest.
+func runExportCommand() {
+	rows := catalogRows(mustLoadManifest()) // Everything the manifest knows about
+	createDirectory(exportDir, 0755)        // Make sure the export folder exists
+	if err := writeCatalogCSV(filepath.Join(exportDir, "catalog.csv"), rows); err != nil {
+		fatalln(err)
+	}
+	if err := writeCatalogJSONL(filepath.Join(exportDir, "catalog.jsonl"), rows); err != nil {
+		fatalln(err)
+	}
+	log.Printf("exported %d documents to %s", len(rows), exportDir)
+}
+
+// Write the catalog as CSV with a header row
+func writeCatalogCSV(path string, rows []catalogRow) error {
+	file, err := os.Create(path) // Create the CSV file
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file) // Handles quoting of product names
+	writer.Write([]string{"product_name", "url", "local_path", "sha256", "size", "revision_date", "hazard_codes", "signal_word"})
+	for _, row := range rows {
+		writer.Write([]string{row.ProductName, row.URL, row.LocalPath, row.SHA256, strconv.FormatInt(row.Size, 10), row.RevisionDate, strings.Join(row.HazardCodes, ";"), row.SignalWord})
+	}
+	writer.Flush() // Push buffered rows to the file
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Write the catalog as one JSON object per line
+func writeCatalogJSONL(path string, rows []catalogRow) error {
+	file, err := os.Create(path) // Create the JSONL file
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file) // Encode writes one line per value
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
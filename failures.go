@@ -0,0 +1,102 @@
+package main // Define the main package
+
+import (
+	"encoding/json"  // For writing the machine-readable report
+	"flag"           // For command-line flag parsing
+	"fmt"            // For formatting details and the summary table
+	"log"            // For logging messages and errors
+	"os"             // For writing the report file
+	"sort"           // For a stable summary order
+	"sync"           // For guarding the shared failure list
+	"text/tabwriter" // For aligned console output
+	"time"           // For failure timestamps
+)
+
+const (
+	failureKindSearch   = "search"   // A search query failed
+	failureKindDownload = "download" // A PDF download failed
+
+	reasonRequestError    = "request_error"    // The request could not be built or sent
+	reasonHTTPStatus      = "http_status"      // The server answered with an unexpected status
+	reasonContentType     = "content_type"     // The response was not a PDF
+	reasonReadError       = "read_error"       // The response body could not be read
+	reasonEmptyBody       = "empty_body"       // The response body was empty
+	reasonWriteError      = "write_error"      // The result could not be stored locally
+	reasonTooLarge        = "too_large"        // The response was bigger than --max-file-size
+	reasonLowDiskSpace    = "low_disk_space"   // Free space fell below --min-free-space
+	reasonContentEncoding = "content_encoding" // The response was compressed in a way that cannot be decoded
+	reasonHookRejected    = "hook_rejected"    // A post-download hook refused the file
+)
+
+var (
+	failuresFile  string     // Where the end-of-run failure report is written
+	failures      []failure  // Every failure recorded during the run
+	failuresMutex sync.Mutex // Guards failures across workers
+)
+
+func init() {
+	flag.StringVar(&failuresFile, "failures-file", "failures.json", "where to write the machine-readable failure report") // Register the failure report flag
+}
+
+// failure describes one failed search query or download.
+type failure struct {
+	Kind   string    `json:"kind"`   // failureKindSearch or failureKindDownload
+	Target string    `json:"target"` // Query or URL that failed
+	Reason string    `json:"reason"` // One of the reason codes above
+	Detail string    `json:"detail"` // Error message or status line
+	Time   time.Time `json:"time"`   // When the failure happened
+}
+
+// recordFailure logs a failure and keeps it for the end-of-run report.
+func recordFailure(kind, target, reason string, detail any) {
+	entry := failure{Kind: kind, Target: target, Reason: reason, Detail: fmt.Sprint(detail), Time: time.Now().UTC()} // Build the entry
+	log.Printf("%s failed for %s: %s (%s)", kind, target, entry.Detail, reason)                                      // Keep the log line
+	failuresMutex.Lock()
+	failures = append(failures, entry) // Remember the failure
+	failuresMutex.Unlock()
+	if kind == failureKindDownload {
+		emitEvent(event{Type: eventDownloadFailed, URL: target, Reason: reason, Error: entry.Detail})
+	}
+}
+
+// writeFailureReport writes failures.json and prints a summary table.
+func writeFailureReport() {
+	failuresMutex.Lock()
+	defer failuresMutex.Unlock()
+	report := failures // Always write a list, even when it is empty
+	if report == nil {
+		report = []failure{}
+	}
+	content, err := json.MarshalIndent(report, "", "  ") // Encode the report
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(failuresFile, append(content, '\n'), 0644); err != nil {
+		log.Println(err) // Log error
+	}
+	if len(failures) == 0 || jsonOutput() || quietOutput { // Nothing to summarize, or the run report or quiet summary carries the failures
+		return
+	}
+	counts := make(map[[2]string]int) // Failures per kind and reason
+	for _, entry := range failures {
+		counts[[2]string{entry.Kind, entry.Reason}]++
+	}
+	keys := make([][2]string, 0, len(counts)) // Sorted summary rows
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "KIND\tREASON\tCOUNT")
+	for _, key := range keys {
+		fmt.Fprintf(writer, "%s\t%s\t%d\n", key[0], key[1], counts[key])
+	}
+	writer.Flush() // Print the table
+	log.Printf("%d failures recorded in %s", len(failures), failuresFile)
+}
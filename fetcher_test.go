@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// setGlobal sets a package variable for the rest of the test.
+func setGlobal[T any](t *testing.T, variable *T, value T) {
+	t.Helper()
+	previous := *variable
+	*variable = value
+	t.Cleanup(func() { *variable = previous })
+}
+
+// useTestOrigin points searches and downloads at an httptest server through
+// the fetcher seam, with empty data folders and a fresh run.
+func useTestOrigin(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	root := t.TempDir()
+	setGlobal(t, &fetcher, Fetcher(server.Client()))
+	setGlobal(t, &originURL, server.URL)
+	setGlobal(t, &allowExternal, true) // The test server is not an allowed domain
+	setGlobal(t, &givenFolder, filepath.Join(root, "assets"))
+	setGlobal(t, &outputDir, filepath.Join(root, "PDFs"))
+	setGlobal(t, &manifestFile, filepath.Join(root, "manifest.json"))
+	setGlobal(t, &documentManifest, &manifest{Entries: make(map[string]*manifestEntry)})
+	for _, folder := range []string{givenFolder, outputDir} {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forgetCachedState() // Nothing cached from another test's folders
+	resetRunState()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		checkpointJournal() // Close the journal in this test's folder
+		forgetCachedState()
+	})
+	return server
+}
+
+// recordedFailures returns the failures of the run so far.
+func recordedFailures() []failure {
+	failuresMutex.Lock()
+	defer failuresMutex.Unlock()
+	return append([]failure(nil), failures...)
+}
+
+func TestDiscoverParsesAndPaginates(t *testing.T) {
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/safetydatasheet/search/results" || request.URL.Query().Get("q") != "floor care" {
+			http.NotFound(writer, request)
+			return
+		}
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+		page = max(page, 1)
+		fmt.Fprintf(writer, `<div class="card"><h3>Product %d</h3><a href="/docs/p%d.pdf">SDS</a></div>`, page, page)
+		if page < 3 {
+			fmt.Fprintf(writer, `<a href="?q=floor+care&page=%d">%d</a>`, page+1, page+1)
+		}
+	}))
+	result := currentSite().Discover(context.Background(), "floor care")
+	if result.Status != http.StatusOK || result.Truncated {
+		t.Fatalf("Discover() status %d, truncated %t; want 200, complete", result.Status, result.Truncated)
+	}
+	if len(result.Links) != 3 {
+		t.Fatalf("Discover() found %d links, want one per page: %+v", len(result.Links), result.Links)
+	}
+	for index, link := range result.Links {
+		if want := fmt.Sprintf("%s/docs/p%d.pdf", originURL, index+1); link.URL != want || link.Title != fmt.Sprintf("Product %d", index+1) {
+			t.Errorf("link %d = %+v, want %s titled after its card", index, link, want)
+		}
+	}
+	if len(recordedFailures()) != 0 {
+		t.Errorf("Discover() recorded failures %v", recordedFailures())
+	}
+}
+
+func TestDiscoverStopsAtMaxSearchPages(t *testing.T) {
+	var requests int
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+		page = max(page, 1)
+		fmt.Fprintf(writer, `<a href="/docs/p%d.pdf">Product %d</a><a rel="next" href="?q=x&page=%d">Next</a>`, page, page, page+1)
+	}))
+	setGlobal(t, &maxSearchPages, 2)
+	result := currentSite().Discover(context.Background(), "x")
+	if requests != 2 || len(result.Links) != 2 || !result.Truncated || result.Status != http.StatusOK {
+		t.Errorf("Discover() made %d requests and returned %d links, truncated %t; want 2, 2, true", requests, len(result.Links), result.Truncated)
+	}
+}
+
+func TestSearchQueryResumesAfterBudgetStop(t *testing.T) {
+	var requests int
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+		page = max(page, 1)
+		fmt.Fprintf(writer, `<a href="/docs/p%d.pdf">Product %d</a>`, page, page)
+		if page < 3 {
+			fmt.Fprintf(writer, `<a rel="next" href="?q=floor&page=%d">Next</a>`, page+1)
+		}
+	}))
+	setGlobal(t, &customQueries, []string{"floor"})
+	setGlobal(t, &requestBudget, 2) // Spent after the second of three pages
+
+	searchQuery("floor")
+	result, found := loadSearchResult("floor")
+	if !found || !result.Truncated || result.Pages != 2 || len(result.Links) != 2 || requests != 2 {
+		t.Fatalf("saved result = %+v after %d requests, want the first 2 pages, truncated", result, requests)
+	}
+	if !result.interrupted() || !resultsOutdated("floor") {
+		t.Fatal("a search stopped by the budget counts as done")
+	}
+	if reason := incompleteSearches(); reason == "" {
+		t.Error("prune would trust the truncated result")
+	}
+
+	resetRunState() // The next run, with a fresh budget
+	setGlobal(t, &requestBudget, 0)
+	searchQuery("floor")
+	if result, _ := loadSearchResult("floor"); result.Truncated || len(result.Links) != 3 || resultsOutdated("floor") {
+		t.Errorf("resumed result = %+v, want all 3 pages", result)
+	}
+}
+
+func TestSearchQueryKeepsMaxSearchPagesCap(t *testing.T) {
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `<a href="/docs/a.pdf">A</a><a rel="next" href="?q=floor&page=2">Next</a>`)
+	}))
+	setGlobal(t, &customQueries, []string{"floor"})
+	setGlobal(t, &maxSearchPages, 1)
+	searchQuery("floor")
+	if result, _ := loadSearchResult("floor"); !result.Truncated || result.interrupted() || resultsOutdated("floor") {
+		t.Errorf("result = %+v; a search capped by -max-search-pages should not be searched again", result)
+	}
+}
+
+func TestDiscoverDecodesGzip(t *testing.T) {
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Encoding", "gzip")
+		compressed := gzip.NewWriter(writer)
+		fmt.Fprint(compressed, `<a href="/docs/glass.pdf">Glass Cleaner</a>`)
+		compressed.Close()
+	}))
+	result := currentSite().Discover(context.Background(), "glass")
+	if len(result.Links) != 1 || result.Links[0].Title != "Glass Cleaner" {
+		t.Errorf("Discover() = %+v, want the link of the decompressed page", result.Links)
+	}
+}
+
+func TestDiscoverDecodesBrotli(t *testing.T) {
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !strings.Contains(request.Header.Get("Accept-Encoding"), "br") {
+			t.Errorf("Accept-Encoding = %q, want br offered", request.Header.Get("Accept-Encoding"))
+		}
+		writer.Header().Set("Content-Encoding", "br")
+		compressed := brotli.NewWriter(writer)
+		fmt.Fprint(compressed, `<a href="/docs/wax.pdf">Floor Wax</a>`)
+		compressed.Close()
+	}))
+	result := currentSite().Discover(context.Background(), "wax")
+	if len(result.Links) != 1 || result.Links[0].Title != "Floor Wax" {
+		t.Errorf("Discover() = %+v, want the link of the decompressed page", result.Links)
+	}
+}
+
+func TestDiscoverRecordsFailedSearch(t *testing.T) {
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "maintenance", http.StatusServiceUnavailable)
+	}))
+	result := currentSite().Discover(context.Background(), "wax")
+	if result.searched() || result.Status != http.StatusServiceUnavailable || len(result.Links) != 0 {
+		t.Errorf("Discover() = status %d with %d links, want a failed 503 search", result.Status, len(result.Links))
+	}
+	if failed := recordedFailures(); len(failed) != 1 || failed[0].Kind != failureKindSearch || failed[0].Reason != reasonHTTPStatus {
+		t.Errorf("failures = %+v, want one http_status search failure", failed)
+	}
+}
+
+func TestDownloadPDF(t *testing.T) {
+	pdf := []byte("%PDF-1.7\n1 0 obj << >> endobj\n%%EOF\n")
+	useTestOrigin(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/docs/floor.pdf":
+			writer.Header().Set("Content-Type", "application/pdf")
+			writer.Write(pdf)
+		case "/docs/expired.pdf":
+			writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(writer, "<html>Please log in</html>")
+		case "/docs/huge.pdf":
+			writer.Header().Set("Content-Type", "application/pdf")
+			writer.Header().Set("Content-Length", "1000000")
+		case "/docs/streamed.pdf": // No Content-Length, too big once read
+			writer.Header().Set("Content-Type", "application/pdf")
+			writer.(http.Flusher).Flush()
+			writer.Write(bytes.Repeat([]byte("x"), 5000))
+		case "/docs/short.pdf":
+			writer.Header().Set("Content-Type", "application/pdf")
+			writer.Header().Set("Content-Length", "100")
+			writer.Write(pdf[:10])
+		case "/docs/empty.pdf":
+			writer.Header().Set("Content-Type", "application/pdf")
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	setGlobal(t, &maxFileSize, 4096)
+
+	tests := []struct {
+		file   string
+		reason string // Failure reason, "" when the download must succeed
+	}{
+		{"floor.pdf", ""},
+		{"missing.pdf", reasonHTTPStatus},
+		{"expired.pdf", reasonContentType},
+		{"huge.pdf", reasonTooLarge},
+		{"streamed.pdf", reasonTooLarge},
+		{"short.pdf", reasonReadError},
+		{"empty.pdf", reasonEmptyBody},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			resetRunState()
+			link := originURL + "/docs/" + test.file
+			downloadPDF(link)
+			stored := filepath.Join(outputDir, test.file)
+			failed := recordedFailures()
+			if test.reason == "" {
+				content, err := os.ReadFile(stored)
+				if err != nil || !bytes.Equal(content, pdf) || len(failed) != 0 {
+					t.Fatalf("downloadPDF() stored %q (%v) with failures %+v, want the PDF", content, err, failed)
+				}
+				if entry, found := documentManifest.lookup(link); !found || entry.File != test.file || entry.Size != int64(len(pdf)) {
+					t.Errorf("manifest entry = %+v, %t; want %s with %d bytes", entry, found, test.file, len(pdf))
+				}
+				return
+			}
+			if len(failed) != 1 || failed[0].Kind != failureKindDownload || failed[0].Reason != test.reason || failed[0].Target != link {
+				t.Errorf("failures = %+v, want one %s download failure for %s", failed, test.reason, link)
+			}
+			if _, err := os.Stat(stored); !os.IsNotExist(err) {
+				t.Errorf("rejected download left %s behind (%v)", stored, err)
+			}
+			if leftovers, _ := filepath.Glob(filepath.Join(outputDir, ".*")); len(leftovers) != 0 {
+				t.Errorf("rejected download left temporary files %v", leftovers)
+			}
+			if _, found := documentManifest.lookup(link); found {
+				t.Errorf("rejected download was recorded in the manifest")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For printing tables
+	"log"            // For logging messages and errors
+	"net/url"        // For decoding URL filenames
+	"os"             // For renaming files and the exit status
+	"path"           // For the URL's last path segment
+	"path/filepath"  // For building local paths
+	"regexp"         // For recognizing disambiguated names
+	"strings"        // For building names
+	"text/tabwriter" // For aligned columns
+	"unicode"        // For keeping letters of every script
+)
+
+// Problems the filename audit reports
+const (
+	filenameMeaningless = "meaningless" // Nothing but underscores and punctuation is left
+	filenameLossy       = "lossy"       // Non-ASCII letters of the original name were replaced
+	filenameCollision   = "collision"   // Shares its sanitized name with another URL and got a hash suffix
+	filenameUntitled    = "untitled"    // Named after the URL although -name-files-by title is set
+)
+
+// disambiguationSuffix matches the suffix disambiguatedFilename appends.
+var disambiguationSuffix = regexp.MustCompile(`-[0-9a-f]{8}\.pdf$`)
+
+// filenameFinding is one stored file the audit would rename.
+type filenameFinding struct {
+	File     string   `json:"file"`               // Current filename
+	URL      string   `json:"url"`                // Source URL
+	Title    string   `json:"title,omitempty"`    // Product name from the catalog
+	Issues   []string `json:"issues"`             // What is wrong with the name
+	Proposed string   `json:"proposed,omitempty"` // Better name, empty when none can be derived
+}
+
+// internationalFilename sanitizes a name like sanitizeFilename, but keeps
+// letters and digits of every script and collapses runs of replaced
+// characters, so "Désinfectant Ĳs" becomes "désinfectant_ĳs.pdf" instead of
+// "d_sinfectant_s.pdf". Returns "" when nothing meaningful is left.
+func internationalFilename(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".pdf")
+	var safe strings.Builder
+	for _, character := range name {
+		switch {
+		case unicode.IsLetter(character) || unicode.IsDigit(character) || character == '-' || character == '.':
+			safe.WriteRune(character)
+		case !strings.HasSuffix(safe.String(), "_"): // Collapse runs into one underscore
+			safe.WriteRune('_')
+		}
+	}
+	stem := strings.Trim(safe.String(), "_-.")
+	if stem == "" {
+		return ""
+	}
+	return stem + ".pdf"
+}
+
+// urlBaseName returns the decoded last path segment of a URL.
+func urlBaseName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	base := path.Base(parsed.Path)
+	if decoded, err := url.QueryUnescape(base); err == nil {
+		return decoded
+	}
+	return base
+}
+
+// filenameIssues lists what the old sanitizer did to an entry's name.
+func filenameIssues(entry manifestEntry) []string {
+	var issues []string
+	docType := entryDocType(entry)                                                                           // Decides the subfolder and the titled suffix
+	stem := strings.TrimSuffix(disambiguationSuffix.ReplaceAllString(path.Base(entry.File), ".pdf"), ".pdf") // Name without the hash suffix
+	if strings.Trim(stem, "_-.") == "" {
+		issues = append(issues, filenameMeaningless)
+	}
+	if hasNonASCIILetter(urlBaseName(entry.URL)) && !hasNonASCIILetter(entry.File) { // Already renamed names keep them
+		issues = append(issues, filenameLossy)
+	}
+	if plain := docType.inFolder(plainPDFFilename(entry.URL)); entry.File != plain && entry.File == disambiguatedFilename(plain, entry.URL) {
+		issues = append(issues, filenameCollision)
+	}
+	if titled := titledFilename(entry.Title, docType.Name, entry.Language); nameFilesBy == nameFilesByTitle && entry.Source != manualSource && titled != "" && entry.File != docType.inFolder(titled) && entry.File != disambiguatedFilename(docType.inFolder(titled), entry.URL) {
+		issues = append(issues, filenameUntitled)
+	}
+	return issues
+}
+
+// hasNonASCIILetter reports whether name has letters the old sanitizer dropped.
+func hasNonASCIILetter(name string) bool {
+	for _, character := range name {
+		if character > unicode.MaxASCII && unicode.IsLetter(character) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditFilenames finds stored files with damaged names and proposes names
+// built from the catalog title, or from the URL when there is no title.
+// Proposals never clash with each other or with files already stored.
+func auditFilenames(documents *manifest) []filenameFinding {
+	entries := documents.currentEntries()
+	taken := make(map[string]bool) // Names in use or already proposed
+	for _, entry := range entries {
+		taken[entry.File] = true
+	}
+	var findings []filenameFinding
+	for _, entry := range entries {
+		issues := filenameIssues(entry)
+		if len(issues) == 0 {
+			continue
+		}
+		finding := filenameFinding{File: entry.File, URL: entry.URL, Title: entry.Title, Issues: issues}
+		proposed := internationalFilename(entry.Title) // Catalog metadata says most
+		if nameFilesBy == nameFilesByTitle {           // The same names new downloads get
+			proposed = titledFilename(entry.Title, entryDocType(entry).Name, entry.Language)
+		}
+		if proposed == "" {
+			proposed = internationalFilename(urlBaseName(entry.URL))
+		}
+		if proposed != "" { // Stay in the document type's subfolder
+			proposed = entryDocType(entry).inFolder(proposed)
+		}
+		if proposed != "" && taken[proposed] {
+			proposed = disambiguatedFilename(proposed, entry.URL)
+		}
+		if proposed != "" && proposed != entry.File && !taken[proposed] && !fileExists(filepath.Join(outputDir, proposed)) {
+			finding.Proposed = proposed
+			taken[proposed] = true
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// runFilenamesCommand audits stored filenames and, with "apply", renames
+// the files and updates the manifest.
+func runFilenamesCommand(args []string) {
+	action := "audit"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action != "audit" && action != "apply" {
+		log.Printf("usage: filenames [audit|apply]")
+		exit(2)
+	}
+	documentManifest = mustLoadManifest()
+	findings := auditFilenames(documentManifest)
+	if action == "apply" {
+		applyFilenameProposals(findings)
+	}
+	if jsonOutput() {
+		printJSON(findings)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "FILE\tISSUES\tPROPOSED\tTITLE")
+	for _, finding := range findings {
+		proposed := finding.Proposed
+		if proposed == "" {
+			proposed = "-" // Nothing better to offer
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", finding.File, strings.Join(finding.Issues, ","), proposed, finding.Title)
+	}
+	writer.Flush() // Print the table
+}
+
+// applyFilenameProposals renames each file whose content still matches the
+// manifest and whose new name is free, saving the manifest after every
+// rename so the two never disagree. Later runs keep the new names because
+// storedFilename prefers the name the manifest records.
+func applyFilenameProposals(findings []filenameFinding) {
+	renamed := 0
+	for index, finding := range findings {
+		if finding.Proposed == "" {
+			continue
+		}
+		entry, known := documentManifest.lookup(finding.URL)
+		source, target := filepath.Join(outputDir, finding.File), filepath.Join(outputDir, finding.Proposed)
+		if hash, err := hashFile(source); !known || err != nil || hash != entry.SHA256 { // Missing or changed since it was recorded
+			log.Printf("not renaming %s: it does not match the manifest, run verify first", finding.File)
+			findings[index].Proposed = ""
+			continue
+		}
+		if _, err := os.Lstat(target); err == nil { // Appeared since the audit
+			log.Printf("not renaming %s: %s already exists", finding.File, finding.Proposed)
+			findings[index].Proposed = ""
+			continue
+		}
+		if err := os.Rename(source, target); err != nil {
+			log.Printf("failed to rename %s to %s: %v", finding.File, finding.Proposed, err)
+			findings[index].Proposed = ""
+			continue
+		}
+		entry.File = finding.Proposed
+		documentManifest.replace(entry)
+		if err := documentManifest.save(manifestFile); err != nil {
+			os.Rename(target, source) // Keep disk and manifest in step
+			fatalf("failed to save manifest %s: %v", manifestFile, err)
+		}
+		renamed++
+	}
+	writeChecksums(documentManifest) // Sidecars follow the new names
+	log.Printf("renamed %d of %d files", renamed, len(findings))
+}
@@ -0,0 +1,125 @@
+package main // Define the main package
+
+import (
+	"crypto/sha1"  // For short, stable filename suffixes
+	"encoding/hex" // For encoding the suffix
+	"flag"         // For command-line flag parsing
+	"fmt"          // For rejecting unknown namings
+	"log"          // For logging collisions
+	"strings"      // For splitting the extension
+	"sync"         // For guarding the owner table across workers
+	"unicode"      // For keeping letters of every script
+)
+
+// Ways of naming newly stored files
+const (
+	nameFilesByURL   = "url"   // Sanitized last segment of the document URL
+	nameFilesByTitle = "title" // Product name and language, e.g. arsenal-quick-and-clean-sds-en.pdf
+)
+
+var (
+	filenameOwners      map[string]string // Local filename → source URL stored under it
+	filenameOwnersMutex sync.Mutex        // Guards filenameOwners
+	nameFilesBy         = nameFilesByURL  // How new files are named
+)
+
+func init() {
+	flag.Func("name-files-by", "name newly stored PDFs after the \"url\" or the product \"title\" found in the search results (default url); filenames apply renames existing ones", func(value string) error {
+		if value != nameFilesByURL && value != nameFilesByTitle {
+			return fmt.Errorf("unknown naming %q (use url or title)", value)
+		}
+		nameFilesBy = value
+		return nil
+	}) // Register the naming flag
+}
+
+// titledFilename builds a readable filename from a product name, document
+// type and language: "Arsenal Quick & Clean", "sds", "en" →
+// arsenal-quick-and-clean-sds-en.pdf. Letters of every script are kept.
+// Returns "" without a usable title.
+func titledFilename(title, kind, language string) string {
+	var slug strings.Builder
+	for _, character := range strings.ToLower(strings.ReplaceAll(title, "&", " and ")) {
+		switch {
+		case unicode.IsLetter(character) || unicode.IsDigit(character):
+			slug.WriteRune(character)
+		case slug.Len() > 0 && !strings.HasSuffix(slug.String(), "-"): // Collapse separators into one hyphen
+			slug.WriteRune('-')
+		}
+	}
+	name := strings.TrimSuffix(slug.String(), "-")
+	for _, suffix := range []string{"-" + kind, "-sds", "-msds", "-safety-data-sheet"} { // Added back below, once
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if name == "" {
+		return ""
+	}
+	name += "-" + kind
+	if language != "" {
+		name += "-" + strings.ToLower(language)
+	}
+	return name + ".pdf"
+}
+
+// preferredFilename is the name a new document would be stored under before
+// collision handling: titled with -name-files-by title when the search
+// results named the product, otherwise taken from the URL.
+func preferredFilename(pdfURL, title, kind, language string) string {
+	if nameFilesBy == nameFilesByTitle {
+		if name := titledFilename(title, kind, language); name != "" {
+			return name
+		}
+	}
+	return plainPDFFilename(pdfURL)
+}
+
+// Load the filename owners from the manifest if needed; the caller holds
+// filenameOwnersMutex. Without a loaded manifest nothing is known to be taken.
+func loadFilenameOwnersLocked() {
+	if filenameOwners != nil || documentManifest == nil {
+		return
+	}
+	filenameOwners = make(map[string]string)
+	for _, entry := range documentManifest.currentEntries() {
+		if _, taken := filenameOwners[entry.File]; !taken { // Sorted by URL within a name, first one keeps it
+			filenameOwners[entry.File] = entry.URL
+		}
+	}
+}
+
+// disambiguatedFilename appends a short hash of the URL before the extension,
+// e.g. sds.pdf → sds-1a2b3c4d.pdf.
+func disambiguatedFilename(name, pdfURL string) string {
+	sum := sha1.Sum([]byte(pdfURL))
+	return strings.TrimSuffix(name, ".pdf") + "-" + hex.EncodeToString(sum[:4]) + ".pdf"
+}
+
+// storedFilename returns the filename for pdfURL given the names already
+// taken: the name the manifest records for it (which survives renames by
+// "filenames apply"), the plain sanitized name, or the disambiguated one
+// when a different URL already owns the plain name. With claim set, the
+// name is reserved for pdfURL and the URL that owned the plain name, if
+// any, is returned as well.
+func storedFilename(name, pdfURL string, claim bool) (string, string) {
+	if documentManifest != nil {
+		if entry, known := documentManifest.lookup(pdfURL); known && entry.File != "" { // Stored before
+			return entry.File, ""
+		}
+	}
+	filenameOwnersMutex.Lock()
+	defer filenameOwnersMutex.Unlock()
+	loadFilenameOwnersLocked()
+	owner, taken := filenameOwners[name]
+	if !taken || owner == pdfURL { // Free, or already ours
+		if claim && filenameOwners != nil {
+			filenameOwners[name] = pdfURL
+		}
+		return name, ""
+	}
+	unique := disambiguatedFilename(name, pdfURL) // Two URLs sanitize to the same name
+	if claim {
+		filenameOwners[unique] = pdfURL
+		log.Printf("filename collision: %s and %s both map to %s; the latter gets %s", owner, pdfURL, name, unique)
+	}
+	return unique, owner
+}
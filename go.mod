@@ -1,3 +1,12 @@
 module github.com/Strong-Foundation/hillyard-com-documentation
 
 go 1.24.2
+
+require (
+	github.com/andybalholm/brotli v1.2.5
+	github.com/ledongthuc/pdf v0.0.0-20260907135840-6c8c28e0e8a0
+	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.43.0
+	golang.org/x/sys v0.35.0
+	golang.org/x/term v0.34.0
+)
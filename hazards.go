@@ -0,0 +1,95 @@
+package main // Define the main package
+
+import (
+	"log"     // For logging the update
+	"os"      // For reading extracted text
+	"regexp"  // For finding codes and signal words
+	"slices"  // For comparing code lists
+	"sort"    // For stable code order
+	"strings" // For normalizing signal words
+)
+
+// sectionTwoLimit caps how much text is searched when a document has no
+// recognizable Section 2 heading.
+const sectionTwoLimit = 8000
+
+var (
+	sectionThreeStartRegex = regexp.MustCompile(`(?i)section\s*3\b`)
+	hazardCodeRegex        = regexp.MustCompile(`\bH[2-4]\d{2}(?:[FDfd]{1,2})?\b`)                // GHS hazard statements, e.g. H314 or H360FD
+	signalWordRegex        = regexp.MustCompile(`(?i)signal\s*word\s*[:\-–]?\s*(danger|warning)`) // "Signal word: Danger"
+	bareSignalWordRegex    = regexp.MustCompile(`\b(DANGER|WARNING)\b`)                           // Printed on its own in capitals
+)
+
+// sectionTwo returns the hazard identification section of an SDS.
+func sectionTwo(text string) string {
+	if start := sectionTwoStartRegex.FindStringIndex(text); start != nil {
+		text = text[start[0]:]
+		if end := sectionThreeStartRegex.FindStringIndex(text); end != nil {
+			text = text[:end[0]]
+		}
+	}
+	if len(text) > sectionTwoLimit {
+		text = text[:sectionTwoLimit]
+	}
+	return text
+}
+
+// parseHazards returns the GHS hazard codes, sorted and unique, and the
+// signal word ("Danger" or "Warning", "" when none is printed) of an SDS.
+func parseHazards(text string) ([]string, string) {
+	section := sectionTwo(text)
+	seen := make(map[string]bool)
+	var codes []string
+	for _, code := range hazardCodeRegex.FindAllString(section, -1) {
+		code = strings.ToUpper(code[:4]) + code[4:] // Keep the case of the F/D suffixes, which differs in meaning
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	word := ""
+	if match := signalWordRegex.FindStringSubmatch(section); match != nil {
+		word = match[1]
+	} else if matches := bareSignalWordRegex.FindAllString(section, -1); len(matches) > 0 {
+		word = matches[0]
+		if slices.Contains(matches, "DANGER") { // Danger outranks Warning
+			word = "DANGER"
+		}
+	}
+	if word != "" {
+		word = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+	return codes, word
+}
+
+// recordHazards fills in the hazard codes and signal word of every safety
+// data sheet whose text has been extracted, and saves the manifest when
+// anything changed. It runs after indexing, so the text is already there.
+func recordHazards(documents *manifest) {
+	updated := 0
+	for _, entry := range documents.currentEntries() {
+		if entryDocType(entry).Name != "sds" { // Only SDS have a hazard section
+			continue
+		}
+		text, err := os.ReadFile(extractedTextPath(entry.SHA256))
+		if err != nil { // Not extracted, e.g. a malformed PDF
+			continue
+		}
+		codes, word := parseHazards(string(text))
+		if slices.Equal(codes, entry.HazardCodes) && word == entry.SignalWord {
+			continue
+		}
+		entry.HazardCodes, entry.SignalWord = codes, word
+		documents.replace(entry)
+		updated++
+	}
+	if updated == 0 {
+		return
+	}
+	if err := documents.save(manifestFile); err != nil {
+		log.Printf("failed to save manifest %s: %v", manifestFile, err)
+		return
+	}
+	log.Printf("updated the hazard codes of %d documents", updated)
+}
@@ -0,0 +1,121 @@
+package main // Define the main package
+
+import (
+	"bytes"          // For atomic report writes
+	"encoding/json"  // For the report format
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing tables
+	"log"            // For logging messages and errors
+	"os"             // For reading reports
+	"path/filepath"  // For building report paths
+	"sort"           // For chronological order
+	"strconv"        // For the limit argument
+	"text/tabwriter" // For aligned columns
+	"time"           // For durations and file names
+)
+
+var reportsDir string // Folder holding one summary per finished run
+
+func init() {
+	flag.StringVar(&reportsDir, "reports-dir", "reports", "folder where a timestamped summary of every run is kept") // Register the history folder flag
+}
+
+// runSummary is the slice of a run report kept in the history: counts
+// only, the failures themselves are in failures.json.
+type runSummary struct {
+	StartedAt       time.Time     `json:"started_at"`       // When the run started
+	FinishedAt      time.Time     `json:"finished_at"`      // When the run finished
+	Duration        time.Duration `json:"duration"`         // Wall time of the run
+	QueriesSearched int64         `json:"queries_searched"` // Search queries fetched
+	LinksDiscovered int64         `json:"links_discovered"` // Unique PDF links considered
+	New             int           `json:"new"`              // Documents stored for the first time
+	Updated         int           `json:"updated"`          // Documents whose content changed
+	Skipped         int64         `json:"skipped"`          // PDFs already present
+	Failed          int           `json:"failed"`           // Recorded failures
+	BytesDownloaded int64         `json:"bytes_downloaded"` // Bytes of PDFs written
+	Documents       int           `json:"documents"`        // Size of the library afterwards, 0 when the run did not load it
+}
+
+// summarizeRun cuts a run report down to its history entry.
+func summarizeRun(report runReport) runSummary {
+	summary := runSummary{
+		StartedAt:       report.StartedAt,
+		FinishedAt:      report.FinishedAt,
+		Duration:        report.Duration,
+		QueriesSearched: report.QueriesSearched,
+		LinksDiscovered: report.LinksDiscovered,
+		New:             len(report.NewDocuments),
+		Updated:         len(report.UpdatedDocuments),
+		Skipped:         report.Skipped,
+		Failed:          len(report.Failures),
+		BytesDownloaded: report.BytesDownloaded,
+	}
+	if documentManifest != nil {
+		summary.Documents = len(documentManifest.currentEntries())
+	}
+	return summary
+}
+
+// writeRunSummary adds the run to the history as reports/run-<start time>.json.
+func writeRunSummary(report runReport) {
+	content, err := json.MarshalIndent(summarizeRun(report), "", "  ") // Encode the summary
+	if err != nil {
+		log.Printf("failed to encode run summary: %v", err)
+		return
+	}
+	content = append(content, '\n')
+	createDirectory(reportsDir, 0755)                                                                       // Make sure the folder exists
+	path := filepath.Join(reportsDir, "run-"+report.StartedAt.UTC().Format("20060102T150405.000Z")+".json") // Names sort by start time, runs a second apart do not clash
+	if _, err := writeFileAtomically(path, bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Printf("failed to write run summary %s: %v", path, err)
+	}
+}
+
+// runSummaryFiles returns the history files, oldest first.
+func runSummaryFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(reportsDir, "run-*.json")) // A missing folder means no history
+	sort.Strings(files)
+	return files
+}
+
+// loadRunSummaries reads the history, skipping unreadable files.
+func loadRunSummaries() []runSummary {
+	var summaries []runSummary
+	for _, file := range runSummaryFiles() {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("skipping %s: %v", file, err)
+			continue
+		}
+		var summary runSummary
+		if err := json.Unmarshal(content, &summary); err != nil {
+			log.Printf("skipping %s: %v", file, err)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// runHistoryCommand prints the history of runs, optionally only the last N.
+func runHistoryCommand(args []string) {
+	summaries := loadRunSummaries()
+	if len(args) > 0 {
+		limit, err := strconv.Atoi(args[0])
+		if err != nil || limit < 0 {
+			fatalf("usage: history [number of runs]")
+		}
+		summaries = summaries[max(len(summaries)-limit, 0):]
+	}
+	if jsonOutput() {
+		printJSON(summaries)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "STARTED\tDURATION\tDISCOVERED\tNEW\tUPDATED\tSKIPPED\tFAILED\tBYTES\tDOCUMENTS")
+	for _, summary := range summaries {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", summary.StartedAt.Local().Format(time.DateTime), summary.Duration,
+			summary.LinksDiscovered, summary.New, summary.Updated, summary.Skipped, summary.Failed, summary.BytesDownloaded, summary.Documents)
+	}
+	writer.Flush() // Print the table
+}
@@ -0,0 +1,28 @@
+package main // Define the main package
+
+import (
+	"flag" // For command-line flag parsing
+
+	"github.com/Strong-Foundation/hillyard-com-documentation/pkg/hillyard" // For parsing search result pages
+)
+
+var originURL string // Site searched for documents
+
+func init() {
+	flag.StringVar(&originURL, "origin-url", hillyard.DefaultBaseURL, "site to search for safety data sheets, e.g. a staging mirror or a local test server") // Register the origin flag
+}
+
+// searchPageURL is the page search results are served from; relative links
+// in saved results are resolved against it.
+func searchPageURL() string {
+	return documentTypes[0].searchURL() // The SDS search
+}
+
+// documentLink is a link to a document found in a search result page.
+type documentLink = hillyard.Document
+
+// extractDocumentLinks parses an HTML or JSON search result page; see
+// hillyard.ParseResults.
+func extractDocumentLinks(content string, baseURL string) []documentLink {
+	return hillyard.ParseResults(content, baseURL)
+}
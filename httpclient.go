@@ -0,0 +1,159 @@
+package main // Define the main package
+
+import (
+	"context"     // For per-phase timeouts
+	"crypto/tls"  // For disabling HTTP/2 negotiation and the TLS options
+	"crypto/x509" // For extra certificate authorities
+	"flag"        // For command-line flag parsing
+	"fmt"         // For rejecting unknown TLS versions
+	"log"         // For warning about disabled verification
+	"net"         // For the connect timeout
+	"net/http"    // For the shared client
+	"os"          // For reading -ca-cert
+	"sync"        // For building the client once
+	"time"        // For timeouts
+)
+
+var (
+	httpTimeout         time.Duration // Overall timeout of one request including the body, unless a phase sets its own
+	searchTimeout       time.Duration // Overall timeout of one search result page
+	downloadTimeout     time.Duration // Overall timeout of one PDF download
+	headerTimeout       time.Duration // Longest wait for response headers once the request is sent
+	connectTimeout      time.Duration // Longest wait for a TCP connection
+	maxIdleConnsPerHost int           // Idle keep-alive connections kept per host
+	idleConnTimeout     time.Duration // How long idle connections stay in the pool
+	disableKeepAlives   bool          // Open a fresh connection for every request
+	disableHTTP2        bool          // Stick to HTTP/1.1
+	caCertFile          string        // PEM bundle of extra certificate authorities, e.g. a corporate proxy's
+	tlsMinVersion       uint16        // Oldest TLS version accepted
+	insecureSkipVerify  bool          // Accept any certificate
+	sharedClient        *http.Client  // Used by every request of the run
+	sharedClientOnce    sync.Once     // Builds sharedClient after flag parsing
+	fetcher             Fetcher       // Sends every outgoing request; the shared client unless replaced
+	fetcherOnce         sync.Once     // Falls back to the shared client on first use
+)
+
+func init() {
+	flag.DurationVar(&httpTimeout, "http-timeout", 30*time.Second, "timeout of a single HTTP request, including reading the body, for requests without a phase timeout such as probes and webhooks") // Register the request timeout flag
+	flag.DurationVar(&searchTimeout, "search-timeout", 30*time.Second, "timeout of fetching one page of search results, so a hung search does not stall the crawl")                                  // Register the search timeout flag
+	flag.DurationVar(&downloadTimeout, "download-timeout", 10*time.Minute, "timeout of downloading one PDF, including the body, so large PDFs on slow links can finish")                             // Register the download timeout flag
+	flag.DurationVar(&headerTimeout, "header-timeout", 30*time.Second, "longest wait for the response headers of any request (0 = none)")                                                            // Register the header timeout flag
+	flag.DurationVar(&connectTimeout, "connect-timeout", 10*time.Second, "longest wait for a connection to be established (0 = none)")                                                               // Register the connect timeout flag
+	flag.IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 16, "idle keep-alive connections kept open per host")                                                                               // Register the pool size flag
+	flag.DurationVar(&idleConnTimeout, "idle-conn-timeout", 90*time.Second, "how long an idle keep-alive connection stays in the pool")                                                              // Register the idle timeout flag
+	flag.BoolVar(&disableKeepAlives, "disable-keep-alives", false, "open a new connection for every request")                                                                                        // Register the keep-alive flag
+	flag.BoolVar(&disableHTTP2, "disable-http2", false, "use HTTP/1.1 only")                                                                                                                         // Register the HTTP/2 flag
+	flag.StringVar(&caCertFile, "ca-cert", "", "PEM file of certificate authorities trusted in addition to the system ones, e.g. a corporate TLS-inspecting proxy's")                                // Register the CA flag
+	flag.BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "accept any TLS certificate; only for diagnosing proxy problems, as it allows tampered documents")                              // Register the verification flag
+	tlsMinVersion = tls.VersionTLS12
+	flag.Func("tls-min-version", "oldest TLS version accepted: 1.0, 1.1, 1.2 or 1.3 (default 1.2)", func(value string) error {
+		versions := map[string]uint16{"1.0": tls.VersionTLS10, "1.1": tls.VersionTLS11, "1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13} // Register the TLS version flag
+		version, known := versions[value]
+		if !known {
+			return fmt.Errorf("unknown TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", value)
+		}
+		tlsMinVersion = version
+		return nil
+	})
+}
+
+// httpClient returns the client shared by every request of the run, so
+// connections are pooled and reused across queries and downloads.
+func httpClient() *http.Client {
+	sharedClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone() // Keep proxy and dial defaults
+		transport.MaxIdleConns = 0                                   // No global cap, the per-host cap applies
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		transport.IdleConnTimeout = idleConnTimeout
+		transport.ResponseHeaderTimeout = headerTimeout
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext // Same keep-alive as the default transport
+		transport.DisableKeepAlives = disableKeepAlives
+		transport.DisableCompression = disableCompression // Downloads too
+		transport.ForceAttemptHTTP2 = !disableHTTP2
+		transport.TLSClientConfig = clientTLSConfig()
+		if disableHTTP2 { // An empty map turns off ALPN negotiation of h2
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		sharedClient = &http.Client{Transport: rateLimitTransport{next: transport, timeout: httpTimeout}, Jar: cookieJar} // Timeout is per attempt, rate-limit pauses do not count
+		if verboseOutput {                                                                                                // Trace every attempt, retries after rate limiting included
+			sharedClient.Transport = rateLimitTransport{next: traceTransport{next: transport}, timeout: httpTimeout}
+			sharedClient.CheckRedirect = traceRedirect
+		}
+	})
+	return sharedClient
+}
+
+// clientTLSConfig builds the TLS settings of the shared transport from
+// --ca-cert, --tls-min-version and --insecure-skip-verify.
+func clientTLSConfig() *tls.Config {
+	config := &tls.Config{MinVersion: tlsMinVersion, InsecureSkipVerify: insecureSkipVerify}
+	if insecureSkipVerify {
+		log.Printf("warning: -insecure-skip-verify is set, TLS certificates are not checked")
+	}
+	if caCertFile == "" {
+		return config
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil { // No system pool on this platform
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(caCertFile)
+	if err != nil {
+		fatalConfig("failed to read -ca-cert %s: %v", caCertFile, err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		fatalConfig("-ca-cert %s holds no PEM certificates", caCertFile)
+	}
+	config.RootCAs = pool
+	return config
+}
+
+// Fetcher sends HTTP requests. *http.Client satisfies it; the search,
+// download and probe code only talks to the network through it, so tests can
+// swap in an httptest server's client or a stub by setting fetcher before
+// the first request.
+type Fetcher interface {
+	Do(request *http.Request) (*http.Response, error) // Send one request
+}
+
+// currentFetcher returns the fetcher, defaulting to the shared client.
+func currentFetcher() Fetcher {
+	fetcherOnce.Do(func() {
+		if fetcher == nil { // Not replaced
+			fetcher = httpClient()
+		}
+	})
+	return fetcher
+}
+
+// fetchURL sends a bodiless request such as GET or HEAD through the fetcher.
+func fetchURL(method, url string) (*http.Response, error) {
+	startSession() // Log in before the first request
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return currentFetcher().Do(request)
+}
+
+// phaseTimeoutKey marks a request context with the timeout of its phase.
+type phaseTimeoutKey struct{}
+
+// fetchURLWithin is fetchURL with the timeout of a phase, e.g. --search-timeout,
+// in place of --http-timeout. Each rate-limit retry gets the full timeout.
+func fetchURLWithin(method, url string, timeout time.Duration) (*http.Response, error) {
+	startSession() // Log in before the first request
+	request, err := http.NewRequestWithContext(context.WithValue(context.Background(), phaseTimeoutKey{}, timeout), method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return currentFetcher().Do(request)
+}
+
+// requestTimeout returns the timeout of the request's phase, or fallback.
+func requestTimeout(request *http.Request, fallback time.Duration) time.Duration {
+	if timeout, set := request.Context().Value(phaseTimeoutKey{}).(time.Duration); set {
+		return timeout
+	}
+	return fallback
+}
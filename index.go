@@ -0,0 +1,304 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For collecting extracted text
+	"crypto/sha256" // For keying extracted text by content
+	"encoding/hex"  // For encoding hashes
+	"encoding/json" // For the term index
+	"errors"        // For detecting a missing index
+	"flag"          // For command-line flag parsing
+	"fmt"           // For wrapping extraction panics
+	"io"            // For hashing files
+	"log"           // For logging messages and errors
+	"os"            // For file operations
+	"path/filepath" // For building index paths
+	"runtime"       // For the default worker count
+	"slices"        // For deduplicating postings
+	"sort"          // For stable index output
+	"strings"       // For tokenizing text
+	"sync"          // For merging worker results
+	"sync/atomic"   // For progress counters
+	"unicode"       // For splitting text into terms
+
+	"github.com/ledongthuc/pdf" // Pure-Go PDF text extraction
+)
+
+var (
+	indexDir     string // Folder holding extracted text and the term index
+	indexWorkers int    // Number of documents extracted and tokenized at once
+)
+
+func init() {
+	flag.StringVar(&indexDir, "index-dir", "index", "folder holding extracted PDF text and the search index")         // Register the index folder flag
+	flag.IntVar(&indexWorkers, "index-workers", runtime.NumCPU(), "number of PDFs to extract and index concurrently") // Register the index worker flag
+}
+
+// analyzerVersion identifies how text is turned into terms. Bump it whenever
+// tokenize changes; indexes built by another version must be rebuilt with
+// the reindex command.
+const analyzerVersion = 1
+
+// indexedDocument is one PDF known to the term index.
+type indexedDocument struct {
+	File  string `json:"file"`  // Filename inside the PDF folder
+	Title string `json:"title"` // Product name from the manifest
+}
+
+// termIndex maps search terms to the documents containing them. It is a
+// plain JSON file rather than a Bleve index, which would pull dozens of
+// modules into a tool that otherwise needs a handful; see the README for
+// what that costs in size.
+type termIndex struct {
+	Analyzer  int                        `json:"analyzer"`  // analyzerVersion the terms were produced with
+	Documents map[string]indexedDocument `json:"documents"` // Content hash → document
+	Terms     map[string][]string        `json:"terms"`     // Term → content hashes
+}
+
+// Path of the extracted text of a document
+func extractedTextPath(hash string) string {
+	return filepath.Join(indexDir, "text", hash+".txt") // One text file per content hash
+}
+
+// Path of the term index
+func termIndexPath() string {
+	return filepath.Join(indexDir, "terms.json")
+}
+
+// loadTermIndex reads the term index, returning an empty one if none exists yet.
+func loadTermIndex() (termIndex, error) {
+	index := termIndex{Analyzer: analyzerVersion, Documents: make(map[string]indexedDocument), Terms: make(map[string][]string)}
+	content, err := os.ReadFile(termIndexPath()) // Read the stored index
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil // Nothing indexed yet
+	}
+	if err != nil {
+		return index, err
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return index, err
+	}
+	return index, nil
+}
+
+// runIndexCommand brings the term index up to date with the library.
+func runIndexCommand() {
+	updateIndex(false)
+}
+
+// runReindexCommand discards the term index and builds it from scratch, or
+// with "reindex manifest" adds the PDFs on disk to the manifest.
+func runReindexCommand(args []string) {
+	if len(args) > 0 && args[0] == "manifest" {
+		runBootstrapManifestCommand()
+		return
+	}
+	if len(args) > 0 {
+		fatalf("unknown reindex subcommand %q", args[0])
+	}
+	updateIndex(true)
+}
+
+// updateIndex extracts the text of every PDF in the library and updates the
+// term index. Documents are keyed by content hash, so only new or changed
+// PDFs are tokenized and documents that left the library are dropped. With
+// full set the stored terms are discarded first. Extraction runs on
+// --index-workers workers and is resumable: text is stored per content
+// hash, so an interrupted run picks up where it stopped and unchanged
+// documents are never extracted twice. With --ocr, documents whose text
+// layer is empty are read by OCR instead.
+func updateIndex(full bool) {
+	checkOCRTools()                                        // Fail before any work when -ocr cannot run
+	createDirectory(filepath.Join(indexDir, "text"), 0755) // Make sure the text folder exists
+	index, err := loadTermIndex()                          // Terms from earlier runs
+	if err != nil {
+		fatalf("failed to read index %s: %v", termIndexPath(), err)
+	}
+	if !full && index.Analyzer != analyzerVersion {
+		fatalf("index %s was built with analyzer version %d, this build uses %d; run reindex", termIndexPath(), index.Analyzer, analyzerVersion)
+	}
+	if full { // Start over with the current analyzer
+		index = termIndex{Analyzer: analyzerVersion, Documents: make(map[string]indexedDocument), Terms: make(map[string][]string)}
+	}
+	documents := documentManifest // The run's manifest when indexing after a crawl
+	if documents == nil {
+		documents = mustLoadManifest()
+	}
+	files := libraryPDFs()            // Every PDF in the library
+	titles := make(map[string]string) // Filename → product name
+	for _, entry := range documents.sortedEntries() {
+		titles[entry.File] = entry.Title
+	}
+	current := make(map[string]bool)                         // Hashes still in the library
+	claimed := make(map[string]bool)                         // Hashes a worker is indexing, so copies of a PDF are indexed once
+	var indexMutex sync.Mutex                                // Guards index, current and claimed while workers merge into them
+	var processed, extracted, recognized, added atomic.Int64 // Progress counters
+	runWorkerPool(files, indexWorkers, func(file string) {
+		defer reportProgress("indexing", processed.Add(1), len(files))
+		hash, err := hashFile(filepath.Join(outputDir, file)) // Key the text by content
+		if err != nil {
+			log.Printf("failed to hash %s: %v", file, err)
+			return
+		}
+		indexMutex.Lock()
+		current[hash] = true
+		_, known := index.Documents[hash] // Terms already indexed
+		if known {
+			index.Documents[hash] = indexedDocument{File: file, Title: titles[file]} // Pick up renames and new titles
+		}
+		duplicate := claimed[hash] // Another copy of the same PDF is being indexed
+		claimed[hash] = true
+		indexMutex.Unlock()
+		if known || duplicate {
+			return
+		}
+		text, err := os.ReadFile(extractedTextPath(hash)) // Reuse earlier extraction
+		fresh := err != nil                               // Extracted by this run
+		var extractErr error                              // Why the text layer could not be read
+		if fresh {
+			var extractedText string
+			extractedText, extractErr = extractPDFText(filepath.Join(outputDir, file))
+			text = []byte(extractedText)
+		}
+		if ocrEnabled && needsOCR(string(text)) { // A scan, or a text layer OCR may still improve on
+			if ocrText, err := ocrPDFText(filepath.Join(outputDir, file)); err != nil {
+				log.Printf("failed to OCR %s: %v", file, err)
+			} else if !needsOCR(ocrText) {
+				text, fresh, extractErr = []byte(ocrText), true, nil
+				recognized.Add(1)
+			}
+		}
+		if extractErr != nil { // Neither the text layer nor OCR could be read, try again next run
+			log.Printf("failed to extract text from %s: %v", file, extractErr)
+			return
+		}
+		if fresh {
+			if _, err := writeFileAtomically(extractedTextPath(hash), bytes.NewReader(text), int64(len(text))); err != nil {
+				log.Printf("failed to store text of %s: %v", file, err)
+				return
+			}
+			extracted.Add(1)
+		}
+		terms := tokenize(string(text)) // Unique terms of the document
+		indexMutex.Lock()
+		index.Documents[hash] = indexedDocument{File: file, Title: titles[file]}
+		for _, term := range terms {
+			index.Terms[term] = append(index.Terms[term], hash)
+		}
+		indexMutex.Unlock()
+		added.Add(1)
+	})
+	removed := 0 // Documents no longer in the library
+	for hash := range index.Documents {
+		if !current[hash] {
+			delete(index.Documents, hash)
+			removed++
+		}
+	}
+	for term, hashes := range index.Terms {
+		kept := hashes[:0] // Postings of documents still indexed
+		for _, hash := range hashes {
+			if _, ok := index.Documents[hash]; ok {
+				kept = append(kept, hash)
+			}
+		}
+		if len(kept) == 0 {
+			delete(index.Terms, term)
+			continue
+		}
+		sort.Strings(kept)                       // Worker order is not deterministic
+		index.Terms[term] = slices.Compact(kept) // Drop duplicate postings left by earlier versions
+	}
+	content, err := json.Marshal(index) // Encode the index
+	if err != nil {
+		fatalln(err)
+	}
+	if _, err := writeFileAtomically(termIndexPath(), bytes.NewReader(content), int64(len(content))); err != nil {
+		fatalln(err)
+	}
+	log.Printf("indexed %d documents (%d added, %d removed, %d newly extracted, %d by OCR), %d terms", len(index.Documents), added.Load(), removed, extracted.Load(), recognized.Load(), len(index.Terms))
+	recordHazards(documents) // The text is at hand now
+}
+
+// libraryPDFs lists the PDF files in the PDF folder and its document type
+// subfolders, as paths relative to the PDF folder.
+func libraryPDFs() []string {
+	entries, err := os.ReadDir(outputDir) // Top level, where SDS live
+	if err != nil {
+		log.Println(err) // Log error
+		return nil
+	}
+	files := pdfNames("", entries) // PDF filenames
+	for _, docType := range documentTypes {
+		if docType.Folder == "" {
+			continue
+		}
+		if entries, err := os.ReadDir(filepath.Join(outputDir, docType.Folder)); err == nil { // Absent until the type is mirrored
+			files = append(files, pdfNames(docType.Folder+"/", entries)...)
+		}
+	}
+	return files
+}
+
+// pdfNames returns the visible PDFs among entries, prefixed with folder.
+func pdfNames(folder string, entries []os.DirEntry) []string {
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".pdf") && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, folder+entry.Name())
+		}
+	}
+	return files
+}
+
+// hashFile returns the hex SHA-256 of a file.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path) // Open the file
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// extractPDFText returns the plain text of a PDF. The PDF library panics on
+// some malformed files, so panics are turned into errors.
+func extractPDFText(path string) (text string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("malformed PDF: %v", recovered)
+		}
+	}()
+	file, reader, err := pdf.Open(path) // Parse the PDF structure
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	plainText, err := reader.GetPlainText() // Text of all pages
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	if _, err := buffer.ReadFrom(plainText); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// tokenize splits text into unique lowercase terms of two or more characters.
+func tokenize(text string) []string {
+	seen := make(map[string]bool) // Terms already returned
+	var terms []string
+	for _, term := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) // Split on anything that is not a word character
+	}) {
+		if len([]rune(term)) >= 2 && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
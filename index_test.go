@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateIndexPostsCopiesOnce(t *testing.T) {
+	useTestOrigin(t, http.NotFoundHandler())
+	setGlobal(t, &indexDir, filepath.Join(t.TempDir(), "index"))
+	setGlobal(t, &indexWorkers, 8)
+	content := []byte("%PDF-1.7 the same sheet stored under many names\n")
+	for number := range 20 {
+		if err := os.WriteFile(filepath.Join(outputDir, fmt.Sprintf("copy-%d.pdf", number)), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(filepath.Join(outputDir, "copy-0.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(extractedTextPath(hash)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(extractedTextPath(hash), []byte("sodium hypochlorite bleach"), 0644); err != nil { // Skip extraction
+		t.Fatal(err)
+	}
+	updateIndex(true)
+	index, err := loadTermIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, term := range []string{"sodium", "hypochlorite", "bleach"} {
+		if postings := index.Terms[term]; len(postings) != 1 || postings[0] != hash {
+			t.Errorf("postings of %q = %v, want the one content hash", term, postings)
+		}
+	}
+
+	index.Terms["bleach"] = []string{hash, hash} // As saved by a version that posted copies twice
+	saved, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(termIndexPath(), saved, 0644); err != nil {
+		t.Fatal(err)
+	}
+	updateIndex(false)
+	if index, _ := loadTermIndex(); len(index.Terms["bleach"]) != 1 {
+		t.Errorf("postings of \"bleach\" = %v after an update, want the duplicate dropped", index.Terms["bleach"])
+	}
+}
@@ -0,0 +1,37 @@
+package main // Define the main package
+
+import (
+	"sync" // For guarding the registry across workers
+)
+
+var (
+	inFlightDownloads = make(map[string]chan struct{}) // URL → closed once its download finishes
+	inFlightMutex     sync.Mutex                       // Guards inFlightDownloads
+)
+
+// claimDownload registers link as being downloaded by the caller. When
+// another worker already holds it, e.g. because two handler links lead to
+// the same PDF or two portal visitors asked for the same document, the
+// caller waits until that download is over and gets false: the file is
+// stored by then, or the attempt failed and is not repeated in parallel.
+func claimDownload(link string) bool {
+	inFlightMutex.Lock()
+	done, busy := inFlightDownloads[link]
+	if !busy {
+		inFlightDownloads[link] = make(chan struct{})
+	}
+	inFlightMutex.Unlock()
+	if busy {
+		<-done // Let the other worker finish writing the file
+		return false
+	}
+	return true
+}
+
+// releaseDownload marks the download of link as over and wakes the waiters.
+func releaseDownload(link string) {
+	inFlightMutex.Lock()
+	close(inFlightDownloads[link])
+	delete(inFlightDownloads, link)
+	inFlightMutex.Unlock()
+}
@@ -0,0 +1,153 @@
+package main // Define the main package
+
+import (
+	"bufio"         // For reading the journal line by line
+	"encoding/json" // For the journal records
+	"log"           // For logging the recovery
+	"os"            // For appending to the journal
+	"path/filepath" // For the journal path
+	"sync"          // For serializing appends across workers
+	"time"          // For record times
+)
+
+// Operations the journal records
+const (
+	journalQueryIssued       = "query_issued"       // A search was sent
+	journalQueryCompleted    = "query_completed"    // Its results were saved
+	journalLinkDiscovered    = "link_discovered"    // A link was considered for download
+	journalDownloadStarted   = "download_started"   // A download was sent
+	journalDownloadCompleted = "download_completed" // Its file is stored under File
+)
+
+// journalRecord is one line of the journal.
+type journalRecord struct {
+	Op     string         `json:"op"`              // One of the operations above
+	Target string         `json:"target"`          // Query target or discovered URL
+	File   string         `json:"file,omitempty"`  // Stored filename of a completed download
+	Entry  *manifestEntry `json:"entry,omitempty"` // Manifest entry of a completed download
+	Time   time.Time      `json:"time"`            // When it happened
+}
+
+var (
+	journal           *os.File        // Open journal, nil until the first record
+	journalFailed     bool            // Set after a write error, so it is reported once
+	journalMutex      sync.Mutex      // Guards the journal and the sets below
+	journalQueries    map[string]bool // Queries a crashed run completed
+	journalDownloads  map[string]bool // Links a crashed run stored
+	journalUnreplayed bool            // Downloads were recovered without a manifest to record them in
+	journalRecoverOne sync.Once       // Replays the journal once per process
+)
+
+// Path of the write-ahead journal of the running pipeline
+func journalPath() string {
+	return filepath.Join(givenFolder, "journal.jsonl") // Next to the state it protects
+}
+
+// appendJournal writes one record and syncs it to disk before the work it
+// announces goes on, so a crash loses at most the operation in flight.
+func appendJournal(record journalRecord) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	if journalFailed {
+		return
+	}
+	if journal == nil {
+		file, err := os.OpenFile(journalPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Printf("failed to open journal %s, an interrupted run will not be resumed exactly: %v", journalPath(), err)
+			journalFailed = true
+			return
+		}
+		journal = file
+	}
+	record.Time = time.Now().UTC()
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = journal.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = journal.Sync()
+	}
+	if err != nil {
+		log.Printf("failed to write journal %s, an interrupted run will not be resumed exactly: %v", journalPath(), err)
+		journalFailed = true
+	}
+}
+
+// recoverJournal replays the journal a crashed run left behind: the
+// downloads it completed go into the manifest and the seen-URL index, which
+// are only saved at the end of a run, and the searches it completed are
+// dropped from the queue. Without a loaded manifest only the searches are
+// replayed; the journal stays until a run saves the manifest.
+func recoverJournal() {
+	journalRecoverOne.Do(func() {
+		file, err := os.Open(journalPath())
+		if err != nil { // The last run finished cleanly
+			return
+		}
+		defer file.Close()
+		journalQueries, journalDownloads = make(map[string]bool), make(map[string]bool)
+		started, recovered := make(map[string]bool), 0
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20) // Entries with long titles
+		for scanner.Scan() {
+			var record journalRecord
+			if json.Unmarshal(scanner.Bytes(), &record) != nil { // The line the crash cut short
+				continue
+			}
+			switch record.Op {
+			case journalQueryCompleted:
+				journalQueries[record.Target] = true
+			case journalDownloadStarted:
+				started[record.Target] = true
+			case journalDownloadCompleted:
+				delete(started, record.Target)
+				if record.File == "" || !fileExists(filepath.Join(outputDir, record.File)) { // Deleted since
+					continue
+				}
+				journalDownloads[record.Target] = true
+				if documentManifest == nil { // Left for a run that loads it
+					journalUnreplayed = true
+				} else if record.Entry != nil {
+					documentManifest.record(*record.Entry)
+				}
+				markSeen(record.Target, record.File)
+				recovered++
+			}
+		}
+		log.Printf("resuming an interrupted run: %d searches and %d downloads completed, %d downloads cut short", len(journalQueries), recovered, len(started))
+	})
+}
+
+// resumeQueue drops the items a crashed run completed from a queue.
+func resumeQueue(queue []queueItem) []queueItem {
+	recoverJournal()
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	var pending []queueItem
+	for _, item := range queue {
+		if item.Kind == queueKindQuery && journalQueries[item.Target] || item.Kind == queueKindDownload && journalDownloads[item.Target] {
+			continue
+		}
+		pending = append(pending, item)
+	}
+	return pending
+}
+
+// checkpointJournal clears the journal once the state it protects is saved,
+// unless it holds downloads no manifest has taken in yet.
+func checkpointJournal() {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	if documentManifest == nil && journalUnreplayed {
+		return
+	}
+	if journal != nil {
+		journal.Close()
+		journal = nil
+	}
+	if err := os.Remove(journalPath()); err != nil && !os.IsNotExist(err) {
+		log.Println(err) // Log error
+	}
+	journalQueries, journalDownloads = nil, nil
+}
@@ -0,0 +1,167 @@
+package main // Define the main package
+
+import (
+	"encoding/json" // For persisting detected languages
+	"flag"          // For command-line flag parsing
+	"log"           // For logging messages and errors
+	"net/url"       // For isolating the filename of a link
+	"os"            // For reading and writing the cache file
+	"path"          // For the last URL path segment
+	"path/filepath" // For building the cache path
+	"strings"       // For tokenizing names
+	"sync"          // For guarding the cache across workers
+	"unicode"       // For splitting names into words
+)
+
+var (
+	allowedLanguages       map[string]bool   // Language codes to keep (nil = all)
+	documentLanguages      map[string]string // Document URL → detected language code
+	documentLanguagesMutex sync.Mutex        // Guards documentLanguages
+)
+
+func init() {
+	flag.Func("languages", "only keep SDS in these languages, e.g. en,fr (default all)", func(value string) error {
+		allowedLanguages = make(map[string]bool) // Register the language filter flag
+		for _, code := range strings.Split(value, ",") {
+			if code = strings.ToLower(strings.TrimSpace(code)); code != "" {
+				allowedLanguages[code] = true
+			}
+		}
+		return nil
+	})
+}
+
+// languageNames maps words that name a language in titles and URLs to codes.
+var languageNames = map[string]string{
+	"english": "en",
+	"french":  "fr", "francais": "fr", "français": "fr",
+	"spanish": "es", "espanol": "es", "español": "es",
+	"german": "de", "deutsch": "de",
+	"portuguese": "pt", "portugues": "pt", "português": "pt",
+	"italian": "it", "italiano": "it",
+	"chinese": "zh", "japanese": "ja", "korean": "ko",
+}
+
+// languageStopwords are frequent words that identify a language in PDF text.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "with", "for", "is", "or", "not", "this"},
+	"fr": {"le", "la", "les", "des", "et", "du", "une", "pour", "avec", "est"},
+	"es": {"el", "los", "las", "del", "y", "para", "con", "por", "una", "es"},
+	"de": {"der", "die", "das", "und", "mit", "für", "ist", "nicht", "den", "von"},
+	"pt": {"o", "os", "do", "da", "dos", "em", "não", "para", "com", "uma"},
+	"it": {"il", "gli", "della", "per", "con", "non", "sono", "una", "di", "che"},
+}
+
+// Path of the cache of detected document languages
+func documentLanguagesPath() string {
+	return filepath.Join(givenFolder, "languages.json") // Lives next to the search results
+}
+
+// Report whether the language filter is configured
+func filteringLanguages() bool {
+	return len(allowedLanguages) > 0
+}
+
+// languageAllowed reports whether a language passes --languages. Unknown
+// languages pass, so a missing marker never hides a document.
+func languageAllowed(language string) bool {
+	return !filteringLanguages() || language == "" || allowedLanguages[language]
+}
+
+// linkLanguage guesses the language of a document from earlier detection,
+// then from language names in its title or URL filename and a trailing
+// two-letter code in the filename (e.g. "floor-finish-sds-fr.pdf"). It
+// returns "" if unsure.
+func linkLanguage(link, title string) string {
+	documentLanguagesMutex.Lock()
+	loadDocumentLanguagesLocked()
+	language := documentLanguages[link] // Detected from the text by an earlier run
+	documentLanguagesMutex.Unlock()
+	if language != "" {
+		return language
+	}
+	filename := link // Fall back to the whole link if it cannot be parsed
+	if parsed, err := url.Parse(link); err == nil {
+		filename = path.Base(parsed.Path)
+	}
+	filename = strings.TrimSuffix(strings.ToLower(filename), ".pdf")
+	for _, word := range languageWords(title + " " + filename) {
+		if code, known := languageNames[word]; known {
+			return code
+		}
+	}
+	words := languageWords(filename) // Only a trailing code is a reliable marker
+	if len(words) > 1 {
+		if _, known := languageStopwords[words[len(words)-1]]; known {
+			return words[len(words)-1]
+		}
+	}
+	return ""
+}
+
+// Split a title or filename into lowercase words
+func languageWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) // Separators, digits and punctuation
+	})
+}
+
+// detectTextLanguage returns the language whose stopwords occur most often
+// in text, or "" when the text is too short or has no clear winner.
+func detectTextLanguage(text string) string {
+	counts := make(map[string]int) // Language → stopword hits
+	for _, word := range languageWords(text) {
+		for code, stopwords := range languageStopwords {
+			if containsString(stopwords, word) {
+				counts[code]++
+			}
+		}
+	}
+	best, bestCount, total := "", 0, 0
+	for code, count := range counts {
+		total += count
+		if count > bestCount || (count == bestCount && code < best) { // Ties break alphabetically for stable results
+			best, bestCount = code, count
+		}
+	}
+	if bestCount < 20 || bestCount*2 < total { // Too little text or too mixed to tell
+		return ""
+	}
+	return best
+}
+
+// rememberDocumentLanguage caches the detected language of a document URL.
+func rememberDocumentLanguage(link, language string) {
+	documentLanguagesMutex.Lock()
+	defer documentLanguagesMutex.Unlock()
+	loadDocumentLanguagesLocked()
+	documentLanguages[link] = language
+}
+
+// Load the language cache if needed; the caller holds documentLanguagesMutex
+func loadDocumentLanguagesLocked() {
+	if documentLanguages != nil { // Already loaded
+		return
+	}
+	documentLanguages = make(map[string]string)
+	if content, err := os.ReadFile(documentLanguagesPath()); err == nil {
+		json.Unmarshal(content, &documentLanguages) // A corrupt cache just means detecting again
+	}
+}
+
+// Persist the detected languages for the next run
+func saveDocumentLanguages() {
+	documentLanguagesMutex.Lock()
+	defer documentLanguagesMutex.Unlock()
+	if len(documentLanguages) == 0 { // Nothing was detected this run
+		return
+	}
+	content, err := json.MarshalIndent(documentLanguages, "", "  ") // Encode the cache
+	if err != nil {
+		log.Println(err) // Log error
+		return
+	}
+	if err := os.WriteFile(documentLanguagesPath(), content, 0644); err != nil {
+		log.Println(err) // Log error
+	}
+}
@@ -0,0 +1,236 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For checking a stale lock before removing it
+	"encoding/json" // For the lock file
+	"errors"        // For telling a held lock from other errors
+	"flag"          // For command-line flag parsing
+	"fmt"           // For describing the holder
+	"io/fs"         // For fs.ErrExist
+	"log"           // For reporting the holder
+	"os"            // For creating the lock file
+	"path/filepath" // For the lock path
+	"sync"          // For sharing the lock between passes
+	"time"          // For staleness
+)
+
+// lockingCommands are the commands that write the data folders and so must
+// not run twice against the same ones at once; the rest only read. daemon
+// and serve run for days, so they lock each pass that writes instead (see
+// lockPass).
+var lockingCommands = map[string]bool{
+	"run": true, "mirror": true, "discover": true, "download": true,
+	"db": true, "objects": true, "filenames": true, "category-farm": true, "index": true, "reindex": true,
+	"review": true, "approve": true, "attach": true, "barcode": true, "request": true, "snapshot": true,
+}
+
+var (
+	lockStaleAfter  time.Duration // Age of an unrefreshed lock after which its holder is presumed dead
+	heldLock        string        // Path of the lock this process holds, "" if none
+	lockPasses      int           // Passes of this process using the held lock
+	heldLockMutex   sync.Mutex    // Guards heldLock and lockPasses
+	lockRefreshOnce sync.Once     // Starts refreshDataLock with the first lock
+)
+
+func init() {
+	flag.DurationVar(&lockStaleAfter, "lock-stale-after", 10*time.Minute, "take over a data folder lock whose holder stopped refreshing it this long ago, e.g. after a crash on another host") // Register the lock staleness flag
+}
+
+// dataLock is the content of the lock file.
+type dataLock struct {
+	PID       int       `json:"pid"`        // Process holding the lock
+	Host      string    `json:"host"`       // Host the process runs on
+	Command   string    `json:"command"`    // Command it runs
+	StartedAt time.Time `json:"started_at"` // When it took the lock
+}
+
+// String describes the holder for log messages.
+func (holder dataLock) String() string {
+	return fmt.Sprintf("pid %d on %s, running %s since %s", holder.PID, holder.Host, holder.Command, holder.StartedAt.Local().Format(time.DateTime))
+}
+
+// Path of the lock of the data folders
+func dataLockPath() string {
+	return filepath.Join(givenFolder, "hillyard.lock") // Next to the search results, which every writer touches
+}
+
+// acquireDataLock takes the lock of the data folders for a command that
+// writes them, taking over a stale one, or exits with exitLocked while
+// another instance holds it, e.g. an overlapping cron job.
+func acquireDataLock(command string) {
+	heldLockMutex.Lock()
+	defer heldLockMutex.Unlock()
+	holder, acquired := tryDataLockLocked(command)
+	if !acquired {
+		log.Printf("another instance is using %s: %s; refusing to start (remove %s if that process is gone)", givenFolder, holder, dataLockPath())
+		os.Exit(exitLocked)
+	}
+}
+
+// lockPass takes the lock for one pass of a long-running mode that writes
+// the data folders, e.g. a daemon crawl or a read-through download, and
+// reports false while another process holds it. Passes of one process share
+// the lock. Whoever takes it fresh drops the state cached in memory, as other
+// processes may have changed the files since.
+func lockPass(command string) bool {
+	heldLockMutex.Lock()
+	defer heldLockMutex.Unlock()
+	if lockPasses > 0 {
+		lockPasses++
+		return true
+	}
+	holder, acquired := tryDataLockLocked(command)
+	if !acquired {
+		log.Printf("%s: another instance is using %s: %s; trying again later", command, givenFolder, holder)
+		return false
+	}
+	lockPasses = 1
+	forgetCachedState()
+	return true
+}
+
+// unlockPass ends a pass of lockPass, releasing the lock after the last one.
+func unlockPass() {
+	heldLockMutex.Lock()
+	defer heldLockMutex.Unlock()
+	if lockPasses--; lockPasses == 0 {
+		releaseDataLockLocked()
+	}
+}
+
+// tryDataLockLocked creates the lock file, taking over a stale one, or
+// returns its live holder; the caller holds heldLockMutex.
+func tryDataLockLocked(command string) (dataLock, bool) {
+	host, _ := os.Hostname()
+	content, err := json.Marshal(dataLock{PID: os.Getpid(), Host: host, Command: command, StartedAt: time.Now().UTC()})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var holder dataLock // Last holder seen
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(dataLockPath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644) // Only one process can create it
+		if err == nil {
+			_, err = file.Write(content)
+			file.Close()
+			if err != nil {
+				os.Remove(dataLockPath())
+				log.Fatalf("failed to write lock %s: %v", dataLockPath(), err)
+			}
+			heldLock = dataLockPath()
+			lockRefreshOnce.Do(func() { go refreshDataLock() })
+			return holder, true
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			log.Fatalf("failed to create lock %s: %v", dataLockPath(), err)
+		}
+		held, stale := []byte(nil), false
+		if holder, held, stale = readDataLock(host); !stale {
+			return holder, false
+		}
+		log.Printf("taking over the stale lock of pid %d on %s (%s)", holder.PID, holder.Host, holder.Command)
+		if !removeStaleLock(dataLockPath(), held) {
+			return holder, false // Another instance took it over first
+		}
+	}
+	return holder, false // Another instance took it while this one was starting
+}
+
+// readDataLock returns the holder of the lock, the content of the lock file
+// and whether it is stale: its process is gone from this host, or it
+// stopped refreshing the lock.
+func readDataLock(host string) (dataLock, []byte, bool) {
+	var holder dataLock
+	info, err := os.Stat(dataLockPath())
+	if err != nil { // Released in the meantime
+		return holder, nil, true
+	}
+	content, err := os.ReadFile(dataLockPath())
+	if err != nil || json.Unmarshal(content, &holder) != nil { // Half written by a process that died
+		return holder, content, time.Since(info.ModTime()) > time.Minute
+	}
+	if holder.Host == host && !processAlive(holder.PID) {
+		return holder, content, true
+	}
+	return holder, content, time.Since(info.ModTime()) > lockStaleAfter
+}
+
+// removeStaleLock removes a lock file judged stale, provided it still holds
+// the content that was judged. Removing it by name could delete the fresh
+// lock of another process that judged it stale too and took it over first,
+// so the file is renamed aside and checked; a fresh lock found there is put
+// back and false returned.
+func removeStaleLock(path string, stale []byte) bool {
+	aside := fmt.Sprintf("%s.%d.stale", path, os.Getpid()) // Only this process uses the name
+	if err := os.Rename(path, aside); err != nil {
+		return errors.Is(err, fs.ErrNotExist) // Released in the meantime, so free to create
+	}
+	defer os.Remove(aside)
+	if content, err := os.ReadFile(aside); err == nil && bytes.Equal(content, stale) {
+		return true
+	}
+	os.Link(aside, path) // Put the other process's lock back, unless a third one created one already
+	return false
+}
+
+// refreshDataLock touches the lock every minute so other hosts sharing the
+// folders can tell a live holder from a crashed one.
+func refreshDataLock() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		heldLockMutex.Lock()
+		if heldLock != "" {
+			os.Chtimes(heldLock, now, now)
+		}
+		heldLockMutex.Unlock()
+	}
+}
+
+// releaseDataLock removes the lock this process holds; exit calls it before
+// every other way out. A process that crashes or is killed leaves a lock the
+// next start recognizes as stale.
+func releaseDataLock() {
+	heldLockMutex.Lock()
+	defer heldLockMutex.Unlock()
+	releaseDataLockLocked()
+}
+
+// releaseDataLockLocked removes the lock; the caller holds heldLockMutex.
+func releaseDataLockLocked() {
+	if heldLock != "" {
+		os.Remove(heldLock)
+		heldLock, lockPasses = "", 0
+	}
+}
+
+// forgetCachedState drops the caches loaded from the data folders and
+// reloads the manifest in place, so a long-running process picks up what
+// other processes wrote while it did not hold the lock.
+func forgetCachedState() {
+	if documentManifest != nil {
+		if loaded, err := loadManifest(manifestFile); err != nil {
+			log.Printf("failed to reload manifest %s: %v", manifestFile, err)
+		} else {
+			documentManifest.mutex.Lock()
+			documentManifest.Entries = loaded.Entries
+			documentManifest.mutex.Unlock()
+		}
+	}
+	filenameOwnersMutex.Lock()
+	filenameOwners = nil // Rebuilt from the manifest
+	filenameOwnersMutex.Unlock()
+	seenURLsMutex.Lock()
+	seenFilter, seenShards = nil, nil
+	seenURLsMutex.Unlock()
+	resolvedLinksMutex.Lock()
+	resolvedLinks, dispositionNames = nil, nil
+	resolvedLinksMutex.Unlock()
+	documentLanguagesMutex.Lock()
+	documentLanguages = nil
+	documentLanguagesMutex.Unlock()
+	originHealthMutex.Lock()
+	originHealthState = nil
+	originHealthMutex.Unlock()
+	queryStatsMutex.Lock()
+	queryStatsState = nil
+	queryStatsMutex.Unlock()
+}
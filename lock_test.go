@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleLock(t *testing.T) {
+	tests := []struct {
+		name        string
+		onDisk      string // Lock file content when the takeover starts, "" for none
+		judged      string // Content judged stale
+		wantRemoved bool
+	}{
+		{"still the stale lock", `{"pid":1}`, `{"pid":1}`, true},
+		{"taken over by another process first", `{"pid":2}`, `{"pid":1}`, false},
+		{"released meanwhile", "", `{"pid":1}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hillyard.lock")
+			if test.onDisk != "" {
+				if err := os.WriteFile(path, []byte(test.onDisk), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if removed := removeStaleLock(path, []byte(test.judged)); removed != test.wantRemoved {
+				t.Fatalf("removeStaleLock() = %t, want %t", removed, test.wantRemoved)
+			}
+			content, err := os.ReadFile(path)
+			if test.wantRemoved && err == nil {
+				t.Errorf("lock %q left in place", content)
+			}
+			if !test.wantRemoved && string(content) != test.onDisk {
+				t.Errorf("lock holds %q (%v), want the other process's %q back", content, err, test.onDisk)
+			}
+			if leftovers, _ := filepath.Glob(path + ".*"); len(leftovers) != 0 {
+				t.Errorf("left %v behind", leftovers)
+			}
+		})
+	}
+}
+
+func TestUpdatePolitenessBreaksStaleLock(t *testing.T) {
+	setGlobal(t, &politenessFile, filepath.Join(t.TempDir(), "politeness.json"))
+	lockPath := politenessFile + ".lock"
+	if err := os.WriteFile(lockPath, []byte("1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crashed := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, crashed, crashed); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := updatePoliteness(ctx, func(state map[string]*hostPoliteness) {}); err != nil {
+		t.Fatalf("updatePoliteness() = %v", err)
+	}
+	if leftovers, _ := filepath.Glob(lockPath + "*"); len(leftovers) != 0 {
+		t.Errorf("left %v behind", leftovers)
+	}
+}
@@ -0,0 +1,63 @@
+package main // Define the main package
+
+import (
+	"fmt"     // For printing results
+	"log"     // For usage errors
+	"strings" // For matching product names
+)
+
+// Exit codes of the lookup commands, for shell scripts
+const (
+	lookupFound    = 0 // At least one document matched
+	lookupNotFound = 1 // No document matched
+	lookupUsage    = 2 // Missing product argument
+)
+
+// findProductDocuments returns the mirrored documents of a product, matched
+// case-insensitively against the product name or, for documents without a
+// name, the stored filename with or without its .pdf extension.
+func findProductDocuments(product string) []manifestEntry {
+	return matchProductDocuments(mustLoadManifest().currentEntries(), product)
+}
+
+// matchProductDocuments filters already loaded entries like findProductDocuments.
+func matchProductDocuments(entries []manifestEntry, product string) []manifestEntry {
+	var matches []manifestEntry // Documents of the product
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Title, product) || strings.EqualFold(entry.File, product) || strings.EqualFold(strings.TrimSuffix(entry.File, ".pdf"), product) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// lookupCommand builds a has/path-of/hash-of command. It prints one line per
+// matching document (nothing for has) and exits 0 when the product is in the
+// mirror, 1 when it is not and 2 on bad usage.
+func lookupCommand(name string, line func(entry manifestEntry) string) func(args []string) {
+	return func(args []string) {
+		if len(args) == 0 {
+			log.Printf("usage: %s <product>", name)
+			exit(lookupUsage)
+		}
+		product := strings.Join(args, " ")       // Unquoted names still work
+		matches := findProductDocuments(product) // Documents of the product
+		if len(matches) == 0 {                   // Let the safety team know somebody needed it
+			recordDocumentRequest(product, requestSourceLookup, "")
+		}
+		if jsonOutput() {
+			if matches == nil {
+				matches = []manifestEntry{}
+			}
+			printJSON(matches)
+		} else if line != nil {
+			for _, entry := range matches {
+				fmt.Println(line(entry))
+			}
+		}
+		if len(matches) == 0 {
+			exit(lookupNotFound)
+		}
+		exit(lookupFound)
+	}
+}
@@ -1,7 +1,11 @@
 package main // Define the main package
 
 import (
-	"bytes"         // For buffering I/O
+	"context"       // For skipping downloads from -tui
+	"crypto/sha256" // For hashing downloaded PDFs
+	"encoding/hex"  // For encoding hashes
+	"flag"          // For command-line flag parsing
+	"fmt"           // For building error messages
 	"io"            // For reading from response bodies
 	"log"           // For logging messages and errors
 	"net/http"      // For HTTP client/server interactions
@@ -15,22 +19,321 @@ import (
 )
 
 var (
-	givenFolder string // Folder where JSON results will be saved
-	outputDir   string // Folder where downloaded PDFs will be stored
+	givenFolder         string // Folder where JSON results will be saved
+	outputDir           string // Folder where downloaded PDFs will be stored
+	searchConcurrency   int    // Maximum number of search requests in flight at once
+	downloadConcurrency int    // Maximum number of PDF downloads in flight at once
+	maxSearchPages      int    // Most result pages fetched per query
 )
 
 func init() {
-	givenFolder = "assets/"            // Set the default folder for result files
+	flag.IntVar(&searchConcurrency, "search-concurrency", 4, "maximum number of concurrent search API requests")                             // Register the search concurrency flag
+	flag.IntVar(&maxSearchPages, "max-search-pages", 50, "most result pages followed per search query")                                      // Register the pagination limit flag
+	flag.IntVar(&downloadConcurrency, "download-concurrency", 4, "maximum number of concurrent PDF downloads")                               // Register the download concurrency flag
+	flag.StringVar(&givenFolder, "assets-dir", "assets", "folder where search results are saved (drive-letter and UNC paths are supported)") // Register the assets folder flag
+	flag.StringVar(&outputDir, "pdf-dir", "PDFs", "folder where downloaded PDFs are stored (drive-letter and UNC paths are supported)")      // Register the PDF folder flag
+}
+
+// Normalize the configured storage paths and create the folders
+func prepareStorage() {
+	givenFolder = storagePath(givenFolder)                   // Normalize the result folder
+	outputDir = storagePath(outputDir)                       // Normalize the PDF folder
+	failuresFile = storagePath(failuresFile)                 // Normalize the failure report path
+	localDir = storagePath(localDir)                         // Normalize the local documents folder
+	manifestFile = storagePath(manifestFile)                 // Normalize the manifest path
+	exportDir = storagePath(exportDir)                       // Normalize the export folder
+	indexDir = storagePath(indexDir)                         // Normalize the index folder
+	snapshotDir = storagePath(snapshotDir)                   // Normalize the snapshot folder
+	archiveDir = storagePath(archiveDir)                     // Normalize the archive folder
+	versionsDir = storagePath(versionsDir)                   // Normalize the versions folder
+	requestsFile = storagePath(requestsFile)                 // Normalize the request log path
+	barcodesFile = storagePath(barcodesFile)                 // Normalize the barcode table path
+	approvedManifestFile = storagePath(approvedManifestFile) // Normalize the approved manifest path
+	reportsDir = storagePath(reportsDir)                     // Normalize the run history folder
+	eventsFile = storagePath(eventsFile)                     // Normalize the event stream path
+	if queueFile != "" {                                     // Normalize the hand-edited queue path
+		queueFile = storagePath(queueFile)
+	}
+	if logFile != "" { // Normalize the log file path
+		logFile = storagePath(logFile)
+	}
+	if facilityContactsFile != "" { // Normalize the facility contact list path
+		facilityContactsFile = storagePath(facilityContactsFile)
+	}
+	if caCertFile != "" { // Normalize the certificate authority path
+		caCertFile = storagePath(caCertFile)
+	}
+	if categoryDir != "" { // Normalize the category folder
+		categoryDir = storagePath(categoryDir)
+	}
+	if stagingDir != "" { // Normalize and create the local staging folder
+		stagingDir = storagePath(stagingDir)
+		if err := os.MkdirAll(stagingDir, 0755); err != nil {
+			fatalConfig("failed to create -staging-dir %s: %v", stagingDir, err)
+		}
+	}
+	if urlListFile != "" { // Normalize the URL list path
+		urlListFile = storagePath(urlListFile)
+	}
+	if cookieFile != "" { // Normalize the cookies.txt path
+		cookieFile = storagePath(cookieFile)
+	}
+	if loginFieldsFile != "" { // Normalize the login form path
+		loginFieldsFile = storagePath(loginFieldsFile)
+	}
+	if queriesFile != "" { // Normalize the operator's search terms path
+		queriesFile = storagePath(queriesFile)
+	}
+	if signManifest { // A key that does not work should stop the run before the crawl, not after it
+		if signingKey == "" {
+			fatalConfig("-sign-manifest needs -signing-key")
+		}
+		if _, err := loadSigner(signingKey); err != nil {
+			fatalConfig("failed to read signing key %s: %v", signingKey, err)
+		}
+	}
+	if prune { // Pruning after a partial search would throw away most of the library
+		if reason := narrowedQueries(); reason != "" {
+			fatalConfig("-prune needs the full catalog search, but %s", reason)
+		}
+	}
 	if !directoryExists(givenFolder) { // Check if the directory exists
 		createDirectory(givenFolder, 0755) // Create it if not present with 0755 permissions
 	}
-	outputDir = "PDFs/"              // Set the default output directory for PDFs
 	if !directoryExists(outputDir) { // Check if it exists
 		createDirectory(outputDir, 0755) // Create it if missing
 	}
 }
 
 func main() {
+	flag.Usage = printUsage          // List the commands in -help output
+	name, args := "run", os.Args[1:] // Default to a full crawl
+	named := len(args) > 0 && !strings.HasPrefix(args[0], "-")
+	if named { // The first argument names a command
+		name, args = args[0], args[1:]
+	}
+	selected, known := commands[name] // Look the command up
+	if !known {
+		flag.Usage()
+		fatalConfig("unknown command %q", name)
+	}
+	flag.CommandLine.Parse(args) // Parse the flags that follow the command
+	if !named && flag.NArg() > 0 {
+		name = flag.Arg(0) // Flags came first, e.g. -pdf-dir X verify
+		if selected, known = commands[name]; !known {
+			flag.Usage()
+			fatalConfig("unknown command %q", name)
+		}
+		flag.CommandLine.Parse(flag.Args()[1:]) // Flags may follow the command as well
+	}
+	if crawlCommands[name] && flag.NArg() > 0 {
+		fatalConfig("%s takes no arguments, got %q", name, flag.Args())
+	}
+	changeWorkingDir() // Services start in the system folder
+	applyProfile()     // Fill in flags from -profile
+	prepareStorage()   // Resolve and create the storage folders
+	setLogVerbosity()  // Apply -quiet and -verbose
+	openLogFile()      // Mirror the log into -log-file
+	openEventStream()  // Stream events to -events
+	startTUI(name)     // Show the -tui dashboard
+	if lockingCommands[name] {
+		acquireDataLock(name) // One writer per data folder
+	}
+	selected.run(flag.Args()) // Run the command
+	releaseDataLock()
+}
+
+// Search every pending query and download every pending PDF
+func runCrawl() runReport {
+	crawl()            // Search, download and prune
+	return finishRun() // Persist state and report
+}
+
+// Search the pending queries, download what they found and prune
+func crawl() {
+	documentManifest = mustLoadManifest() // Documents downloaded by earlier runs
+	crawlLibrary()
+}
+
+// Crawl into the loaded manifest, e.g. the one a server is already serving
+func crawlLibrary() {
+	recoverJournal()       // Pick up what an interrupted run stored
+	if urlListFile != "" { // The operator has the links already
+		downloadLinks(loadURLList())
+		return
+	}
+	queue := currentQueue()      // Work left over from previous runs
+	if spaceCheck || preflight { // The size estimate needs every link before the first download
+		pdfLinks := discover(pendingTargets(queue, queueKindQuery))              // Search the pending combos
+		pdfLinks = append(pendingTargets(queue, queueKindDownload), pdfLinks...) // Links discovered by earlier runs come first
+		downloadLinks(pdfLinks)                                                  // Download everything not stored yet
+	} else {
+		streamCrawl(pendingTargets(queue, queueKindDownload), pendingTargets(queue, queueKindQuery)) // Download while searching
+	}
+	if prune { // Mirror mode drops what the catalog no longer lists
+		pruneStaleDocuments()
+	}
+}
+
+// Search the pending queries without downloading anything
+func runDiscoverCommand() runReport {
+	discover(pendingTargets(currentQueue(), queueKindQuery)) // Search the pending combos
+	return finishRun()                                       // Persist state and report
+}
+
+// Download every discovered link that has no local copy yet
+func runDownloadCommand() runReport {
+	documentManifest = mustLoadManifest() // Documents downloaded by earlier runs
+	recoverJournal()                      // Pick up what an interrupted run stored
+	if urlListFile != "" {                // The operator has the links already
+		downloadLinks(loadURLList())
+	} else {
+		downloadLinks(pendingTargets(currentQueue(), queueKindDownload)) // Download the pending links
+	}
+	return finishRun() // Persist state and report
+}
+
+// The queue a run works from: generated, or the operator's hand-edited file
+func currentQueue() []queueItem {
+	if queueFile != "" { // Use the operator's hand-edited queue instead
+		return resumeQueue(loadQueue(queueFile))
+	}
+	return resumeQueue(buildQueue()) // Work left over from previous runs, less what an interrupted run finished
+}
+
+// Search the given queries and return the PDF links found in their results
+func discover(queries []string) []string {
+	// Run the pending searches through a bounded pool of workers
+	queriesQueued.Add(int64(len(queries)))
+	runWorkerPool(queries, searchConcurrency, searchQuery)
+	var pdfLinks []string // Links found by this run's searches
+	for _, character := range queries {
+		pdfLinks = append(pdfLinks, savedResultLinks(character)...) // Links of the searches that succeeded
+	}
+	if len(queries) > 0 { // Something was searched
+		logCoverage()
+	}
+	return pdfLinks
+}
+
+// Search one query and save its results
+func searchQuery(character string) {
+	waitWhilePaused()      // Held back while -tui is paused
+	queriesQueued.Add(-1)  // Taken off the queue
+	if !reserveRequest() { // Leave the combo for the next run once the budget is spent
+		return
+	}
+	appendJournal(journalRecord{Op: journalQueryIssued, Target: character})
+	search, ctx := startTransfer(stopContext, failureKindSearch, character) // Shown and skippable in -tui
+	result := currentSite().Discover(ctx, character)                        // Get API response for the combo
+	search.finish()
+	if !result.searched() { // Failed searches are retried by the next run
+		if previous, found := loadSearchResult(character); found { // Keep the links of the last search that worked
+			result.Links = previous.Links
+		}
+	}
+	if result.searched() {
+		recordQueryStats(result) // Log and remember the result count
+	}
+	if result.searched() && len(result.Links) == 0 && !result.Truncated { // The stats stand in for an empty file
+		if err := os.Remove(queryResultPath(character)); err != nil && !os.IsNotExist(err) {
+			log.Println(err) // Log error
+		}
+		queriesSearched.Add(1) // Count the completed search
+		appendJournal(journalRecord{Op: journalQueryCompleted, Target: character})
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(queryResultPath(character)), 0755); err != nil { // Other document types keep their results in a subfolder
+		log.Printf("failed to save results for %s: %v", character, err)
+		return
+	}
+	if err := saveSearchResult(result); err != nil { // Replaces results older than --max-age
+		log.Printf("failed to save results for %s: %v", character, err)
+		return
+	}
+	if result.searched() && !result.interrupted() { // Interrupted searches are finished by the next run
+		queriesSearched.Add(1) // Count the completed search
+		appendJournal(journalRecord{Op: journalQueryCompleted, Target: character})
+	}
+}
+
+// Download the given links, skipping the ones already stored
+func downloadLinks(pdfLinks []string) {
+	pdfLinks = removeDuplicatesFromSlice(pdfLinks) // Remove duplicate links
+	linksDiscovered.Add(int64(len(pdfLinks)))      // Count the links considered for download
+	for _, link := range pdfLinks {
+		emitEvent(event{Type: eventDiscovered, URL: link})
+		appendJournal(journalRecord{Op: journalLinkDiscovered, Target: link})
+	}
+	pdfLinks = scopedLinks(pdfLinks)                // Drop links outside --include-pattern/--exclude-pattern
+	pdfLinks = unseenLinks(pdfLinks)                // Drop links stored by earlier runs
+	pdfLinks, confirmed := preflightLinks(pdfLinks) // Drop dead links and confirm the size with --preflight
+	if !confirmed {                                 // The operator declined the expected download
+		return
+	}
+	if !checkDiskSpace(pdfLinks) { // Make sure the downloads fit before starting
+		return
+	}
+	pdfLinks = newestFirst(pdfLinks) // An interrupted run keeps the freshest sheets
+	downloadsQueued.Add(int64(len(pdfLinks)))
+	runWorkerPool(pdfLinks, downloadConcurrency, func(link string) {
+		waitWhilePaused() // Held back while -tui is paused
+		downloadsQueued.Add(-1)
+		if !quotaExhausted.Load() { // Stop gracefully once the quota is reached
+			downloadPDF(link) // Download and save each PDF
+		}
+	})
+}
+
+// Persist the run's state and report how it went
+func finishRun() runReport {
+	stopTUI() // The report goes to the normal screen
+
+	if deferred := deferredRequests.Load(); deferred > 0 { // Report work carried over to the next run
+		log.Printf("%d requests were deferred to the next run by the request/byte budget", deferred)
+	}
+	if pauses := rateLimitPauses.Load(); pauses > 0 { // Hint that the crawl may be too aggressive
+		log.Printf("the origin rate-limited the run %d times", pauses)
+	}
+	saveResolvedLinks()          // Remember where handler links lead
+	saveSeenURLs()               // Remember which links are stored
+	saveDocumentLanguages()      // Remember detected languages
+	saveOriginHealth()           // Later runs ramp up after being rate limited
+	saveQueryStats()             // Result counts, which also stand in for empty results
+	if documentManifest != nil { // Discovery alone does not touch the manifest
+		buildCategoryFarm(documentManifest) // Browse by category, filling in categories first
+		if err := documentManifest.save(manifestFile); err != nil {
+			log.Printf("failed to save manifest %s: %v", manifestFile, err)
+		} else {
+			checkpointJournal() // The manifest now holds what the journal protected
+		}
+		writeChecksums(documentManifest) // Publish checksums next to the PDFs
+		signLibrary()                    // Sign the manifest and SHA256SUMS with -sign-manifest
+		writeRunBundle()                 // Package the library for distribution
+	} else {
+		checkpointJournal() // Searches are saved as they complete
+	}
+	updateRetryQueue()         // Retry this run's failures first next time
+	writeFailureReport()       // Summarize everything that went wrong
+	report := buildRunReport() // Final counters of the run
+	logTraffic(report.Traffic) // Where the transfer went
+	writeRunSummary(report)    // Keep the run in the history
+	if jsonOutput() {
+		printJSON(report)
+	}
+	printQuietSummary(report) // One line for cron
+	syncDeferredFiles()       // Flush what -fsync end held back
+	sendNotifications(report) // Tell the configured channels how the run went
+	return report
+}
+
+// generateQueries returns every search query: the terms of --query and
+// --queries-file when given, otherwise the generated combos, two-letter
+// combos first.
+func generateQueries() []string {
+	if queriesFile != "" || len(customQueries) > 0 { // The operator narrowed the crawl to their own terms
+		return typedQueries(loadCustomQueries())
+	}
 	// Initialize a slice to store allowed characters as strings
 	var allowedCharacters []string
 	// Get all single characters as strings
@@ -40,23 +343,18 @@ func main() {
 	allowedCharacters = combineMultipleSlices(allowedCharacters, allTwoLetterCombinations) // Combine
 	allowedCharacters = combineMultipleSlices(allowedCharacters, allSingleChars)           // Combine
 	// Remove duplicates from the allowed characters slice
-	allowedCharacters = removeDuplicatesFromSlice(allowedCharacters) // Ensure uniqueness
+	return typedQueries(removeDuplicatesFromSlice(allowedCharacters)) // Ensure uniqueness, then repeat for each --doc-types class
+}
 
-	for _, character := range allowedCharacters {
-		filePath := givenFolder + character + ".json" // Construct the path to store results
-		if !fileExists(filePath) {                    // Check if the file already exists
-			apiResults := getAPIResultsWithTwoLetterCombo(character) // Get API response for the combo
-			appendAndWriteToFile(filePath, apiResults)               // Write results to a file
-		}
-		if fileExists(filePath) { // If the file exists
-			content := readAFileAsString(filePath)         // Read the content of the file
-			pdfLinks := extractPDFLinks(content)           // Extract all PDF links
-			pdfLinks = removeDuplicatesFromSlice(pdfLinks) // Remove duplicate links
-			for _, link := range pdfLinks {                // Loop over each link
-				downloadPDF(link, outputDir) // Download and save each PDF
-			}
-		}
+// Build the path of the saved search results for a query target
+func queryResultPath(target string) string {
+	docType, query := splitQueryTarget(target) // Results of other document types go in their subfolder
+	name := sanitizeFilename(query)            // Custom terms may contain spaces and slashes
+	if name != query {                         // Keep distinct terms from sharing a file
+		sum := sha256.Sum256([]byte(query))
+		name += "-" + hex.EncodeToString(sum[:4])
 	}
+	return filepath.Join(givenFolder, docType.Folder, name+".json") // One result file per query
 }
 
 // Combine two slices together and return the new slice.
@@ -65,7 +363,7 @@ func combineMultipleSlices(sliceOne []string, sliceTwo []string) []string {
 	return combinedSlice
 }
 
-// Convert a URL into a safe, lowercase filename
+// Convert a URL into a safe filename under the -filename-* policy
 func urlToSafeFilename(rawURL string) string {
 	parsedURL, err := url.Parse(rawURL) // Parse the input URL
 	if err != nil {
@@ -76,74 +374,262 @@ func urlToSafeFilename(rawURL string) string {
 	if err != nil {
 		decoded = base // Fallback to base if decode fails
 	}
-	decoded = strings.ToLower(decoded)        // Convert filename to lowercase
+	return fileSanitizer.Sanitize(decoded) // Apply the -filename-* policy
+}
+
+// Convert any name into a safe, lowercase filename; used for names that
+// must stay stable whatever the -filename-* policy, like result files
+func sanitizeFilename(name string) string {
+	name = strings.ToLower(name)              // Convert filename to lowercase
 	re := regexp.MustCompile(`[^a-z0-9._-]+`) // Regex to allow only safe characters
-	safe := re.ReplaceAllString(decoded, "_") // Replace unsafe characters with underscores
+	safe := re.ReplaceAllString(name, "_")    // Replace unsafe characters with underscores
 	return safe                               // Return the sanitized filename
 }
 
 // Download and save a PDF file from a given URL
-func downloadPDF(finalURL, outputDir string) {
-	filename := strings.ToLower(urlToSafeFilename(finalURL)) // Generate a safe filename
-	filePath := filepath.Join(outputDir, filename)           // Full path for saving the file
-	if fileExists(filePath) {                                // Skip if file already exists
-		log.Printf("file already exists, skipping: %s", filePath)
+func downloadPDF(finalURL string) {
+	discoveredURL := finalURL        // Link as found in the search results
+	docType := linkDocType(finalURL) // Class of document, which decides the subfolder
+	if !linkAllowed(discoveredURL) { // Off-site links are not fetched
+		return
+	}
+	if hasPDFExtension(discoveredURL) && !linkInScope(discoveredURL) { // Handler links are checked once resolved
+		log.Printf("skipping %s: outside -include-pattern/-exclude-pattern", discoveredURL)
 		return
 	}
-	client := &http.Client{Timeout: 30 * time.Second} // Create HTTP client with timeout
-	resp, err := client.Get(finalURL)                 // Make GET request
+	if !claimDownload(discoveredURL) { // Another worker has this link
+		log.Printf("skipping %s: already being downloaded", discoveredURL)
+		return
+	}
+	defer releaseDownload(discoveredURL)
+	title := linkTitle(finalURL)                   // Product name seen in the search results
+	hints := title + " " + linkLabel(finalURL)     // Either may name the language
+	language := linkLanguage(discoveredURL, hints) // Language named by the title, link text or URL
+	if !languageAllowed(language) {                // Not one of the --languages
+		log.Printf("skipping %s: language %s is not in --languages", discoveredURL, language)
+		return
+	}
+	if !hasPDFExtension(finalURL) { // Download handler, find out where it leads first
+		finalURL = currentSite().ResolveURL(finalURL)
+		if finalURL == "" || !linkAllowed(finalURL) { // Not a PDF, not resolvable right now, or redirected off-site
+			return
+		}
+		if !linkInScope(discoveredURL, finalURL) {
+			log.Printf("skipping %s: outside -include-pattern/-exclude-pattern", discoveredURL)
+			return
+		}
+		if finalURL != discoveredURL { // Handlers that serve the PDF themselves are claimed already
+			if !claimDownload(finalURL) { // Another handler link led a worker to the same PDF
+				log.Printf("skipping %s: already being downloaded", finalURL)
+				return
+			}
+			defer releaseDownload(finalURL)
+		}
+		if language == "" { // The resolved filename may name the language
+			language = linkLanguage(finalURL, hints)
+			if !languageAllowed(language) {
+				log.Printf("skipping %s: language %s is not in --languages", finalURL, language)
+				return
+			}
+		}
+	}
+	filename, collidedWith := storedFilename(docType.inFolder(preferredFilename(finalURL, title, docType.Name, language)), finalURL, true) // Reserve the name for this URL
+	filePath := filepath.Join(outputDir, filepath.FromSlash(filename))                                                                     // Full path for saving the file
+	var stored *manifestEntry                                                                                                              // Copy on disk to revalidate, if any
+	if fileExists(filePath) {
+		entry, due := storedEntryDue(finalURL)
+		if !due || entry.File != filename { // Skip if file already exists
+			log.Printf("file already exists, skipping: %s", filePath)
+			documentsSkipped.Add(1)           // Count the skipped document
+			markSeen(discoveredURL, filename) // Skip it silently next time
+			return
+		}
+		stored = &entry
+	}
+	if !reserveRequest() { // Leave the download for the next run once the budget is spent
+		return
+	}
+	emitEvent(event{Type: eventDownloadStarted, URL: finalURL})
+	appendJournal(journalRecord{Op: journalDownloadStarted, Target: discoveredURL})
+	download, ctx := startTransfer(context.Background(), failureKindDownload, finalURL) // Shown and skippable in -tui
+	defer download.finish()
+	resp, err := fetchDocument(ctx, finalURL, stored) // Make GET request, conditional for a stored copy
 	if err != nil {
-		log.Printf("failed to download %s %v", finalURL, err)
+		recordFailure(failureKindDownload, finalURL, reasonRequestError, err)
+		return
+	}
+	defer resp.Body.Close()                      // Ensure response body is closed
+	if !linkAllowed(resp.Request.URL.String()) { // Redirected off-site, leave the body unread
+		return
+	}
+	if stored != nil && resp.StatusCode == http.StatusNotModified { // The server confirmed the stored copy
+		log.Printf("unchanged on the server, keeping: %s", filePath)
+		revalidated := markRevalidated(*stored)
+		documentsSkipped.Add(1)
+		markSeen(discoveredURL, filename)
+		appendJournal(journalRecord{Op: journalDownloadCompleted, Target: discoveredURL, File: filename, Entry: &revalidated})
 		return
 	}
-	defer resp.Body.Close()               // Ensure response body is closed
 	if resp.StatusCode != http.StatusOK { // Validate status code
-		log.Printf("download failed for %s %s", finalURL, resp.Status)
+		recordFailure(failureKindDownload, finalURL, reasonHTTPStatus, resp.Status)
 		return
 	}
 	contentType := resp.Header.Get("Content-Type")         // Get content type header
 	if !strings.Contains(contentType, "application/pdf") { // Ensure it's a PDF
-		log.Printf("invalid content type for %s %s (expected application/pdf)", finalURL, contentType)
+		recordFailure(failureKindDownload, finalURL, reasonContentType, contentType+" (expected application/pdf)")
 		return
 	}
-	var buf bytes.Buffer                     // Create a buffer for reading data
-	written, err := io.Copy(&buf, resp.Body) // Read response into buffer
-	if err != nil {
-		log.Printf("failed to read PDF data from %s %v", finalURL, err)
+	if !fileSizeAllowed(resp.ContentLength) { // Announced as too big, leave the body unread
+		recordFailure(failureKindDownload, finalURL, reasonTooLarge, fmt.Sprintf("%d bytes, over the %d byte --max-file-size", resp.ContentLength, maxFileSize))
 		return
 	}
-	if written == 0 { // Check if data was written
-		log.Printf("downloaded 0 bytes for %s not creating file", finalURL)
+	if !freeSpaceAllows(finalURL, resp.ContentLength) { // Keep the configured headroom on the disk
 		return
 	}
-	out, err := os.Create(filePath) // Create the output file
+	createDirectory(filepath.Dir(filePath), 0755) // Other document types are stored in a subfolder
+	pending, err := createPendingFile(filePath)   // Stream straight to disk, whole PDFs never sit in memory
 	if err != nil {
-		log.Printf("failed to create file for %s %v", finalURL, err)
+		recordFailure(failureKindDownload, finalURL, reasonWriteError, err)
 		return
 	}
-	defer out.Close()         // Ensure the file is closed
-	_, err = buf.WriteTo(out) // Write buffered data to file
+	var body io.Reader = download.counted(throttleBody(budgetReader{resp.Body}, true)) // Paced and counted against the budget
+	if maxFileSize > 0 {                                                               // Bodies without a length are cut off one byte past the limit
+		body = io.LimitReader(body, maxFileSize+1)
+	}
+	hash := sha256.New()                                         // Hash the PDF for the manifest while it streams
+	written, err := io.Copy(io.MultiWriter(pending, hash), body) // Copy the body to the temp file
+	if err == nil && !fileSizeAllowed(written) {                 // Streamed past --max-file-size
+		pending.discard()
+		recordFailure(failureKindDownload, finalURL, reasonTooLarge, fmt.Sprintf("more than the %d byte --max-file-size", maxFileSize))
+		return
+	}
+	if err == nil && resp.ContentLength >= 0 && written != resp.ContentLength { // Connection dropped part way
+		err = fmt.Errorf("short body: received %d of %d bytes", written, resp.ContentLength)
+	}
 	if err != nil {
-		log.Printf("failed to write PDF to file for %s: %v", finalURL, err)
+		pending.discard()
+		reason := reasonReadError    // Most failures are on the network side
+		if pending.writeErr != nil { // The disk refused the data
+			reason = reasonWriteError
+		}
+		recordFailure(failureKindDownload, finalURL, reason, err)
+		return
+	}
+	if written == 0 { // Check if data was written
+		pending.discard()
+		recordFailure(failureKindDownload, finalURL, reasonEmptyBody, "downloaded 0 bytes, not creating file")
 		return
 	}
+	sum := hash.Sum(nil)    // Content hash of the PDF
+	if collidedWith != "" { // Same document published under two URLs is not worth a second copy
+		if owner, known := documentManifest.lookup(collidedWith); known && owner.SHA256 == hex.EncodeToString(sum) {
+			pending.discard()
+			log.Printf("%s is identical to %s, already stored as %s", finalURL, collidedWith, owner.File)
+			documentsSkipped.Add(1)
+			markSeen(discoveredURL, owner.File)
+			return
+		}
+	}
+	if !reserveQuota(written) { // Storing this PDF would exceed --max-total-bytes
+		pending.discard()
+		return
+	}
+	defer releaseQuota(written)     // Counted in documentBytesSaved by then if it was kept
+	var superseded *documentVersion // Stored copy kept by --keep-versions, if any
+	if stored != nil {
+		if superseded, err = archiveVersion(*stored, hex.EncodeToString(sum)); err != nil {
+			pending.discard()
+			recordFailure(failureKindDownload, finalURL, reasonWriteError, fmt.Errorf("failed to keep the previous version: %w", err))
+			return
+		}
+	}
+	if err := pending.commit(); err != nil { // Rename into place only once complete
+		recordFailure(failureKindDownload, finalURL, reasonWriteError, err)
+		return
+	}
+	if language == "" && filteringLanguages() { // Only the text can tell, and it matters
+		if text, err := extractPDFText(filePath); err == nil {
+			language = detectTextLanguage(text)
+		}
+		if language != "" {
+			rememberDocumentLanguage(discoveredURL, language) // Never download it again just to find out
+		}
+		if !languageAllowed(language) {
+			log.Printf("discarding %s: language %s is not in --languages", filePath, language)
+			os.Remove(filePath)
+			restoreVersion(superseded, filePath)
+			return
+		}
+	}
+	revisionDate := linkRevision(discoveredURL) // The date the search results list, else the server's Last-Modified
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && revisionDate == "" {
+		revisionDate = lastModified.UTC().Format(time.DateOnly)
+	}
+	entry := manifestEntry{
+		URL:          finalURL,
+		Title:        title,
+		Category:     linkCategory(discoveredURL),
+		Language:     language,
+		File:         filename,
+		DocType:      docType.entryName(),
+		SHA256:       hex.EncodeToString(sum),
+		Size:         written,
+		RevisionDate: revisionDate,
+		LastModified: resp.Header.Get("Last-Modified"),
+		ETag:         resp.Header.Get("ETag"),
+		DownloadedAt: time.Now().UTC(),
+	}
+	if stored != nil { // Earlier revisions stay on record
+		entry.Versions = stored.Versions
+	}
+	if superseded != nil {
+		entry.Versions = append(entry.Versions, *superseded)
+	}
+	if !runPostDownloadHooks(filePath, entry) { // Rejected, e.g. by a virus scanner
+		os.Remove(filePath)
+		restoreVersion(superseded, filePath)
+		return
+	}
+	if err := storeObject(filePath, entry.SHA256); err != nil { // The readable file stays usable either way
+		log.Printf("failed to add %s to the object store: %v", filePath, err)
+	}
+	previous, replaced := documentManifest.record(entry) // Earlier version, if any
+	recordDocumentChange(entry, previous, replaced)      // New or updated, for the run report
+	markSeen(discoveredURL, filename)                    // Never attempt this link again while the file exists
+	documentsSaved.Add(1)                                // Count the stored document
+	documentBytesSaved.Add(written)                      // Count the stored bytes
+	appendJournal(journalRecord{Op: journalDownloadCompleted, Target: discoveredURL, File: filename, Entry: &entry})
+	emitEvent(event{Type: eventDownloadCompleted, URL: finalURL, File: filename, Size: written, SHA256: entry.SHA256})
 	log.Printf("successfully downloaded %d bytes: %s → %s\n", written, finalURL, filePath)
 }
 
-// Extract all .pdf links using regex
-func extractPDFLinks(htmlContent string) []string {
-	htmlContent = strings.ToLower(htmlContent)                                 // Normalize content to lowercase
-	pdfRegex := regexp.MustCompile(`https?://[^\s"'<>]+?\.pdf(\?[^\s"'<>]*)?`) // Regex to match PDF URLs
-	matches := pdfRegex.FindAllString(htmlContent, -1)                         // Find all matches
-	seen := make(map[string]struct{})                                          // Map to track unique links
-	var links []string                                                         // Slice to hold unique links
-	for _, m := range matches {
-		if _, ok := seen[m]; !ok { // If not already seen
-			seen[m] = struct{}{}     // Mark as seen
-			links = append(links, m) // Add to list
+// Build the local filename for a PDF URL
+func pdfFilename(pdfURL string) string {
+	name, _ := storedFilename(linkDocType(pdfURL).inFolder(plainPDFFilename(pdfURL)), pdfURL, false) // Stay clear of names other URLs own
+	return name
+}
+
+// Build the sanitized filename for a PDF URL, before collision handling
+func plainPDFFilename(pdfURL string) string {
+	if !hasPDFExtension(pdfURL) { // Opaque URL that still serves a PDF
+		if name := dispositionFilename(pdfURL); name != "" { // The server named the document
+			return name
+		}
+		return opaqueURLFilename(pdfURL)
+	}
+	return urlToSafeFilename(pdfURL) // Generate a safe filename
+}
+
+// Work out where a discovered link is stored locally, "" if not known yet
+func localPDFPath(link string) string {
+	if !hasPDFExtension(link) { // Handler links are only known once resolved
+		resolved, cached := lookupResolvedLink(link)
+		if !cached || resolved == "" {
+			return ""
 		}
+		link = resolved
 	}
-	return links // Return unique PDF links
+	return filepath.Join(outputDir, filepath.FromSlash(pdfFilename(link))) // Full path of the local copy
 }
 
 // Read a file and return its contents as a string
@@ -170,7 +656,7 @@ func removeDuplicatesFromSlice(slice []string) []string {
 
 // Create a directory with given permissions
 func createDirectory(path string, permission os.FileMode) {
-	err := os.Mkdir(path, permission) // Try to create directory
+	err := os.MkdirAll(path, permission) // Try to create directory and any missing parents
 	if err != nil {
 		log.Println(err) // Log any creation errors
 	}
@@ -194,28 +680,12 @@ func fileExists(filename string) bool {
 	return !info.IsDir() // Return true if it's a file
 }
 
-// Append content to a file, creating it if needed
-func appendAndWriteToFile(path string, content string) {
-	filePath, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // Open file for appending
-	if err != nil {
-		log.Println(err) // Log error
-	}
-	_, err = filePath.WriteString(content + "\n") // Append content
-	if err != nil {
-		log.Println(err) // Log error
-	}
-	err = filePath.Close() // Close file
-	if err != nil {
-		log.Println(err) // Log error
-	}
-}
-
 // generateTwoLetterCombinations generates all 2-character combinations
-// using the characters 'a'–'z' and '0'–'9'.
+// using the characters of --charset ('a'–'z' and '0'–'9' by default).
 // It returns a slice of strings containing all possible 2-letter combinations.
 func generateTwoLetterCombinations() []string {
 	// Define the set of characters to use in combinations
-	characterSet := "abcdefghijklmnopqrstuvwxyz0123456789"
+	characterSet := queryCharset
 
 	// Create a slice to store all generated combinations
 	var allCombinations []string
@@ -237,9 +707,9 @@ func generateTwoLetterCombinations() []string {
 }
 
 // generateSingleCharacters returns a slice of strings containing
-// all characters from 'a' to 'z' and '0' to '9', each as a single-character string.
+// all characters of --charset, each as a single-character string.
 func generateSingleCharacters() []string {
-	characterSet := "abcdefghijklmnopqrstuvwxyz0123456789" // Characters to include
+	characterSet := queryCharset // Characters to include
 
 	var singleCharacters []string // Slice to hold each character as a string
 
@@ -252,29 +722,30 @@ func generateSingleCharacters() []string {
 	return singleCharacters // Return the list of single-character strings
 }
 
-// Fetch results from API using 2-letter combo
-func getAPIResultsWithTwoLetterCombo(combo string) string {
-	url := "https://www.hillyard.com/safetydatasheet/search/results?q=" + combo // Construct URL
-	method := "GET"                                                             // Set HTTP method
-
-	client := &http.Client{}                      // Create new HTTP client
-	req, err := http.NewRequest(method, url, nil) // Build the request
+// Fetch one page of search results and the status it was answered with,
+// 0 when no answer came or ctx was cancelled
+func fetchSearchPage(ctx context.Context, combo, url string) (string, int) {
+	res, err := fetchSearchResponse(ctx, url) // Execute the request, compressed when the server can
 	if err != nil {
-		log.Println(err) // Log error
-		return ""        // Return empty string
+		recordFailure(failureKindSearch, combo, reasonRequestError, err) // Record error
+		return "", 0                                                     // Return empty string
 	}
+	defer res.Body.Close() // Close body when done
 
-	res, err := client.Do(req) // Execute the request
-	if err != nil {
-		log.Println(err) // Log error
-		return ""        // Return empty string
+	if res.StatusCode != http.StatusOK { // Validate status code
+		recordFailure(failureKindSearch, combo, reasonHTTPStatus, res.Status) // Record error
+		return "", res.StatusCode                                             // Return empty string
 	}
-	defer res.Body.Close() // Close body when done
 
-	body, err := io.ReadAll(res.Body) // Read response body
+	decoded, err := decodeBody(throttleBody(budgetReader{res.Body}, false), res.Header.Get("Content-Encoding")) // Budget and bandwidth count the bytes on the wire
+	if err != nil {
+		recordFailure(failureKindSearch, combo, reasonContentEncoding, err) // Never save compressed bytes as results
+		return "", 0
+	}
+	body, err := io.ReadAll(decoded) // Read response body
 	if err != nil {
-		log.Println(err) // Log error
-		return ""        // Return empty string
+		recordFailure(failureKindSearch, combo, reasonReadError, err) // Record error
+		return "", 0                                                  // The answer was cut off
 	}
-	return string(body) // Return the body as string
+	return string(body), res.StatusCode // Return the body as string
 }
@@ -0,0 +1,237 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For atomic manifest writes
+	"encoding/json" // For the manifest format
+	"errors"        // For detecting a missing manifest
+	"flag"          // For command-line flag parsing
+	"os"            // For reading the manifest
+	"path/filepath" // For building local paths
+	"sort"          // For stable entry order
+	"sync"          // For guarding the manifest across workers
+	"time"          // For download timestamps
+)
+
+var (
+	manifestFile     string                // Where the document manifest is kept
+	documentManifest *manifest             // Manifest of the current run
+	linkTitles       = map[string]string{} // Document URL → title seen in search results
+	linkLabels       = map[string]string{} // Document URL → anchor text seen in search results
+	linkCategories   = map[string]string{} // Document URL → product category seen in search results
+	linkRevisions    = map[string]string{} // Document URL → revision date listed in search results
+	linkTitlesMutex  sync.Mutex            // Guards linkTitles, linkLabels, linkCategories and linkRevisions
+)
+
+func init() {
+	flag.StringVar(&manifestFile, "manifest", "manifest.json", "where the manifest of downloaded documents is kept") // Register the manifest flag
+}
+
+// manifestEntry describes one downloaded document.
+type manifestEntry struct {
+	URL          string            `json:"url"`                     // Source URL the PDF was downloaded from
+	Title        string            `json:"title,omitempty"`         // Product name from the search results
+	Category     string            `json:"category,omitempty"`      // Product category from the search results
+	Language     string            `json:"language,omitempty"`      // Language code, when known
+	File         string            `json:"file"`                    // Path inside the PDF folder, with forward slashes
+	DocType      string            `json:"doc_type,omitempty"`      // Document class from --doc-types, empty for safety data sheets
+	SHA256       string            `json:"sha256"`                  // Hash of the stored PDF
+	Size         int64             `json:"size"`                    // Size of the stored PDF
+	RevisionDate string            `json:"revision_date,omitempty"` // Revision date listed in the search results, else the Last-Modified date
+	LastModified string            `json:"last_modified,omitempty"` // Last-Modified header as sent, for conditional requests
+	ETag         string            `json:"etag,omitempty"`          // ETag header as sent, for conditional requests
+	HazardCodes  []string          `json:"hazard_codes,omitempty"`  // GHS hazard statements from Section 2, e.g. H314
+	SignalWord   string            `json:"signal_word,omitempty"`   // "Danger" or "Warning" from Section 2
+	Source       string            `json:"source,omitempty"`        // "manual" for uploaded documents, empty when crawled
+	DownloadedAt time.Time         `json:"downloaded_at"`           // When the PDF was stored
+	CheckedAt    *time.Time        `json:"checked_at,omitempty"`    // When the server last confirmed it unchanged
+	Pruned       string            `json:"pruned,omitempty"`        // "archived" or "deleted" once no longer listed upstream
+	PrunedAt     *time.Time        `json:"pruned_at,omitempty"`     // When the document was pruned
+	Review       string            `json:"review,omitempty"`        // Review state, empty when never reviewed
+	ReviewedBy   string            `json:"reviewed_by,omitempty"`   // Who set the review state
+	ReviewedAt   *time.Time        `json:"reviewed_at,omitempty"`   // When the review state was set
+	Versions     []documentVersion `json:"versions,omitempty"`      // Superseded revisions kept by --keep-versions, oldest first
+}
+
+// manifest records every downloaded document, keyed by source URL.
+type manifest struct {
+	mutex   sync.Mutex                // Guards Entries
+	Entries map[string]*manifestEntry // Source URL → entry
+}
+
+// loadManifest reads a manifest, returning an empty one if none exists yet.
+func loadManifest(path string) (*manifest, error) {
+	loaded := &manifest{Entries: make(map[string]*manifestEntry)} // Empty manifest
+	content, err := os.ReadFile(path)                             // Read the manifest
+	if errors.Is(err, os.ErrNotExist) {
+		return loaded, nil // First run
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*manifestEntry // Stored as a sorted list for readable diffs
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		entry.URL = canonicalURL(entry.URL) // Entries recorded before links were canonicalized
+		if existing, found := loaded.Entries[entry.URL]; found && existing.DownloadedAt.After(entry.DownloadedAt) {
+			continue // Two spellings of one link, keep the newer download
+		}
+		loaded.Entries[entry.URL] = entry
+	}
+	return loaded, nil
+}
+
+// mustLoadManifest loads the configured manifest or exits.
+func mustLoadManifest() *manifest {
+	loaded, err := loadManifest(manifestFile) // Read the manifest
+	if err != nil {
+		fatalConfig("failed to read manifest %s: %v", manifestFile, err)
+	}
+	return loaded
+}
+
+// record adds or replaces an entry and returns the entry it replaced, if any.
+func (documents *manifest) record(entry manifestEntry) (previous manifestEntry, replaced bool) {
+	documents.mutex.Lock()
+	defer documents.mutex.Unlock()
+	if existing, ok := documents.Entries[entry.URL]; ok {
+		previous, replaced = *existing, true
+	}
+	entry = reviewStateAfterRecord(entry, previous, replaced) // New content needs a new review
+	documents.Entries[entry.URL] = &entry                     // Newest download wins
+	return previous, replaced
+}
+
+// replace overwrites an entry as is, for edits that are not new downloads.
+func (documents *manifest) replace(entry manifestEntry) {
+	documents.mutex.Lock()
+	defer documents.mutex.Unlock()
+	documents.Entries[entry.URL] = &entry
+}
+
+// entryForFile returns the current entry stored under a filename.
+func (documents *manifest) entryForFile(file string) (manifestEntry, bool) {
+	for _, entry := range documents.currentEntries() {
+		if entry.File == file {
+			return entry, true
+		}
+	}
+	return manifestEntry{}, false
+}
+
+// lookup returns a copy of the entry for a source URL.
+func (documents *manifest) lookup(sourceURL string) (manifestEntry, bool) {
+	documents.mutex.Lock()
+	defer documents.mutex.Unlock()
+	if entry, ok := documents.Entries[sourceURL]; ok {
+		return *entry, true
+	}
+	return manifestEntry{}, false
+}
+
+// sortedEntries returns copies of all entries ordered by filename.
+func (documents *manifest) sortedEntries() []manifestEntry {
+	documents.mutex.Lock()
+	defer documents.mutex.Unlock()
+	entries := make([]manifestEntry, 0, len(documents.Entries)) // Copies are safe to use unlocked
+	for _, entry := range documents.Entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+		return entries[i].URL < entries[j].URL
+	})
+	return entries
+}
+
+// save writes the manifest atomically.
+func (documents *manifest) save(path string) error {
+	content, err := json.MarshalIndent(documents.sortedEntries(), "", "  ") // Encode the entries
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	_, err = writeFileAtomically(path, bytes.NewReader(content), int64(len(content))) // Never leave a torn manifest
+	return err
+}
+
+// currentEntries returns the sorted entries that have not been pruned.
+func (documents *manifest) currentEntries() []manifestEntry {
+	var current []manifestEntry // Documents still part of the mirror
+	for _, entry := range documents.sortedEntries() {
+		if entry.Pruned == "" {
+			current = append(current, entry)
+		}
+	}
+	return current
+}
+
+// Local path of a manifest entry
+func (entry manifestEntry) localPath() string {
+	if entry.Pruned == pruneActionArchive { // Moved out of the mirror by --prune
+		return filepath.Join(archiveDir, entry.File)
+	}
+	return filepath.Join(outputDir, entry.File) // PDFs live in the PDF folder
+}
+
+// Remember the titles of the links found in a search result page
+func rememberLinkTitles(links []documentLink) {
+	linkTitlesMutex.Lock()
+	defer linkTitlesMutex.Unlock()
+	for _, link := range links {
+		if link.Title != "" && linkTitles[link.URL] == "" { // Keep the first title seen
+			linkTitles[link.URL] = link.Title
+		}
+		if link.Label != "" && linkLabels[link.URL] == "" {
+			linkLabels[link.URL] = link.Label
+		}
+		if link.Category != "" && linkCategories[link.URL] == "" {
+			linkCategories[link.URL] = link.Category
+		}
+		if link.Revised > linkRevisions[link.URL] { // The newest listing wins
+			linkRevisions[link.URL] = link.Revised
+		}
+	}
+}
+
+// Look up the title of a discovered link
+func linkTitle(link string) string {
+	linkTitlesMutex.Lock()
+	defer linkTitlesMutex.Unlock()
+	return linkTitles[link]
+}
+
+// Look up the product category of a discovered link
+func linkCategory(link string) string {
+	linkTitlesMutex.Lock()
+	defer linkTitlesMutex.Unlock()
+	return linkCategories[link]
+}
+
+// Look up the revision date listed for a discovered link
+func linkRevision(link string) string {
+	linkTitlesMutex.Lock()
+	defer linkTitlesMutex.Unlock()
+	return linkRevisions[link]
+}
+
+// newestFirst orders links by the revision date listed for them, newest
+// first, so an interrupted run still has the freshest sheets. Links without
+// a date keep their order after the dated ones.
+func newestFirst(links []string) []string {
+	sorted := append([]string(nil), links...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return linkRevision(sorted[i]) > linkRevision(sorted[j])
+	})
+	return sorted
+}
+
+// Look up the anchor text of a discovered link
+func linkLabel(link string) string {
+	linkTitlesMutex.Lock()
+	defer linkTitlesMutex.Unlock()
+	return linkLabels[link]
+}
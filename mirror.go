@@ -0,0 +1,65 @@
+package main // Define the main package
+
+import (
+	"flag"    // For applying the defaults
+	"log"     // For logging the settings and steps
+	"sort"    // For listing the defaults in a stable order
+	"strings" // For the settings line
+)
+
+// mirrorDefaults are the flags the mirror command sets unless the command
+// line or the -profile sets them: checksums published next to the PDFs,
+// downloads sized up before they start, results refreshed monthly and
+// documents the catalog dropped moved to the archive instead of lingering.
+// Retries, rate limiting and the manifest are on in every run already.
+// Pruning is left off when -query, -queries-file or -charset narrow the
+// search, as everything the narrower search misses would be archived.
+var mirrorDefaults = map[string]string{
+	"checksums":   "sidecar,sums",
+	"space-check": "true",
+	"max-age":     "720h",
+	"prune":       "true",
+}
+
+// runMirrorCommand is a complete archival run in one command: search,
+// extract links, download, verify what is stored, index the text and
+// report. It exits like run, and with status 1 when verification finds
+// problems.
+func runMirrorCommand() {
+	applyMirrorDefaults()
+	crawl() // Search, extract and download
+	log.Printf("mirror: verifying the library")
+	problems := verifyEntries(documentManifest.currentEntries())
+	log.Printf("mirror: indexing new documents")
+	updateIndex(false)
+	report := finishRun() // Persist state and report
+	if len(problems) > 0 {
+		exit(exitPartialFailure) // Let schedulers notice a damaged archive
+	}
+	exitWithOutcome(report)
+}
+
+// applyMirrorDefaults sets the mirror defaults that were not given, and
+// logs every value it picked so the run explains itself.
+func applyMirrorDefaults() {
+	given := make(map[string]bool) // Flags set on the command line or by the profile
+	flag.Visit(func(set *flag.Flag) { given[set.Name] = true })
+	var applied []string
+	for name, value := range mirrorDefaults {
+		if given[name] {
+			continue
+		}
+		if reason := narrowedQueries(); name == "prune" && reason != "" {
+			log.Printf("mirror: not pruning by default: %s", reason)
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			fatalConfig("mirror default -%s=%s: %v", name, value, err)
+		}
+		applied = append(applied, "-"+name+"="+value)
+	}
+	sort.Strings(applied)
+	if len(applied) > 0 {
+		log.Printf("mirror: using %s (override any of them on the command line)", strings.Join(applied, " "))
+	}
+}
@@ -0,0 +1,100 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For rendering templates into memory
+	"flag"          // For command-line flag parsing
+	"fmt"           // For printing notifications
+	"log"           // For logging messages and errors
+	"os"            // For reading template files
+	"strings"       // For splitting the channel list
+	"text/template" // For customizable notification messages
+)
+
+// defaultNotificationTemplate is used when no --notify-template is given.
+const defaultNotificationTemplate = `{{if eq .Kind "digest"}}Hillyard mirror integrity digest since {{.StartedAt.Format "2006-01-02"}}: {{len .IntegrityFindings}} findings.{{range .IntegrityFindings}}
+- {{.File}}: {{.Kind}} {{.Detail}}{{end}}{{else}}Hillyard mirror run finished in {{.Duration}}: {{.Downloaded}} downloaded ({{len .NewDocuments}} new, {{len .UpdatedDocuments}} updated), {{.Skipped}} skipped, {{len .Failures}} failed, {{.QueriesSearched}} queries searched.{{with .Drift}} Drift from the approved manifest: {{len .Added}} added, {{len .Removed}} removed, {{len .Changed}} changed.{{end}}{{end}}`
+
+var (
+	notifyChannels       string                                 // Comma-separated list of notification channels
+	notifyTemplateFile   string                                 // Optional Go template file for notification messages
+	notifierConstructors = map[string]func() (Notifier, error){ // Every available channel by name
+		"stdout":  func() (Notifier, error) { return stdoutNotifier{}, nil },
+		"webhook": newWebhookNotifier,
+		"slack":   newSlackNotifier,
+		"email":   newEmailNotifier,
+	}
+)
+
+func init() {
+	flag.StringVar(&notifyChannels, "notify", "", "comma-separated notification channels to send the run report to (stdout, webhook, slack, email)") // Register the channel flag
+	flag.StringVar(&notifyTemplateFile, "notify-template", "", "Go text/template file used to render notification messages")                         // Register the template flag
+}
+
+// Notifier delivers a rendered run report through one channel. New channels
+// only need to implement this interface and register a constructor in
+// notifierConstructors; the message text is shared and comes from the template.
+type Notifier interface {
+	Name() string                                  // Channel name used in logs
+	Notify(report runReport, message string) error // Deliver the message
+}
+
+// stdoutNotifier prints the message to standard output.
+type stdoutNotifier struct{}
+
+// Name returns the channel name.
+func (stdoutNotifier) Name() string { return "stdout" }
+
+// Notify prints the rendered message.
+func (stdoutNotifier) Notify(report runReport, message string) error {
+	_, err := fmt.Println(message) // Print the message
+	return err
+}
+
+// renderNotification executes the notification template over the report.
+func renderNotification(report runReport) (string, error) {
+	templateText := defaultNotificationTemplate // Built-in message
+	if notifyTemplateFile != "" {               // Use the operator's template instead
+		content, err := os.ReadFile(notifyTemplateFile)
+		if err != nil {
+			return "", err
+		}
+		templateText = string(content)
+	}
+	messageTemplate, err := template.New("notification").Parse(templateText) // Compile the template
+	if err != nil {
+		return "", err
+	}
+	var message bytes.Buffer // Rendered output
+	if err := messageTemplate.Execute(&message, report); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.String()), nil
+}
+
+// sendNotifications renders the report once and hands it to every configured channel.
+func sendNotifications(report runReport) {
+	if notifyChannels == "" { // Notifications are opt-in
+		return
+	}
+	message, err := renderNotification(report) // Shared message text
+	if err != nil {
+		log.Printf("failed to render notification: %v", err)
+		return
+	}
+	for _, name := range strings.Split(notifyChannels, ",") {
+		name = strings.TrimSpace(name) // Allow "a, b"
+		constructor, ok := notifierConstructors[name]
+		if !ok {
+			log.Printf("unknown notification channel %q", name)
+			continue
+		}
+		notifier, err := constructor() // Build the channel from its flags
+		if err != nil {
+			log.Printf("failed to set up %s notifications: %v", name, err)
+			continue
+		}
+		if err := notifier.Notify(report, message); err != nil {
+			log.Printf("failed to send %s notification: %v", notifier.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For collecting command output
+	"context"       // For the OCR timeout
+	"flag"          // For command-line flag parsing
+	"fmt"           // For wrapping command errors
+	"os"            // For the page images
+	"os/exec"       // For running the OCR tools
+	"path/filepath" // For listing the page images
+	"sort"          // For page order
+	"strings"       // For splitting the command
+	"time"          // For the OCR timeout
+	"unicode"       // For telling text from noise
+)
+
+var (
+	ocrEnabled   bool          // Read scanned PDFs with OCR while indexing
+	ocrCommand   string        // Command printing the text of a PDF on stdout, "" for pdftoppm and tesseract
+	ocrLanguages string        // Tesseract languages, e.g. eng+fra
+	ocrTimeout   time.Duration // Longest OCR of one document
+)
+
+func init() {
+	flag.BoolVar(&ocrEnabled, "ocr", false, "read PDFs without a text layer, such as scanned SDS, with OCR while indexing (needs pdftoppm and tesseract, or -ocr-command)")                                 // Register the OCR flag
+	flag.StringVar(&ocrCommand, "ocr-command", "", "command run instead of pdftoppm and tesseract; the PDF path is appended and the text is read from its stdout, e.g. \"/usr/local/bin/scan-to-text.sh\"") // Register the OCR command flag
+	flag.StringVar(&ocrLanguages, "ocr-languages", "eng", "tesseract languages of the scanned documents, e.g. eng+fra+spa")                                                                                 // Register the OCR language flag
+	flag.DurationVar(&ocrTimeout, "ocr-timeout", 5*time.Minute, "longest OCR of one document")                                                                                                              // Register the OCR timeout flag
+}
+
+// minTextLetters is how many letters a text layer needs to be trusted; below
+// it the PDF is taken for a scan.
+const minTextLetters = 20
+
+// checkOCRTools makes sure the OCR tools can be found before indexing starts.
+func checkOCRTools() {
+	if !ocrEnabled {
+		return
+	}
+	tools := []string{"pdftoppm", "tesseract"}
+	if ocrCommand != "" {
+		tools = strings.Fields(ocrCommand)[:1]
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			fatalConfig("-ocr needs %s: %v", tool, err)
+		}
+	}
+}
+
+// needsOCR reports whether extracted text is too thin to be a text layer.
+func needsOCR(text string) bool {
+	letters := 0
+	for _, character := range text {
+		if unicode.IsLetter(character) {
+			letters++
+			if letters >= minTextLetters {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ocrPDFText returns the text OCR reads from a PDF.
+func ocrPDFText(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+	defer cancel()
+	if ocrCommand != "" { // The operator's own pipeline
+		fields := strings.Fields(ocrCommand)
+		return runOCRTool(ctx, fields[0], append(fields[1:], path)...)
+	}
+	pages, err := os.MkdirTemp("", "hillyard-ocr-") // Page images, removed afterwards
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(pages)
+	if _, err := runOCRTool(ctx, "pdftoppm", "-r", "300", "-gray", "-png", path, filepath.Join(pages, "page")); err != nil {
+		return "", err
+	}
+	images, _ := filepath.Glob(filepath.Join(pages, "page*.png"))
+	sort.Strings(images) // pdftoppm pads page numbers, so names sort in page order
+	var text strings.Builder
+	for _, image := range images {
+		pageText, err := runOCRTool(ctx, "tesseract", image, "stdout", "-l", ocrLanguages)
+		if err != nil {
+			return "", err
+		}
+		text.WriteString(pageText)
+		text.WriteString("\n")
+	}
+	return text.String(), nil
+}
+
+// runOCRTool runs one tool and returns its stdout, with stderr in the error.
+func runOCRTool(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdout, command.Stderr = &stdout, &stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,132 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For atomic state writes
+	"context"       // For cancelled waits
+	"encoding/json" // For the state file
+	"flag"          // For command-line flag parsing
+	"log"           // For logging messages and errors
+	"os"            // For reading the state file
+	"path/filepath" // For the state file path
+	"sync"          // For guarding the state
+	"time"          // For cooldowns
+)
+
+var (
+	backoffCooldown    time.Duration            // How long after being rate limited requests ramp back up
+	rampInterval       time.Duration            // Spacing between requests right after being rate limited
+	originHealthState  map[string]*originHealth // Host → health, loaded on first use
+	originRampNext     = make(map[string]time.Time)
+	originHealthMutex  sync.Mutex              // Guards originHealthState and originRampNext
+	originHealthLogged = make(map[string]bool) // Hosts whose ramp-up was announced
+)
+
+func init() {
+	flag.DurationVar(&backoffCooldown, "backoff-cooldown", 6*time.Hour, "after the origin rate-limits a run, how long later runs take to ramp back up to full rate")     // Register the cooldown flag
+	flag.DurationVar(&rampInterval, "ramp-interval", 2*time.Second, "spacing between requests right after being rate limited, shrinking to none over -backoff-cooldown") // Register the ramp flag
+}
+
+// originHealth is what later runs need to know about an origin.
+type originHealth struct {
+	LimitedAt   time.Time `json:"limited_at"`   // Last 429/503 answer
+	PausedUntil time.Time `json:"paused_until"` // End of the last Retry-After pause
+}
+
+// Path of the origin health file
+func originHealthPath() string {
+	return filepath.Join(givenFolder, "origin-health.json") // Lives next to the search results
+}
+
+// Load the origin health if needed; the caller holds originHealthMutex
+func loadOriginHealthLocked() {
+	if originHealthState != nil {
+		return
+	}
+	originHealthState = make(map[string]*originHealth)
+	if content, err := os.ReadFile(originHealthPath()); err == nil {
+		json.Unmarshal(content, &originHealthState) // A corrupt file just starts fresh
+	}
+}
+
+// recordOriginLimited remembers that host rate-limited us until the given time.
+func recordOriginLimited(host string, until time.Time) {
+	originHealthMutex.Lock()
+	defer originHealthMutex.Unlock()
+	loadOriginHealthLocked()
+	health := originHealthState[host]
+	if health == nil {
+		health = &originHealth{}
+		originHealthState[host] = health
+	}
+	health.LimitedAt = time.Now().UTC()
+	if until.After(health.PausedUntil) {
+		health.PausedUntil = until.UTC()
+	}
+}
+
+// waitForOriginHealth holds a request back while a pause from an earlier
+// run is still running, then spaces requests out while the origin cools
+// down: rampInterval apart right after the limit, shrinking linearly to
+// no spacing once backoffCooldown has passed.
+func waitForOriginHealth(ctx context.Context, host string) error {
+	originHealthMutex.Lock()
+	loadOriginHealthLocked()
+	health := originHealthState[host]
+	if health == nil { // Never limited
+		originHealthMutex.Unlock()
+		return nil
+	}
+	now := time.Now()
+	elapsed := now.Sub(health.LimitedAt)
+	if elapsed >= backoffCooldown && !health.PausedUntil.After(now) { // Fully recovered
+		originHealthMutex.Unlock()
+		return nil
+	}
+	if !originHealthLogged[host] {
+		originHealthLogged[host] = true
+		log.Printf("%s rate-limited us at %s; ramping up until %s", host, health.LimitedAt.Local().Format(time.DateTime), health.LimitedAt.Add(backoffCooldown).Local().Format(time.DateTime))
+	}
+	slot := now
+	if health.PausedUntil.After(slot) { // Retry-After outlived the last run
+		slot = health.PausedUntil
+	}
+	if originRampNext[host].After(slot) {
+		slot = originRampNext[host]
+	}
+	if elapsed < backoffCooldown {
+		remaining := 1 - float64(elapsed)/float64(backoffCooldown) // 1 right after the limit, 0 when cooled down
+		originRampNext[host] = slot.Add(time.Duration(float64(rampInterval) * remaining))
+	}
+	originHealthMutex.Unlock()
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// saveOriginHealth persists the origin health for the next run, dropping
+// origins that have fully cooled down.
+func saveOriginHealth() {
+	originHealthMutex.Lock()
+	defer originHealthMutex.Unlock()
+	if originHealthState == nil { // Nothing was requested this run
+		return
+	}
+	for host, health := range originHealthState {
+		if time.Since(health.LimitedAt) >= backoffCooldown && time.Now().After(health.PausedUntil) {
+			delete(originHealthState, host)
+		}
+	}
+	content, err := json.MarshalIndent(originHealthState, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := writeFileAtomically(originHealthPath(), bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Println(err)
+	}
+}
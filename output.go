@@ -0,0 +1,34 @@
+package main // Define the main package
+
+import (
+	"encoding/json" // For machine-readable output
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting unknown formats
+	"os"            // For writing to stdout
+)
+
+var outputFormat = "text" // How commands print their results: text or json
+
+func init() {
+	flag.Func("output", "result format of commands: text or json (logs always go to stderr)", func(value string) error {
+		if value != "text" && value != "json" { // Register the output format flag
+			return fmt.Errorf("unknown output format %q (want text or json)", value)
+		}
+		outputFormat = value
+		return nil
+	})
+}
+
+// Report whether results should be printed as JSON
+func jsonOutput() bool {
+	return outputFormat == "json"
+}
+
+// printJSON writes a command's result to stdout as indented JSON.
+func printJSON(value any) {
+	encoder := json.NewEncoder(os.Stdout) // Stdout carries only the result
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		fatalln(err)
+	}
+}
@@ -0,0 +1,72 @@
+package main // Define the main package
+
+import (
+	"log"         // For logging the run summary
+	"sync"        // For coordinating the stages
+	"sync/atomic" // For counting skipped links
+)
+
+// streamCrawl searches the queries and downloads what they turn up at the
+// same time: every search worker extracts the links of its results as soon
+// as they are saved and hands them to the download workers through a
+// channel, so the network is busy with downloads while searches are still
+// running. Links left over from earlier runs are downloaded first.
+func streamCrawl(queuedLinks []string, queries []string) {
+	downloadsAllowed := checkDiskSpace(nil) // Quota check; the size estimate needs the two-phase crawl
+	links := make(chan string, 256)         // Discovered links waiting for a download worker
+	var downloads sync.WaitGroup            // Tracks the download workers
+	for range max(downloadConcurrency, 1) {
+		downloads.Add(1)
+		go func() {
+			defer downloads.Done()
+			for link := range links {
+				waitWhilePaused() // Held back while -tui is paused
+				downloadsQueued.Add(-1)
+				if !quotaExhausted.Load() { // Drain without downloading once the quota is reached
+					downloadPDF(link)
+				}
+			}
+		}()
+	}
+	var (
+		enqueued      = make(map[string]bool) // Links already handed on this run
+		enqueuedMutex sync.Mutex              // Guards enqueued
+		skipped       atomic.Int64            // Links stored by earlier runs
+	)
+	enqueue := func(link string) {
+		enqueuedMutex.Lock()
+		duplicate := enqueued[link] // Many queries return the same documents
+		enqueued[link] = true
+		enqueuedMutex.Unlock()
+		if duplicate {
+			return
+		}
+		linksDiscovered.Add(1) // Count the links considered for download
+		emitEvent(event{Type: eventDiscovered, URL: link})
+		appendJournal(journalRecord{Op: journalLinkDiscovered, Target: link})
+		if alreadySeen(link) { // Stored by an earlier run
+			skipped.Add(1)
+			documentsSkipped.Add(1)
+			return
+		}
+		if downloadsAllowed {
+			downloadsQueued.Add(1)
+			links <- link
+		}
+	}
+	for _, link := range queuedLinks {
+		enqueue(link)
+	}
+	queriesQueued.Add(int64(len(queries)))
+	runWorkerPool(queries, searchConcurrency, func(query string) {
+		searchQuery(query)
+		for _, link := range newestFirst(savedResultLinks(query)) { // Searched now or by an earlier run
+			enqueue(link)
+		}
+	})
+	close(links)     // No more links are coming
+	downloads.Wait() // Let the in-flight downloads finish
+	if skipped.Load() > 0 {
+		log.Printf("skipped %d links already stored by earlier runs", skipped.Load())
+	}
+}
@@ -0,0 +1,49 @@
+package hillyard
+
+import (
+	"net/url" // For taking links apart
+	"strings" // For matching parameter names
+)
+
+// TrackingParams are query parameters that only tell the site where a click
+// came from; CanonicalURL drops them and utm_* parameters. Add to it before
+// parsing, not while searches run.
+var TrackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "msclkid": true, "dclid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true, "yclid": true,
+}
+
+// CanonicalURL rewrites a link so that links to the same document compare
+// equal: the scheme and host are lowercased, default ports, fragments and
+// tracking parameters are dropped, the remaining parameters are sorted and
+// percent-encoding is normalized. The path keeps its case, as servers
+// tell it apart. Unparsable links are returned unchanged.
+func CanonicalURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); parsed.Scheme == "http" && port == "80" || parsed.Scheme == "https" && port == "443" {
+		parsed.Host = strings.TrimSuffix(parsed.Host, ":"+port)
+	}
+	parsed.Fragment, parsed.RawFragment = "", ""
+	if !strings.Contains(strings.ToLower(parsed.RawPath), "%2f") { // An encoded slash is not a path separator
+		parsed.RawPath = "" // Re-encoded from the decoded path, e.g. %7e becomes ~
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	if parsed.RawQuery != "" {
+		if values, err := url.ParseQuery(parsed.RawQuery); err == nil { // Odd queries are kept as they are
+			for name := range values {
+				if lower := strings.ToLower(name); TrackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+					values.Del(name)
+				}
+			}
+			parsed.RawQuery = values.Encode() // Sorted by name
+		}
+	}
+	parsed.ForceQuery = false
+	return parsed.String()
+}
@@ -0,0 +1,34 @@
+package hillyard
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"already canonical", "https://www.hillyard.com/docs/floor.pdf", "https://www.hillyard.com/docs/floor.pdf"},
+		{"scheme and host lowercased", "HTTPS://WWW.Hillyard.com/Docs/Floor.pdf", "https://www.hillyard.com/Docs/Floor.pdf"},
+		{"default https port dropped", "https://www.hillyard.com:443/a.pdf", "https://www.hillyard.com/a.pdf"},
+		{"default http port dropped", "http://www.hillyard.com:80/a.pdf", "http://www.hillyard.com/a.pdf"},
+		{"other port kept", "http://localhost:8080/a.pdf", "http://localhost:8080/a.pdf"},
+		{"fragment dropped", "https://www.hillyard.com/a.pdf#page=2", "https://www.hillyard.com/a.pdf"},
+		{"utm parameters stripped", "https://www.hillyard.com/a.pdf?utm_source=mail&utm_Medium=x&id=7", "https://www.hillyard.com/a.pdf?id=7"},
+		{"click ids stripped", "https://www.hillyard.com/a.pdf?gclid=1&FBCLID=2", "https://www.hillyard.com/a.pdf"},
+		{"query sorted", "https://www.hillyard.com/getdocument?z=1&a=2&m=3", "https://www.hillyard.com/getdocument?a=2&m=3&z=1"},
+		{"valueless parameter kept", "https://www.hillyard.com/getdocument?lang&id=7", "https://www.hillyard.com/getdocument?id=7&lang="},
+		{"empty query dropped", "https://www.hillyard.com/a.pdf?", "https://www.hillyard.com/a.pdf"},
+		{"empty path becomes root", "https://www.hillyard.com", "https://www.hillyard.com/"},
+		{"percent-encoding normalized", "https://www.hillyard.com/%7euser/a.pdf", "https://www.hillyard.com/~user/a.pdf"},
+		{"encoded slash kept", "https://www.hillyard.com/a%2Fb.pdf", "https://www.hillyard.com/a%2Fb.pdf"},
+		{"relative link unchanged", "/docs/a.pdf", "/docs/a.pdf"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CanonicalURL(test.in); got != test.want {
+				t.Errorf("CanonicalURL(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
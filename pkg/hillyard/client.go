@@ -0,0 +1,232 @@
+// Package hillyard searches the document library of hillyard.com and
+// downloads the documents it lists, for tools built without the
+// command-line mirror around it:
+//
+//	client := hillyard.NewClient("")
+//	documents, err := client.SearchSDS(ctx, "floor finish")
+//	...
+//	err = client.DownloadDocument(ctx, documents[0], file)
+package hillyard
+
+import (
+	"context"  // For cancelling requests
+	"errors"   // For ErrNotPDF
+	"fmt"      // For error messages
+	"io"       // For streaming documents
+	"net/http" // For talking to the site
+	"net/url"  // For encoding search terms
+	"strings"  // For joining result pages
+)
+
+// DefaultBaseURL is the site a Client searches unless told otherwise.
+const DefaultBaseURL = "https://www.hillyard.com"
+
+// DefaultMaxPages is how many result pages a search follows by default.
+const DefaultMaxPages = 50
+
+// Kind is a class of document the site publishes, each with its own search.
+type Kind string
+
+// Document kinds the site publishes
+const (
+	SDS        Kind = "sds"        // Safety data sheets
+	TDS        Kind = "tds"        // Technical data sheets
+	Literature Kind = "literature" // Product literature
+	Label      Kind = "label"      // Product labels
+)
+
+// searchPaths are the search results pages of the kinds on the site.
+var searchPaths = map[Kind]string{
+	SDS:        "/safetydatasheet/search/results",
+	TDS:        "/technicaldatasheet/search/results",
+	Literature: "/productliterature/search/results",
+	Label:      "/productlabel/search/results",
+}
+
+// SearchPath is the path of the search results page of the kind, "" for
+// kinds the site does not publish.
+func (kind Kind) SearchPath() string {
+	return searchPaths[kind]
+}
+
+// ErrStopPaging is returned by a FetchPage hook to end a search before its
+// last page; the documents of the pages fetched so far are returned.
+var ErrStopPaging = errors.New("stop paging")
+
+// ErrNotPDF is returned when a document link serves something else, such
+// as the HTML page of an expired handler link.
+var ErrNotPDF = errors.New("not a PDF")
+
+// StatusError is returned when the site answers with a status other than
+// 200 OK.
+type StatusError struct {
+	URL        string // Requested URL
+	StatusCode int    // Status code, e.g. 404
+	Status     string // Status line, e.g. "404 Not Found"
+}
+
+// Error describes the failed request.
+func (statusError *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", statusError.URL, statusError.Status)
+}
+
+// Doer sends HTTP requests; *http.Client is one.
+type Doer interface {
+	Do(request *http.Request) (*http.Response, error)
+}
+
+// Client searches the site and downloads the documents it lists. The zero
+// value searches DefaultBaseURL with http.DefaultClient.
+type Client struct {
+	BaseURL     string          // Site to search, DefaultBaseURL when empty
+	HTTPClient  Doer            // Sends every request, http.DefaultClient when nil
+	UserAgent   string          // Sent with every request when set
+	MaxPages    int             // Result pages a search follows, DefaultMaxPages when 0
+	SearchPaths map[Kind]string // Search pages the site moved, overriding Kind.SearchPath
+
+	// FetchPage, when set, fetches each search results page instead of a
+	// plain GET through HTTPClient, e.g. to count requests against a budget
+	// or decode compressed pages. It may return ErrStopPaging.
+	FetchPage func(ctx context.Context, pageURL string) (string, error)
+}
+
+// Results is what a search found.
+type Results struct {
+	Documents []Document // Documents of every page fetched, in order
+	Pages     int        // Result pages fetched
+	Truncated bool       // Pages were left, past MaxPages or after ErrStopPaging
+}
+
+// NewClient returns a Client for the site at baseURL, e.g. a staging
+// mirror; an empty baseURL means DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: baseURL}
+}
+
+// SearchSDS returns the safety data sheets the site lists for query.
+func (client *Client) SearchSDS(ctx context.Context, query string) ([]Document, error) {
+	return client.Search(ctx, SDS, query)
+}
+
+// Search returns the documents of a kind the site lists for query,
+// following pagination so the documents of every page are returned in
+// order. Any failed page fails the search.
+func (client *Client) Search(ctx context.Context, kind Kind, query string) ([]Document, error) {
+	results, err := client.SearchPages(ctx, kind, query)
+	if err != nil {
+		return nil, err
+	}
+	return results.Documents, nil
+}
+
+// SearchPages is Search with the number of pages fetched and whether the
+// search stopped before its last page.
+func (client *Client) SearchPages(ctx context.Context, kind Kind, query string) (Results, error) {
+	searchPath := kind.SearchPath()
+	if override, found := client.SearchPaths[kind]; found {
+		searchPath = override
+	}
+	if searchPath == "" {
+		return Results{}, fmt.Errorf("unknown document kind %q", kind)
+	}
+	searchURL := strings.TrimSuffix(client.baseURL(), "/") + searchPath
+	pageURL := searchURL + "?" + url.Values{"q": {query}}.Encode()
+	var results Results
+	var pages []string               // Bodies of the pages fetched so far
+	visited := make(map[string]bool) // Guards against pagination loops
+	for pageURL != "" && !visited[pageURL] {
+		if len(pages) >= client.maxPages() {
+			results.Truncated = true
+			break
+		}
+		visited[pageURL] = true
+		body, err := client.fetchPage(ctx, pageURL)
+		if errors.Is(err, ErrStopPaging) {
+			results.Truncated = true
+			break
+		}
+		if err != nil {
+			return Results{}, err
+		}
+		pages = append(pages, body)
+		pageURL = NextPageURL(body, pageURL) // Empty on the last page
+	}
+	results.Documents, results.Pages = ParseResults(strings.Join(pages, "\n"), searchURL), len(pages)
+	return results, nil
+}
+
+// fetchPage fetches one search results page through FetchPage or a GET.
+func (client *Client) fetchPage(ctx context.Context, pageURL string) (string, error) {
+	if client.FetchPage != nil {
+		return client.FetchPage(ctx, pageURL)
+	}
+	response, err := client.get(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", pageURL, err)
+	}
+	return string(body), nil
+}
+
+// DownloadDocument writes the PDF a document links to into w, following
+// download handlers through their redirects. It returns ErrNotPDF when the
+// link serves something else. When the transfer fails part way, w holds
+// what arrived before.
+func (client *Client) DownloadDocument(ctx context.Context, document Document, w io.Writer) error {
+	response, err := client.get(ctx, document.URL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); !strings.Contains(contentType, "application/pdf") {
+		return fmt.Errorf("%s: %w (Content-Type %q)", document.URL, ErrNotPDF, contentType)
+	}
+	if _, err := io.Copy(w, response.Body); err != nil {
+		return fmt.Errorf("%s: %w", document.URL, err)
+	}
+	return nil
+}
+
+// get sends a GET request and returns the response of a 200 OK.
+func (client *Client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client.UserAgent != "" {
+		request.Header.Set("User-Agent", client.UserAgent)
+	}
+	var httpClient Doer = http.DefaultClient
+	if client.HTTPClient != nil {
+		httpClient = client.HTTPClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, &StatusError{URL: rawURL, StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return response, nil
+}
+
+// Site the client searches
+func (client *Client) baseURL() string {
+	if client.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return client.BaseURL
+}
+
+// Result pages a search follows
+func (client *Client) maxPages() int {
+	if client.MaxPages <= 0 {
+		return DefaultMaxPages
+	}
+	return client.MaxPages
+}
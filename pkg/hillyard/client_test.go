@@ -0,0 +1,192 @@
+package hillyard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedSearch serves /safetydatasheet/search/results as pages pages of two
+// documents each, linking every page to the next, and counts the requests.
+func pagedSearch(t *testing.T, pages int, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/safetydatasheet/search/results" {
+			http.NotFound(writer, request)
+			return
+		}
+		*requests++
+		if request.Header.Get("User-Agent") != "hillyard-test" {
+			t.Errorf("User-Agent = %q, want hillyard-test", request.Header.Get("User-Agent"))
+		}
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+		page = max(page, 1)
+		query := request.URL.Query().Get("q")
+		fmt.Fprintf(writer, `<a href="/docs/%s-%d-a.pdf">Product %d A</a><a href="/docs/%s-%d-b.pdf">Product %d B</a>`, query, page, page, query, page, page)
+		if page < pages {
+			fmt.Fprintf(writer, `<a rel="next" href="?q=%s&page=%d">Next</a>`, query, page+1)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     int // Pages the server has
+		maxPages  int // Client.MaxPages
+		wantPages int // Pages fetched
+		truncated bool
+	}{
+		{"single page", 1, 0, 1, false},
+		{"every page followed", 3, 0, 3, false},
+		{"stops at MaxPages", 5, 2, 2, true},
+		{"MaxPages equal to the pages", 3, 3, 3, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			server := pagedSearch(t, test.pages, &requests)
+			client := &Client{BaseURL: server.URL, HTTPClient: server.Client(), UserAgent: "hillyard-test", MaxPages: test.maxPages}
+			results, err := client.SearchPages(context.Background(), SDS, "floor")
+			if err != nil {
+				t.Fatalf("SearchPages() error = %v", err)
+			}
+			if results.Pages != test.wantPages || requests != test.wantPages || results.Truncated != test.truncated {
+				t.Errorf("SearchPages() = %d pages (%d requests), truncated %t; want %d pages, truncated %t", results.Pages, requests, results.Truncated, test.wantPages, test.truncated)
+			}
+			if len(results.Documents) != 2*test.wantPages {
+				t.Fatalf("SearchPages() returned %d documents, want %d", len(results.Documents), 2*test.wantPages)
+			}
+			for index, document := range results.Documents { // In page order
+				want := fmt.Sprintf("%s/docs/floor-%d-%c.pdf", server.URL, index/2+1, 'a'+index%2)
+				if document.URL != want {
+					t.Errorf("document %d = %q, want %q", index, document.URL, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientSearchEncodesQuery(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		got = request.URL.RawQuery
+	}))
+	defer server.Close()
+	client := &Client{BaseURL: server.URL, HTTPClient: server.Client()}
+	if _, err := client.Search(context.Background(), SDS, "H&S #4+"); err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if want := "q=H%26S+%234%2B"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+}
+
+func TestClientSearchPaths(t *testing.T) {
+	var requests int
+	server := pagedSearch(t, 1, &requests)
+	client := &Client{BaseURL: server.URL, HTTPClient: server.Client(), UserAgent: "hillyard-test", SearchPaths: map[Kind]string{TDS: "/safetydatasheet/search/results"}}
+	documents, err := client.Search(context.Background(), TDS, "wax")
+	if err != nil || len(documents) != 2 {
+		t.Errorf("Search() = %d documents, %v; want 2 documents from the overridden path", len(documents), err)
+	}
+	if _, err := client.Search(context.Background(), Kind("brochure"), "wax"); err == nil {
+		t.Error("Search() of an unknown kind succeeded")
+	}
+}
+
+func TestClientSearchFailedPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Query().Get("page") == "2" {
+			http.Error(writer, "down", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(writer, `<a href="/docs/a.pdf">A</a><a rel="next" href="?q=x&page=2">Next</a>`)
+	}))
+	defer server.Close()
+	client := &Client{BaseURL: server.URL, HTTPClient: server.Client()}
+	documents, err := client.Search(context.Background(), SDS, "x")
+	var statusError *StatusError
+	if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusBadGateway || documents != nil {
+		t.Errorf("Search() = %v, %v; want a 502 StatusError and no documents", documents, err)
+	}
+}
+
+func TestClientFetchPage(t *testing.T) {
+	pages := map[string]string{
+		"/safetydatasheet/search/results?q=x":        `<a href="/docs/1.pdf">One</a><a rel="next" href="?q=x&page=2">Next</a>`,
+		"/safetydatasheet/search/results?q=x&page=2": `<a href="/docs/2.pdf">Two</a><a rel="next" href="?q=x&page=3">Next</a>`,
+	}
+	var fetched []string
+	client := &Client{BaseURL: "https://example.test", FetchPage: func(ctx context.Context, pageURL string) (string, error) {
+		fetched = append(fetched, pageURL)
+		if len(fetched) > 2 {
+			return "", ErrStopPaging
+		}
+		return pages[pageURL[len("https://example.test"):]], nil
+	}}
+	results, err := client.SearchPages(context.Background(), SDS, "x")
+	if err != nil {
+		t.Fatalf("SearchPages() error = %v", err)
+	}
+	if len(fetched) != 3 || results.Pages != 2 || !results.Truncated || len(results.Documents) != 2 {
+		t.Errorf("SearchPages() fetched %v and returned %+v; want 2 pages, then a stop", fetched, results)
+	}
+}
+
+func TestDownloadDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/getdocument":
+			http.Redirect(writer, request, "/docs/floor.pdf", http.StatusFound)
+		case "/docs/floor.pdf":
+			writer.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(writer, "%PDF-1.7 floor")
+		case "/expired":
+			writer.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(writer, "<html>Session expired</html>")
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	defer server.Close()
+	client := &Client{BaseURL: server.URL, HTTPClient: server.Client()}
+
+	t.Run("follows handlers to the PDF", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := client.DownloadDocument(context.Background(), Document{URL: server.URL + "/getdocument?id=1"}, &buffer); err != nil {
+			t.Fatalf("DownloadDocument() error = %v", err)
+		}
+		if buffer.String() != "%PDF-1.7 floor" {
+			t.Errorf("DownloadDocument() wrote %q", buffer.String())
+		}
+	})
+	t.Run("status error", func(t *testing.T) {
+		err := client.DownloadDocument(context.Background(), Document{URL: server.URL + "/docs/missing.pdf"}, &bytes.Buffer{})
+		var statusError *StatusError
+		if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusNotFound {
+			t.Errorf("DownloadDocument() error = %v, want a 404 StatusError", err)
+		}
+	})
+	t.Run("not a PDF", func(t *testing.T) {
+		var buffer bytes.Buffer
+		err := client.DownloadDocument(context.Background(), Document{URL: server.URL + "/expired"}, &buffer)
+		if !errors.Is(err, ErrNotPDF) || buffer.Len() != 0 {
+			t.Errorf("DownloadDocument() error = %v with %d bytes written, want ErrNotPDF and nothing written", err, buffer.Len())
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := client.DownloadDocument(ctx, Document{URL: server.URL + "/docs/floor.pdf"}, &bytes.Buffer{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("DownloadDocument() error = %v, want context.Canceled", err)
+		}
+	})
+}
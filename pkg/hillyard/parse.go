@@ -0,0 +1,444 @@
+package hillyard
+
+import (
+	"encoding/json" // For JSON search responses
+	"net/url"       // For resolving relative links
+	"regexp"        // For spotting download handler paths
+	"slices"        // For reading rel attributes
+	"strconv"       // For page numbers
+	"strings"       // For string manipulation
+	"time"          // For revision dates
+	"unicode"       // For splitting titles into words
+
+	"golang.org/x/net/html" // For parsing search result pages
+)
+
+// documentHandlerPathRegex matches download handler paths such as
+// /getdocument?id=... that only reveal a PDF after redirects.
+var documentHandlerPathRegex = regexp.MustCompile(`(?i)/(?:getdocument|getfile|download|documents?|attachments?)(?:/|$)`)
+
+// Document is a link to a document found in a search result page.
+type Document struct {
+	URL      string `json:"url"`                // Absolute document URL
+	Title    string `json:"title,omitempty"`    // Product name, used as the document title
+	Label    string `json:"label,omitempty"`    // Anchor text, which often names the language ("SDS (English)")
+	Category string `json:"category,omitempty"` // Product category of the card or object, e.g. "Floor Care"
+	Revised  string `json:"revised,omitempty"`  // Revision date listed with the document, as YYYY-MM-DD
+}
+
+// ParseResults parses an HTML or JSON search result page and returns every
+// link that points at a PDF or a download handler, resolved against baseURL,
+// with the product name as its title. Each URL is returned once, keeping the
+// first non-empty title seen for it.
+func ParseResults(htmlContent string, baseURL string) []Document {
+	base, err := url.Parse(baseURL) // Base for relative links
+	if err != nil {
+		return nil
+	}
+	if trimmed := strings.TrimSpace(htmlContent); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") { // A JSON search API answered
+		return parseJSONResults(trimmed, base)
+	}
+	document, err := html.Parse(strings.NewReader(htmlContent)) // Build the DOM
+	if err != nil {
+		return nil
+	}
+	var links []Document              // Links in page order
+	positions := make(map[string]int) // URL → index in links
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "a" {
+			if documentURL := resolveDocumentHref(base, attributeValue(node, "href")); documentURL != "" {
+				title, label := anchorTitle(node), strings.Join(strings.Fields(nodeText(node)), " ") // Product name and the link's own text
+				if index, seen := positions[documentURL]; !seen {
+					positions[documentURL] = len(links)
+					links = append(links, Document{URL: documentURL, Title: title, Label: label, Category: anchorCategory(node), Revised: anchorRevision(node)})
+				} else if links[index].Title == "" { // Prefer a descriptive title over an icon link
+					links[index].Title = title
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child) // Walk the whole tree
+		}
+	}
+	visit(document)
+	return links
+}
+
+// genericLinkWords are anchor texts that name the kind of document or its
+// language rather than the product, like "SDS" or "Download (English)".
+var genericLinkWords = map[string]bool{
+	"sds": true, "msds": true, "pdf": true, "download": true, "view": true, "open": true, "safety": true, "data": true, "sheet": true,
+	"tds": true, "technical": true, "literature": true, "brochure": true, "label": true, "product": true,
+	"english": true, "spanish": true, "french": true, "español": true, "français": true, "en": true, "es": true, "fr": true,
+}
+
+// genericTitle reports whether a title says nothing about the product.
+func genericTitle(title string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(character rune) bool {
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character)
+	}) {
+		if !genericLinkWords[word] {
+			return false
+		}
+	}
+	return true // Also true for an empty title
+}
+
+// anchorTitle returns the product name for a document link: the anchor text,
+// or, when that only says "SDS" or names a language, a title attribute or
+// the heading of the product card the link sits in.
+func anchorTitle(anchor *html.Node) string {
+	title := strings.Join(strings.Fields(nodeText(anchor)), " ") // Collapse whitespace in the anchor text
+	if !genericTitle(title) {
+		return title
+	}
+	for _, attribute := range []string{"data-product-name", "aria-label", "title"} {
+		if value := strings.Join(strings.Fields(attributeValue(anchor, attribute)), " "); !genericTitle(value) {
+			return value
+		}
+	}
+	container := anchor.Parent
+	for level := 0; container != nil && level < 5; level, container = level+1, container.Parent { // The nearest card, row or list item
+		if heading := findHeading(container, anchor); heading != "" {
+			return heading
+		}
+	}
+	return title
+}
+
+// anchorCategory returns the product category of a document link: a
+// data-category attribute on the link or the card around it, or the text of
+// an element whose class mentions a category inside that card.
+func anchorCategory(anchor *html.Node) string {
+	container := anchor
+	for level := 0; container != nil && level < 6; level, container = level+1, container.Parent { // The link, then the nearest card, row or list item
+		if value := strings.Join(strings.Fields(attributeValue(container, "data-category")), " "); value != "" {
+			return value
+		}
+		if container != anchor {
+			if text := findClassText(container, anchor, "category"); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// revisionAttributes are the attributes a card may list the revision date in.
+var revisionAttributes = []string{"data-revision-date", "data-revised", "data-revision", "data-updated"}
+
+// anchorRevision returns the revision date listed with a document link: a
+// revision attribute on the link or the card around it, or the text of an
+// element whose class mentions a revision inside that card.
+func anchorRevision(anchor *html.Node) string {
+	container := anchor
+	for level := 0; container != nil && level < 6; level, container = level+1, container.Parent { // The link, then the nearest card, row or list item
+		for _, attribute := range revisionAttributes {
+			if date := parseRevisionDate(attributeValue(container, attribute)); date != "" {
+				return date
+			}
+		}
+		if container != anchor {
+			if date := parseRevisionDate(findClassText(container, anchor, "revis")); date != "" {
+				return date
+			}
+		}
+	}
+	return ""
+}
+
+// revisionDatePattern finds a date inside text like "Revised: 03/15/2024".
+var revisionDatePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}|\d{1,2}/\d{1,2}/\d{4}|[A-Z][a-z]+\.? \d{1,2}, \d{4}`)
+
+// revisionDateLayouts are the date formats revision dates are listed in.
+var revisionDateLayouts = []string{time.DateOnly, "1/2/2006", "January 2, 2006", "Jan 2, 2006", "Jan. 2, 2006"}
+
+// parseRevisionDate returns the first date in text as YYYY-MM-DD, or "".
+// Slashed dates are read month first, as on US sites.
+func parseRevisionDate(text string) string {
+	if parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(text)); err == nil { // Timestamps of JSON APIs
+		return parsed.UTC().Format(time.DateOnly)
+	}
+	match := revisionDatePattern.FindString(text)
+	for _, layout := range revisionDateLayouts {
+		if parsed, err := time.Parse(layout, match); err == nil {
+			return parsed.Format(time.DateOnly)
+		}
+	}
+	return ""
+}
+
+// findClassText returns the text of the first element whose class mentions
+// word under node and outside skip. Elements holding links are not
+// searched: they are the cards of other documents.
+func findClassText(node, skip *html.Node, word string) string {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child == skip || child.Type != html.ElementNode {
+			continue
+		}
+		if strings.Contains(strings.ToLower(attributeValue(child, "class")), word) {
+			if text := strings.Join(strings.Fields(nodeText(child)), " "); text != "" {
+				return text
+			}
+		}
+		if containsAnchor(child) {
+			continue
+		}
+		if text := findClassText(child, skip, word); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// containsAnchor reports whether node is or holds a link.
+func containsAnchor(node *html.Node) bool {
+	if node.Type == html.ElementNode && node.Data == "a" {
+		return true
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if containsAnchor(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// findHeading returns the text of the first heading, or element whose
+// class mentions a title or name, under node and outside skip.
+func findHeading(node, skip *html.Node) string {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child == skip || child.Type != html.ElementNode {
+			continue
+		}
+		class := strings.ToLower(attributeValue(child, "class"))
+		if len(child.Data) == 2 && child.Data[0] == 'h' && child.Data[1] >= '1' && child.Data[1] <= '6' || strings.Contains(class, "title") || strings.Contains(class, "name") {
+			if text := strings.Join(strings.Fields(nodeText(child)), " "); !genericTitle(text) {
+				return text
+			}
+		}
+		if text := findHeading(child, skip); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// jsonTitleKeys are the fields of a JSON search result naming the product,
+// in order of preference.
+var jsonTitleKeys = []string{"productname", "product_name", "name", "title", "product", "displayname", "display_name"}
+
+// jsonCategoryKeys are the fields of a JSON search result naming the
+// product category, in order of preference.
+var jsonCategoryKeys = []string{"category", "categoryname", "category_name", "productcategory", "product_category"}
+
+// jsonRevisionKeys are the fields of a JSON search result holding the
+// revision date of the document, in order of preference.
+var jsonRevisionKeys = []string{"revisiondate", "revision_date", "reviseddate", "revised_date", "revised", "issuedate", "issue_date", "lastmodified", "last_modified", "updatedat", "updated_at", "updated"}
+
+// jsonRevision returns the revision date of a JSON object as YYYY-MM-DD, or "".
+func jsonRevision(object map[string]any) string {
+	for _, key := range jsonRevisionKeys {
+		for field, value := range object {
+			if text, ok := value.(string); ok && strings.EqualFold(field, key) {
+				if date := parseRevisionDate(text); date != "" {
+					return date
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// jsonCategory returns the category field of a JSON object, or "".
+func jsonCategory(object map[string]any) string {
+	for _, key := range jsonCategoryKeys {
+		for field, value := range object {
+			if text, ok := value.(string); ok && strings.EqualFold(field, key) && strings.TrimSpace(text) != "" {
+				return strings.Join(strings.Fields(text), " ")
+			}
+		}
+	}
+	return ""
+}
+
+// jsonTitle returns the product name field of a JSON object, or "".
+func jsonTitle(object map[string]any, base *url.URL) string {
+	for _, key := range jsonTitleKeys {
+		for field, value := range object {
+			if text, ok := value.(string); ok && strings.EqualFold(field, key) && !genericTitle(text) && resolveDocumentHref(base, text) == "" {
+				return strings.Join(strings.Fields(text), " ")
+			}
+		}
+	}
+	return ""
+}
+
+// parseJSONResults returns the document URLs in a JSON search response
+// with the product name of the object holding them, or of the nearest
+// enclosing object that has one. Saved results of several pages
+// hold one JSON value per page.
+func parseJSONResults(content string, base *url.URL) []Document {
+	var links []Document
+	positions := make(map[string]int) // URL → index in links
+	var visit func(value any, title, category, revised string)
+	visit = func(value any, title, category, revised string) {
+		switch typed := value.(type) {
+		case map[string]any:
+			if name := jsonTitle(typed, base); name != "" { // This object names a product
+				title = name
+			}
+			if name := jsonCategory(typed); name != "" { // Or the category of its products
+				category = name
+			}
+			if date := jsonRevision(typed); date != "" { // Or when its document was revised
+				revised = date
+			}
+			for _, fieldValue := range typed {
+				visit(fieldValue, title, category, revised)
+			}
+		case []any:
+			for _, element := range typed {
+				visit(element, title, category, revised)
+			}
+		case string:
+			if documentURL := resolveDocumentHref(base, typed); documentURL != "" && strings.Contains(typed, "/") {
+				if index, seen := positions[documentURL]; !seen {
+					positions[documentURL] = len(links)
+					links = append(links, Document{URL: documentURL, Title: title, Category: category, Revised: revised})
+				} else if links[index].Title == "" {
+					links[index].Title = title
+				}
+			}
+		}
+	}
+	decoder := json.NewDecoder(strings.NewReader(content))
+	for {
+		var page any
+		if err := decoder.Decode(&page); err != nil { // End of the saved pages, or not JSON after all
+			break
+		}
+		visit(page, "", "", "")
+	}
+	return links
+}
+
+// resolveDocumentHref resolves an href and returns it if it points at a
+// document, or "" if it does not.
+func resolveDocumentHref(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "" // Empty or in-page link
+	}
+	reference, err := url.Parse(href)
+	if err != nil {
+		return "" // Malformed link
+	}
+	resolved := base.ResolveReference(reference) // Make the link absolute
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return "" // mailto:, javascript: and friends
+	}
+	resolved.Fragment = "" // Fragments never change the document
+	if !strings.HasSuffix(strings.ToLower(resolved.Path), ".pdf") && !documentHandlerPathRegex.MatchString(resolved.Path) {
+		return "" // Not a document link
+	}
+	return CanonicalURL(resolved.String()) // One spelling per document, for deduplication
+}
+
+// attributeValue returns the value of a node's attribute, or "".
+func attributeValue(node *html.Node, name string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Key == name {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the concatenated text content of a node.
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var text strings.Builder // Text of all descendants
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(nodeText(child))
+		text.WriteString(" ") // Keep words in sibling elements apart
+	}
+	return text.String()
+}
+
+// pageParameters are query parameters search pages use to number pages or
+// offset results, checked in this order.
+var pageParameters = []string{"page", "p", "pg", "offset", "start"}
+
+// NextPageURL returns the next page of a paginated search result, or ""
+// on the last page. An explicit rel="next" link wins; otherwise it looks
+// for a link to the same search whose page number or offset comes right
+// after the current one.
+func NextPageURL(htmlContent string, currentURL string) string {
+	current, err := url.Parse(currentURL)
+	if err != nil {
+		return ""
+	}
+	document, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	var relNext string      // Target of rel="next"
+	var candidates []string // Links to the same search with another page
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && (node.Data == "a" || node.Data == "link") {
+			if href := strings.TrimSpace(attributeValue(node, "href")); href != "" {
+				if reference, err := url.Parse(href); err == nil {
+					resolved := current.ResolveReference(reference)
+					resolved.Fragment = ""
+					if relNext == "" && slices.Contains(strings.Fields(strings.ToLower(attributeValue(node, "rel"))), "next") {
+						relNext = resolved.String()
+					} else if resolved.Path == current.Path && resolved.Query().Get("q") == current.Query().Get("q") {
+						candidates = append(candidates, resolved.String())
+					}
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(document)
+	if relNext != "" {
+		return relNext
+	}
+	for _, parameter := range pageParameters {
+		position := pagePosition(current, parameter) // Where we are
+		best, bestPosition := "", -1
+		for _, candidate := range candidates {
+			parsed, _ := url.Parse(candidate)
+			if !parsed.Query().Has(parameter) {
+				continue
+			}
+			// The closest page after the current one is the next page
+			if candidatePosition := pagePosition(parsed, parameter); candidatePosition > position && (bestPosition < 0 || candidatePosition < bestPosition) {
+				best, bestPosition = candidate, candidatePosition
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
+
+// pagePosition reads a page number or offset, defaulting to the first page.
+func pagePosition(pageURL *url.URL, parameter string) int {
+	value, err := strconv.Atoi(pageURL.Query().Get(parameter))
+	if err != nil {
+		if parameter == "offset" || parameter == "start" { // Offsets count from zero
+			return 0
+		}
+		return 1
+	}
+	return value
+}
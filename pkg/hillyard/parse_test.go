@@ -0,0 +1,110 @@
+package hillyard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResults(t *testing.T) {
+	const base = "https://www.hillyard.com/safetydatasheet/search/results"
+	tests := []struct {
+		name    string
+		content string
+		want    []Document
+	}{
+		{
+			name:    "relative PDF link",
+			content: `<a href="/docs/Floor-Finish.pdf">Floor Finish</a>`,
+			want:    []Document{{URL: "https://www.hillyard.com/docs/Floor-Finish.pdf", Title: "Floor Finish", Label: "Floor Finish"}},
+		},
+		{
+			name:    "download handler",
+			content: `<a href="/getdocument?id=42&utm_source=x">Bleach</a>`,
+			want:    []Document{{URL: "https://www.hillyard.com/getdocument?id=42", Title: "Bleach", Label: "Bleach"}},
+		},
+		{
+			name:    "non-document links skipped",
+			content: `<a href="/products/floor">Floor</a><a href="#top">Top</a><a href="mailto:a@b.c">Mail</a><a href="javascript:void(0)">JS</a>`,
+			want:    nil,
+		},
+		{
+			name: "generic anchor takes the card heading",
+			content: `<div class="card"><h3>Super Shine-All</h3><span class="category">Floor Care</span>
+				<span class="revised">Revised: 03/15/2024</span><a href="/docs/shine-es.pdf">SDS (Español)</a></div>`,
+			want: []Document{{URL: "https://www.hillyard.com/docs/shine-es.pdf", Title: "Super Shine-All", Label: "SDS (Español)", Category: "Floor Care", Revised: "2024-03-15"}},
+		},
+		{
+			name:    "duplicate keeps the first descriptive title",
+			content: `<a href="/docs/a.pdf#p1"><img src="pdf.png"></a><a href="/docs/a.pdf">Arsenal Cleaner</a>`,
+			want:    []Document{{URL: "https://www.hillyard.com/docs/a.pdf", Title: "Arsenal Cleaner"}},
+		},
+		{
+			name:    "JSON results",
+			content: `{"results":[{"productName":"Glass Cleaner","category":"Glass","sdsUrl":"/docs/glass.pdf"}]}`,
+			want:    []Document{{URL: "https://www.hillyard.com/docs/glass.pdf", Title: "Glass Cleaner", Category: "Glass"}},
+		},
+		{
+			name:    "JSON pages one after another",
+			content: "[{\"name\":\"One\",\"url\":\"/docs/one.pdf\"}]\n[{\"name\":\"Two\",\"url\":\"/docs/two.pdf\"}]",
+			want: []Document{
+				{URL: "https://www.hillyard.com/docs/one.pdf", Title: "One"},
+				{URL: "https://www.hillyard.com/docs/two.pdf", Title: "Two"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseResults(test.content, base); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseResults() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	const current = "https://www.hillyard.com/safetydatasheet/search/results?q=floor"
+	tests := []struct {
+		name    string
+		current string
+		content string
+		want    string
+	}{
+		{
+			name:    "rel next wins",
+			current: current,
+			content: `<a href="?q=floor&page=5">5</a><link rel="next" href="?q=floor&page=2">`,
+			want:    "https://www.hillyard.com/safetydatasheet/search/results?q=floor&page=2",
+		},
+		{
+			name:    "closest later page number",
+			current: current + "&page=2",
+			content: `<a href="?q=floor&page=1">1</a><a href="?q=floor&page=4">4</a><a href="?q=floor&page=3">3</a>`,
+			want:    "https://www.hillyard.com/safetydatasheet/search/results?q=floor&page=3",
+		},
+		{
+			name:    "offset pagination",
+			current: current,
+			content: `<a href="?q=floor&offset=20">Next</a>`,
+			want:    "https://www.hillyard.com/safetydatasheet/search/results?q=floor&offset=20",
+		},
+		{
+			name:    "other searches ignored",
+			current: current,
+			content: `<a href="?q=wax&page=2">2</a><a href="/other/results?q=floor&page=2">2</a>`,
+			want:    "",
+		},
+		{
+			name:    "last page",
+			current: current + "&page=3",
+			content: `<a href="?q=floor&page=1">1</a><a href="?q=floor&page=2">2</a>`,
+			want:    "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NextPageURL(test.content, test.current); got != test.want {
+				t.Errorf("NextPageURL() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main // Define the main package
+
+import (
+	"context"       // For cancelled waits
+	"encoding/json" // For the shared state file
+	"errors"        // For detecting a held lock
+	"flag"          // For command-line flag parsing
+	"fmt"           // For the lock owner
+	"log"           // For logging messages and errors
+	"os"            // For the state and lock files
+	"path/filepath" // For the default state path
+	"time"          // For request slots
+)
+
+// staleLockAge is how old a lock file must be before it is assumed to
+// belong to a crashed process and is broken.
+const staleLockAge = 10 * time.Second
+
+var (
+	sharedRate     float64 // Requests per second allowed per origin across every process
+	politenessFile string  // State shared by every process using the same origin
+)
+
+func init() {
+	flag.Float64Var(&sharedRate, "shared-rate", 0, "requests per second allowed per origin across every process sharing -politeness-file, e.g. concurrent profile runs (0 = no shared budget)") // Register the shared rate flag
+	flag.StringVar(&politenessFile, "politeness-file", filepath.Join(os.TempDir(), "hillyard-politeness.json"), "state file shared by processes that use -shared-rate")                         // Register the shared state flag
+}
+
+// hostPoliteness is the shared state of one origin.
+type hostPoliteness struct {
+	NextAt      time.Time `json:"next_at"`      // Earliest time the next request may start
+	PausedUntil time.Time `json:"paused_until"` // End of a Retry-After pause any process was given
+}
+
+// updatePoliteness runs change on the shared state while holding the lock
+// file, creating the state on first use.
+func updatePoliteness(ctx context.Context, change func(state map[string]*hostPoliteness)) error {
+	lockPath := politenessFile + ".lock"
+	owner := fmt.Appendf(nil, "%d %d\n", os.Getpid(), time.Now().UnixNano()) // Tells this holder from a later one
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = lock.Write(owner)
+			lock.Close()
+			if err != nil {
+				os.Remove(lockPath)
+				return err
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		held, _ := os.ReadFile(lockPath) // Read before the age, so a newer holder is never taken for the stale one
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			removeStaleLock(lockPath, held) // Left behind by a crashed process, unless another process took it over first
+			continue
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer removeStaleLock(lockPath, owner) // Only while it is still ours, if this took long enough to be broken
+	state := make(map[string]*hostPoliteness)
+	if content, err := os.ReadFile(politenessFile); err == nil {
+		json.Unmarshal(content, &state) // A corrupt file just starts over
+	}
+	change(state)
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(politenessFile, content, 0644)
+}
+
+// acquireSharedSlot waits for this process's turn to send a request to
+// host, so all processes together stay within -shared-rate and honour a
+// pause any one of them was given.
+func acquireSharedSlot(ctx context.Context, host string) error {
+	if sharedRate <= 0 { // No shared budget configured
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / sharedRate) // Spacing between requests
+	var slot time.Time
+	err := updatePoliteness(ctx, func(state map[string]*hostPoliteness) {
+		origin := state[host]
+		if origin == nil {
+			origin = &hostPoliteness{}
+			state[host] = origin
+		}
+		slot = time.Now()
+		if origin.NextAt.After(slot) {
+			slot = origin.NextAt
+		}
+		if origin.PausedUntil.After(slot) {
+			slot = origin.PausedUntil
+		}
+		origin.NextAt = slot.Add(interval)
+	})
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sharePause tells the other processes about a Retry-After pause.
+func sharePause(host string, until time.Time) {
+	if sharedRate <= 0 { // No shared budget configured
+		return
+	}
+	err := updatePoliteness(context.Background(), func(state map[string]*hostPoliteness) {
+		origin := state[host]
+		if origin == nil {
+			origin = &hostPoliteness{}
+			state[host] = origin
+		}
+		if until.After(origin.PausedUntil) {
+			origin.PausedUntil = until
+		}
+	})
+	if err != nil {
+		log.Printf("failed to share pause with other processes: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For the entry on stdin
+	"context"       // For the hook timeout
+	"encoding/json" // For the entry on stdin
+	"flag"          // For command-line flag parsing
+	"fmt"           // For the environment
+	"log"           // For logging hook output
+	"os"            // For the inherited environment
+	"os/exec"       // For running the command
+	"path/filepath" // For the absolute path
+	"strings"       // For splitting the command
+	"time"          // For the hook timeout
+)
+
+var (
+	postDownloadCommand string             // Command run for every stored PDF, "" for none
+	postDownloadTimeout time.Duration      // Longest run of the command
+	postDownloadReject  bool               // Discard files the command fails on
+	postDownloadHooks   []PostDownloadHook // Hooks run for every stored PDF, in order
+)
+
+func init() {
+	flag.StringVar(&postDownloadCommand, "post-download-cmd", "", "command run for every stored PDF with its path appended, HILLYARD_* variables set and the manifest entry as JSON on stdin, e.g. a virus scan or CMS import") // Register the hook command flag
+	flag.DurationVar(&postDownloadTimeout, "post-download-timeout", 2*time.Minute, "longest run of -post-download-cmd for one file")                                                                                            // Register the hook timeout flag
+	flag.BoolVar(&postDownloadReject, "post-download-reject", false, "delete a PDF and record a failure when -post-download-cmd exits non-zero, instead of keeping it")                                                         // Register the hook rejection flag
+}
+
+// PostDownloadHook processes a PDF once it is stored and before it enters
+// the manifest. Returning an error reports the hook failed; whether the file
+// is kept then is up to --post-download-reject. Go code adds hooks to
+// postDownloadHooks before the first download.
+type PostDownloadHook interface {
+	Name() string                                         // Name used in logs
+	AfterDownload(path string, entry manifestEntry) error // Process one stored file
+}
+
+// commandHook runs --post-download-cmd.
+type commandHook struct {
+	command []string // Program and arguments
+}
+
+// Name returns the program run.
+func (hook commandHook) Name() string { return hook.command[0] }
+
+// AfterDownload runs the command on one file.
+func (hook commandHook) AfterDownload(path string, entry manifestEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postDownloadTimeout)
+	defer cancel()
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		absolute = path
+	}
+	input, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	command := exec.CommandContext(ctx, hook.command[0], append(hook.command[1:], absolute)...)
+	command.Stdin = bytes.NewReader(input)
+	command.Env = append(os.Environ(),
+		"HILLYARD_FILE="+absolute,
+		"HILLYARD_URL="+entry.URL,
+		"HILLYARD_TITLE="+entry.Title,
+		"HILLYARD_CATEGORY="+entry.Category,
+		"HILLYARD_LANGUAGE="+entry.Language,
+		"HILLYARD_DOC_TYPE="+entryDocType(entry).Name,
+		"HILLYARD_SHA256="+entry.SHA256,
+		fmt.Sprintf("HILLYARD_SIZE=%d", entry.Size),
+	)
+	output, err := command.CombinedOutput()
+	if text := strings.TrimSpace(string(output)); text != "" {
+		log.Printf("%s %s: %s", hook.Name(), entry.File, text)
+	}
+	return err
+}
+
+// activePostDownloadHooks returns the hooks of the run, adding
+// --post-download-cmd first.
+func activePostDownloadHooks() []PostDownloadHook {
+	if fields := strings.Fields(postDownloadCommand); len(fields) > 0 {
+		return append([]PostDownloadHook{commandHook{command: fields}}, postDownloadHooks...)
+	}
+	return postDownloadHooks
+}
+
+// runPostDownloadHooks runs every hook on a stored file and reports false
+// when the file must be discarded.
+func runPostDownloadHooks(path string, entry manifestEntry) bool {
+	for _, hook := range activePostDownloadHooks() {
+		err := hook.AfterDownload(path, entry)
+		if err == nil {
+			continue
+		}
+		if postDownloadReject {
+			recordFailure(failureKindDownload, entry.URL, reasonHookRejected, fmt.Sprintf("%s: %v", hook.Name(), err))
+			return false
+		}
+		log.Printf("post-download hook %s failed for %s, keeping it: %v", hook.Name(), entry.File, err)
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package main // Define the main package
+
+import (
+	"bufio"          // For reading the confirmation
+	"flag"           // For command-line flag parsing
+	"fmt"            // For the size table
+	"log"            // For logging the outcome
+	"mime"           // For reading Content-Type
+	"net/http"       // For HEAD probes
+	"os"             // For the terminal prompt
+	"sort"           // For ordering the table
+	"strings"        // For the answer
+	"sync"           // For caching probes across phases
+	"sync/atomic"    // For counting dead links
+	"text/tabwriter" // For the size table
+)
+
+var (
+	preflight  bool     // HEAD every pending link before the first download
+	assumeYes  bool     // Start the downloads without asking
+	linkProbes sync.Map // Link → *linkProbe, so --space-check reuses the pre-flight answers
+)
+
+func init() {
+	flag.BoolVar(&preflight, "preflight", false, "send a HEAD request for every pending download first: drop dead links, print the expected size by content type and ask before downloading") // Register the pre-flight flag
+	flag.BoolVar(&assumeYes, "yes", false, "download after the pre-flight without asking; runs without a terminal never ask")                                                                 // Register the confirmation flag
+}
+
+// linkProbe is the answer to the HEAD request of one link.
+type linkProbe struct {
+	Status      int    // HTTP status, 0 when no answer came
+	Length      int64  // Content-Length, -1 when not reported
+	ContentType string // Media type without parameters, "" when not reported
+}
+
+// dead reports whether the server says the document is gone.
+func (probe *linkProbe) dead() bool {
+	return probe.Status == http.StatusNotFound || probe.Status == http.StatusGone
+}
+
+// probeLink sends a HEAD request for a link once per run.
+func probeLink(link string) *linkProbe {
+	if cached, found := linkProbes.Load(link); found {
+		return cached.(*linkProbe)
+	}
+	probe := &linkProbe{Length: -1}
+	if reserveRequest() { // Probes count against the run budget too
+		if resp, err := fetchURL(http.MethodHead, link); err == nil { // Ask for the headers only
+			resp.Body.Close()
+			probe.Status, probe.Length = resp.StatusCode, resp.ContentLength
+			probe.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		}
+	}
+	linkProbes.Store(link, probe)
+	return probe
+}
+
+// preflightLinks probes the pending downloads with --preflight, drops the
+// dead ones and prints what the rest would download. It returns the links
+// to download and false when the operator declined.
+func preflightLinks(links []string) ([]string, bool) {
+	if !preflight || len(links) == 0 {
+		return links, true
+	}
+	var dead atomic.Int64
+	runWorkerPool(links, searchConcurrency, func(link string) {
+		if probe := probeLink(link); probe.dead() {
+			recordFailure(failureKindDownload, link, reasonHTTPStatus, "HEAD "+http.StatusText(probe.Status)) // Retried by the next run in case it comes back
+			dead.Add(1)
+		}
+	})
+	var live []string // Links still worth downloading, in queue order
+	type typeTotal struct {
+		links, bytes, unknown int64
+	}
+	totals := make(map[string]*typeTotal) // Content type → expected download
+	var totalBytes, unknownLinks int64
+	for _, link := range links {
+		probe := probeLink(link)
+		if probe.dead() {
+			continue
+		}
+		live = append(live, link)
+		contentType := probe.ContentType
+		if contentType == "" {
+			contentType = "unknown"
+		}
+		if totals[contentType] == nil {
+			totals[contentType] = &typeTotal{}
+		}
+		totals[contentType].links++
+		if probe.Status != http.StatusOK || probe.Length < 0 { // No size ahead of time, e.g. HEAD not allowed
+			totals[contentType].unknown++
+			unknownLinks++
+			continue
+		}
+		totals[contentType].bytes += probe.Length
+		totalBytes += probe.Length
+	}
+	types := make([]string, 0, len(totals))
+	for contentType := range totals {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CONTENT TYPE\tLINKS\tBYTES\tUNKNOWN SIZE")
+	for _, contentType := range types {
+		total := totals[contentType]
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\n", contentType, total.links, total.bytes, total.unknown)
+	}
+	fmt.Fprintf(writer, "total\t%d\t%d\t%d\n", len(live), totalBytes, unknownLinks)
+	writer.Flush()
+	log.Printf("pre-flight: %d links to download, about %s (%d without a known size), %d dead links dropped", len(live), formatByteSize(totalBytes), unknownLinks, dead.Load())
+	if len(live) == 0 || assumeYes || !stdinIsTerminal() {
+		return live, true
+	}
+	fmt.Fprintf(os.Stderr, "Download %d documents, about %s? [y/N] ", len(live), formatByteSize(totalBytes))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" { // Nobody to ask after all, e.g. stdin is /dev/null
+		fmt.Fprintln(os.Stderr)
+		return live, true
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		log.Println("downloads cancelled; the links stay queued for the next run")
+		return nil, false
+	}
+	return live, true
+}
+
+// stdinIsTerminal reports whether someone can answer a prompt.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatByteSize renders a byte count for people, e.g. "12.3 MiB".
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
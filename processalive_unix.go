@@ -0,0 +1,14 @@
+//go:build unix
+
+package main // Define the main package
+
+import (
+	"errors"  // For telling a missing process from one we may not signal
+	"syscall" // For probing the process
+)
+
+// processAlive reports whether a process with this PID exists on this host.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)                        // Signal 0 only checks
+	return err == nil || errors.Is(err, syscall.EPERM) // Exists but belongs to another user
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package main // Define the main package
+
+import (
+	"syscall" // For opening the process
+)
+
+// processQueryLimitedInformation is the least access right a process handle can be opened with.
+const processQueryLimitedInformation = 0x1000
+
+// stillActive is the exit code GetExitCodeProcess reports for a running process.
+const stillActive = 259
+
+// processAlive reports whether a process with this PID runs on this host.
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil { // No such process
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return true // Exists, state unknown
+	}
+	return exitCode == stillActive
+}
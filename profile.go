@@ -0,0 +1,64 @@
+package main // Define the main package
+
+import (
+	"bufio"         // For reading profile files
+	"flag"          // For applying profile values
+	"os"            // For opening profile files
+	"path/filepath" // For building profile paths
+	"strings"       // For parsing lines
+)
+
+var (
+	profileName string // Named set of flags to run with
+	profileDir  string // Folder holding <name>.conf profiles
+)
+
+func init() {
+	flag.StringVar(&profileName, "profile", "", "load flags from <profile-dir>/<name>.conf; flags on the command line win, so several profiles can run side by side") // Register the profile flag
+	flag.StringVar(&profileDir, "profile-dir", "profiles", "folder holding profile files")                                                                            // Register the profile folder flag
+}
+
+// applyProfile sets every flag listed in the selected profile that was not
+// given on the command line. Profile files hold one "-name=value" (or
+// "name value") per line; blank lines and # comments are ignored.
+func applyProfile() {
+	if profileName == "" { // Profiles are opt-in
+		return
+	}
+	explicit := make(map[string]bool) // Flags given on the command line
+	flag.Visit(func(given *flag.Flag) { explicit[given.Name] = true })
+	path := filepath.Join(profileDir, profileName+".conf")
+	file, err := os.Open(path)
+	if err != nil {
+		fatalConfig("failed to open profile %s: %v", path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") { // Blank lines and comments
+			continue
+		}
+		text = strings.TrimLeft(text, "-") // Accept -name and --name
+		name, value, found := strings.Cut(text, "=")
+		if !found {
+			name, value, found = strings.Cut(text, " ")
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found { // Bare boolean flag
+			value = "true"
+		}
+		if name == "profile" || name == "profile-dir" {
+			fatalConfig("%s:%d: profiles cannot load other profiles", path, line)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			fatalConfig("%s:%d: %v", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatalConfig("failed to read profile %s: %v", path, err)
+	}
+}
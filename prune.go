@@ -0,0 +1,135 @@
+package main // Define the main package
+
+import (
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting unknown actions
+	"log"           // For logging messages and errors
+	"os"            // For moving and deleting files
+	"path/filepath" // For building archive paths
+	"slices"        // For checking the document type
+	"strings"       // For checking the character set
+	"time"          // For prune timestamps
+)
+
+const (
+	pruneActionArchive = "archived" // Moved to the archive folder
+	pruneActionDelete  = "deleted"  // Removed from disk
+)
+
+var (
+	prune       bool                 // Remove documents that are no longer listed upstream
+	pruneAction = pruneActionArchive // What happens to a pruned document
+	archiveDir  string               // Where pruned documents are moved
+)
+
+func init() {
+	flag.BoolVar(&prune, "prune", false, "mirror mode: after a complete search, archive or delete documents no longer listed upstream") // Register the prune flag
+	flag.Func("prune-action", "what --prune does with stale documents: archive (default) or delete", func(value string) error {
+		switch value { // Register the prune action flag
+		case "archive":
+			pruneAction = pruneActionArchive
+		case "delete":
+			pruneAction = pruneActionDelete
+		default:
+			return fmt.Errorf("unknown prune action %q (want archive or delete)", value)
+		}
+		return nil
+	})
+	flag.StringVar(&archiveDir, "archive-dir", "archive", "folder that --prune moves stale documents to") // Register the archive folder flag
+}
+
+// pruneStaleDocuments archives or deletes every stored document whose URL
+// no longer appears in the saved search results, and marks it in the
+// manifest. It only runs when every query has a complete result, because
+// an incomplete search would make documents look removed upstream when
+// they are not.
+func pruneStaleDocuments() {
+	if reason := incompleteSearches(); reason != "" {
+		log.Printf("not pruning: %s", reason)
+		return
+	}
+	upstream := make(map[string]bool) // Every URL still listed by the catalog
+	for _, link := range discoveredLinks() {
+		upstream[link] = true
+		if resolved, cached := lookupResolvedLink(link); cached && resolved != "" {
+			upstream[resolved] = true // Manifest entries record the resolved URL
+		}
+	}
+	if len(upstream) == 0 { // An empty catalog is almost certainly a broken search
+		log.Printf("not pruning: the search results list no documents")
+		return
+	}
+	pruned := 0 // Documents archived or deleted
+	for _, entry := range documentManifest.currentEntries() {
+		if upstream[entry.URL] || entry.Source == manualSource { // Uploads were never listed upstream
+			continue
+		}
+		if !slices.Contains(selectedDocTypes, entryDocType(entry).Name) { // Not searched by this run's --doc-types
+			continue
+		}
+		source := entry.localPath() // Where the stale document is stored
+		var err error
+		if pruneAction == pruneActionArchive {
+			target := filepath.Join(archiveDir, entry.File)
+			createDirectory(filepath.Dir(target), 0755) // Make sure the archive and the document type subfolder exist
+			err = os.Rename(source, target)
+		} else {
+			err = os.Remove(source)
+		}
+		if err != nil && !os.IsNotExist(err) { // A file that is already gone is still pruned
+			log.Printf("failed to prune %s: %v", source, err)
+			continue
+		}
+		prunedAt := time.Now().UTC()
+		entry.Pruned, entry.PrunedAt = pruneAction, &prunedAt
+		documentManifest.record(entry)
+		log.Printf("pruned %s (%s): no longer listed upstream", entry.File, pruneAction)
+		pruned++
+	}
+	log.Printf("pruning %s %d documents no longer listed upstream", pruneAction, pruned)
+}
+
+// narrowedQueries says why the queries cover only part of the catalog, or
+// returns "" for the full generated search. Everything the narrower search
+// does not find would look dropped upstream to --prune.
+func narrowedQueries() string {
+	if queriesFile != "" || len(customQueries) > 0 {
+		return "-query and -queries-file search only some terms"
+	}
+	missing := strings.Map(func(char rune) rune { // Default characters the combos no longer start from
+		if strings.ContainsRune(queryCharset, char) {
+			return -1
+		}
+		return char
+	}, defaultQueryCharset)
+	if missing != "" {
+		return fmt.Sprintf("-charset leaves out %q", missing)
+	}
+	return ""
+}
+
+// incompleteSearches says why the saved results are not the whole upstream
+// listing, or returns "" when every query has an up-to-date result that
+// was paged to the end. A result cut short by --max-search-pages, the
+// request budget or a -tui skip lacks the documents of the pages it never
+// fetched.
+func incompleteSearches() string {
+	pending, truncated := 0, 0 // Queries not searched, failed or outdated, and queries paged only part way
+	for _, query := range generateQueries() {
+		if uselessQuery(query) { // Left out of the queue, its empty result stands
+			continue
+		}
+		if resultsOutdated(query) {
+			pending++
+		} else if result, _ := loadSearchResult(query); result.Truncated {
+			truncated++
+		}
+	}
+	switch {
+	case pending > 0:
+		return fmt.Sprintf("%d queries have no up-to-date search results", pending)
+	case truncated > 0:
+		return fmt.Sprintf("%d queries stopped paging before the last result page (-max-search-pages, the request budget or a skip)", truncated)
+	}
+	return ""
+}
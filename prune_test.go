@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// storeDocument writes a PDF into the PDF folder and records it.
+func storeDocument(t *testing.T, link, file string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(outputDir, file), []byte("%PDF-1.7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	documentManifest.record(manifestEntry{URL: link, File: file})
+}
+
+func TestPruneStaleDocuments(t *testing.T) {
+	tests := []struct {
+		name      string
+		truncated bool // The saved result stopped paging early
+		wantPrune bool
+	}{
+		{"complete results prune", false, true},
+		{"truncated results do not", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTestOrigin(t, http.NotFoundHandler())
+			setGlobal(t, &customQueries, []string{"floor"})
+			setGlobal(t, &archiveDir, filepath.Join(t.TempDir(), "archive"))
+			listed, dropped := originURL+"/docs/listed.pdf", originURL+"/docs/dropped.pdf"
+			storeDocument(t, listed, "listed.pdf")
+			storeDocument(t, dropped, "dropped.pdf")
+			result := searchResult{Query: "floor", FetchedAt: time.Now().UTC(), Status: http.StatusOK, Links: []documentLink{{URL: listed}}, Truncated: test.truncated}
+			if err := saveSearchResult(result); err != nil {
+				t.Fatal(err)
+			}
+			pruneStaleDocuments()
+			entry, _ := documentManifest.lookup(dropped)
+			if pruned := entry.Pruned == pruneActionArchive; pruned != test.wantPrune {
+				t.Errorf("dropped document pruned = %t, want %t", pruned, test.wantPrune)
+			}
+			if entry, _ := documentManifest.lookup(listed); entry.Pruned != "" {
+				t.Errorf("listed document was pruned (%s)", entry.Pruned)
+			}
+		})
+	}
+}
+
+func TestPruneKeepsOtherDocTypes(t *testing.T) {
+	useTestOrigin(t, http.NotFoundHandler())
+	setGlobal(t, &customQueries, []string{"floor"})
+	setGlobal(t, &selectedDocTypes, []string{"tds"})
+	setGlobal(t, &archiveDir, filepath.Join(t.TempDir(), "archive"))
+	sheet := originURL + "/docs/sheet.pdf" // A safety data sheet, which -doc-types tds does not search
+	storeDocument(t, sheet, "sheet.pdf")
+	result := searchResult{Query: "tds:floor", FetchedAt: time.Now().UTC(), Status: http.StatusOK, Links: []documentLink{{URL: originURL + "/docs/tds.pdf"}}}
+	if err := os.MkdirAll(filepath.Dir(queryResultPath(result.Query)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSearchResult(result); err != nil {
+		t.Fatal(err)
+	}
+	pruneStaleDocuments()
+	if entry, _ := documentManifest.lookup(sheet); entry.Pruned != "" {
+		t.Errorf("safety data sheet was pruned (%s) by a tds-only search", entry.Pruned)
+	}
+}
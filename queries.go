@@ -0,0 +1,61 @@
+package main // Define the main package
+
+import (
+	"bufio"   // For reading the queries file line by line
+	"flag"    // For command-line flag parsing
+	"fmt"     // For rejecting invalid terms
+	"os"      // For opening the queries file
+	"strings" // For trimming query lines
+	"sync"    // For reading the queries file once
+	"unicode" // For rejecting control characters
+)
+
+// defaultQueryCharset is the --charset the generated combos cover by default.
+const defaultQueryCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+var (
+	queriesFile       string    // Operator-supplied search terms, one per line ("" = generated combos)
+	queryCharset      string    // Characters the generated combos are built from
+	customQueries     []string  // Terms read from queriesFile and given with --query
+	customQueriesOnce sync.Once // Reads queriesFile on first use
+)
+
+func init() {
+	flag.StringVar(&queriesFile, "queries-file", "", "search only the terms in this file (one per line, # comments), e.g. product names or SKUs, instead of generated combos") // Register the queries file flag
+	flag.StringVar(&queryCharset, "charset", defaultQueryCharset, "characters the generated one- and two-character search combos are built from")                              // Register the character set flag
+	flag.Func("query", "search this term instead of generated combos; repeat for more terms, e.g. -query \"floor finish\" -query 'H&S #4'", func(value string) error {
+		term := strings.TrimSpace(value) // Register the search term flag
+		if term == "" || strings.IndexFunc(term, unicode.IsControl) >= 0 {
+			return fmt.Errorf("invalid search term %q", value)
+		}
+		customQueries = append(customQueries, term)
+		return nil
+	})
+}
+
+// loadCustomQueries returns the --query terms and those of --queries-file,
+// skipping blank lines and # comments.
+func loadCustomQueries() []string {
+	customQueriesOnce.Do(func() {
+		if queriesFile == "" {
+			customQueries = removeDuplicatesFromSlice(customQueries)
+			return
+		}
+		file, err := os.Open(queriesFile) // Read the operator's terms
+		if err != nil {
+			fatalConfig("failed to read queries file %s: %v", queriesFile, err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if term := strings.TrimSpace(scanner.Text()); term != "" && !strings.HasPrefix(term, "#") {
+				customQueries = append(customQueries, term)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fatalConfig("failed to read queries file %s: %v", queriesFile, err)
+		}
+		customQueries = removeDuplicatesFromSlice(customQueries)
+	})
+	return customQueries
+}
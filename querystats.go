@@ -0,0 +1,116 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For atomic writes
+	"encoding/json" // For the stats file
+	"flag"          // For command-line flag parsing
+	"log"           // For logging per-query results
+	"net/http"      // For the status of empty results
+	"os"            // For reading the stats file
+	"path/filepath" // For the stats path
+	"sync"          // For guarding the stats across search workers
+	"time"          // For search times
+)
+
+// queryStats is what the searches of one query returned over time.
+type queryStats struct {
+	Results     int       `json:"results"`      // Document links of the last successful search
+	RawSize     int       `json:"raw_size"`     // Bytes of result pages it received
+	SearchedAt  time.Time `json:"searched_at"`  // When it ran
+	EmptyStreak int       `json:"empty_streak"` // Successful searches in a row that returned nothing
+}
+
+var (
+	skipEmptyAfter  int                    // Stop searching queries empty this many times in a row (0 = never)
+	queryStatsState map[string]*queryStats // Query target → stats, loaded on first use
+	queryStatsMutex sync.Mutex             // Guards queryStatsState
+)
+
+func init() {
+	flag.IntVar(&skipEmptyAfter, "skip-empty-after", 0, "stop searching queries that returned no results this many times in a row, e.g. 3 (0 = keep searching them)") // Register the empty query flag
+}
+
+// Path of the per-query search statistics
+func queryStatsPath() string {
+	return filepath.Join(givenFolder, "query-stats.json") // Next to the results it summarizes
+}
+
+// Load the stats if needed; callers hold queryStatsMutex
+func loadQueryStatsLocked() {
+	if queryStatsState != nil {
+		return
+	}
+	queryStatsState = make(map[string]*queryStats)
+	if content, err := os.ReadFile(queryStatsPath()); err == nil {
+		json.Unmarshal(content, &queryStatsState) // Corrupt stats just mean searching empty queries again
+	}
+}
+
+// lookupQueryStats returns the stats of a query, if it was ever searched.
+func lookupQueryStats(target string) (queryStats, bool) {
+	queryStatsMutex.Lock()
+	defer queryStatsMutex.Unlock()
+	loadQueryStatsLocked()
+	stats, found := queryStatsState[target]
+	if !found {
+		return queryStats{}, false
+	}
+	return *stats, true
+}
+
+// recordQueryStats logs how many results a successful search returned and
+// remembers it, so empty queries need no result file.
+func recordQueryStats(result searchResult) {
+	log.Printf("query %s: %d results, %d bytes", result.Query, len(result.Links), result.RawSize)
+	queryStatsMutex.Lock()
+	defer queryStatsMutex.Unlock()
+	loadQueryStatsLocked()
+	stats := queryStatsState[result.Query]
+	if stats == nil {
+		stats = &queryStats{}
+		queryStatsState[result.Query] = stats
+	}
+	stats.Results, stats.RawSize, stats.SearchedAt = len(result.Links), result.RawSize, result.FetchedAt
+	if len(result.Links) == 0 {
+		stats.EmptyStreak++
+	} else {
+		stats.EmptyStreak = 0
+	}
+}
+
+// emptyQueryResult stands in for the result file an empty search no longer
+// writes: a successful search without links, dated by the stats.
+func emptyQueryResult(target string) (searchResult, bool) {
+	stats, found := lookupQueryStats(target)
+	if !found || stats.Results > 0 || stats.SearchedAt.IsZero() {
+		return searchResult{}, false
+	}
+	return searchResult{Query: target, FetchedAt: stats.SearchedAt, Status: http.StatusOK, RawSize: stats.RawSize}, true
+}
+
+// uselessQuery reports whether a query returned nothing often enough in a
+// row to be left out with --skip-empty-after.
+func uselessQuery(target string) bool {
+	if skipEmptyAfter <= 0 {
+		return false
+	}
+	stats, found := lookupQueryStats(target)
+	return found && stats.EmptyStreak >= skipEmptyAfter
+}
+
+// saveQueryStats persists the stats for the next run.
+func saveQueryStats() {
+	queryStatsMutex.Lock()
+	defer queryStatsMutex.Unlock()
+	if queryStatsState == nil { // Nothing was searched or checked this run
+		return
+	}
+	content, err := json.MarshalIndent(queryStatsState, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := writeFileAtomically(queryStatsPath(), bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,156 @@
+package main // Define the main package
+
+import (
+	"encoding/json"  // For exporting and loading the queue
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the queue table
+	"log"            // For logging messages and errors
+	"os"             // For file and stdout access
+	"sort"           // For ordering items by priority
+	"text/tabwriter" // For aligned console output
+	"time"           // For the age of saved results
+)
+
+const (
+	queueKindQuery    = "query"    // A search query that has not been fetched yet
+	queueKindDownload = "download" // A discovered PDF URL that has not been downloaded yet
+	queueStatePending = "pending"  // Work that the next run will perform
+)
+
+var (
+	queueFile    string        // Optional hand-edited queue file that replaces the generated queue
+	maxResultAge time.Duration // Saved search results older than this are searched again (0 = never)
+)
+
+func init() {
+	flag.StringVar(&queueFile, "queue-file", "", "run the pending items of an exported queue file instead of the generated queue")            // Register the queue file flag
+	flag.DurationVar(&maxResultAge, "max-age", 0, "search queries again once their saved results are older than this, e.g. 720h (0 = never)") // Register the result age flag
+}
+
+// queueItem is a single unit of pending work.
+type queueItem struct {
+	Kind      string `json:"kind"`                 // queueKindQuery or queueKindDownload
+	Target    string `json:"target"`               // Search query or PDF URL
+	Priority  int    `json:"priority"`             // Lower priorities run first
+	State     string `json:"state"`                // Only queueStatePending items are run
+	Attempts  int    `json:"attempts,omitempty"`   // Earlier runs in which it failed
+	LastError string `json:"last_error,omitempty"` // Why it failed last time
+}
+
+// buildQueue lists the work a run would perform right now: every query
+// without a saved result file, then every discovered link without a local
+// PDF, with the items that failed in earlier runs first and the most
+// recently revised documents ahead of the rest.
+func buildQueue() []queueItem {
+	var queue []queueItem // Items in execution order
+	useless := 0          // Queries left out by --skip-empty-after
+	for priority, query := range generateQueries() {
+		if uselessQuery(query) {
+			useless++
+			continue
+		}
+		if resultsOutdated(query) { // Not searched yet, failed, or too long ago
+			queue = append(queue, queueItem{Kind: queueKindQuery, Target: query, Priority: priority, State: queueStatePending})
+		}
+	}
+	if useless > 0 {
+		log.Printf("leaving out %d queries that returned nothing %d times in a row (-skip-empty-after)", useless, skipEmptyAfter)
+	}
+	priority := 0 // Downloads are prioritized newest revision first, then in discovery order
+	for _, link := range newestFirst(discoveredLinks()) {
+		localPath := localPDFPath(link)
+		_, due := storedEntryDue(link)
+		if localPath == "" || !fileExists(localPath) || due { // Not downloaded yet, or time to ask whether it changed
+			queue = append(queue, queueItem{Kind: queueKindDownload, Target: link, Priority: priority, State: queueStatePending})
+			priority++
+		}
+	}
+	return prioritizeRetries(queue)
+}
+
+// resultsOutdated reports whether a query's saved results are missing, from
+// a failed or interrupted search, or older than --max-age.
+func resultsOutdated(target string) bool {
+	result, found := loadSearchResult(target)
+	if !found || !result.searched() || result.interrupted() { // Never searched, failed, or stopped before the last page
+		return true
+	}
+	return maxResultAge > 0 && time.Since(result.FetchedAt) > maxResultAge
+}
+
+// discoveredLinks returns the unique PDF links found in all saved search results.
+func discoveredLinks() []string {
+	var links []string // Links in query order
+	for _, query := range generateQueries() {
+		links = append(links, savedResultLinks(query)...) // Collect the links of the saved results
+	}
+	return removeDuplicatesFromSlice(links)
+}
+
+// pendingTargets returns the targets of pending items of the given kind, by priority.
+func pendingTargets(queue []queueItem, kind string) []string {
+	var items []queueItem // Matching items
+	for _, item := range queue {
+		if item.Kind == kind && item.State == queueStatePending {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Priority < items[j].Priority }) // Lowest priority value first
+	var targets []string
+	for _, item := range items {
+		targets = append(targets, item.Target)
+	}
+	return targets
+}
+
+// loadQueue reads a queue previously written by `queue export`.
+func loadQueue(path string) []queueItem {
+	var queue []queueItem             // Decoded items
+	content, err := os.ReadFile(path) // Read the queue file
+	if err != nil {
+		fatalConfig("failed to read queue file %s: %v", path, err) // A missing queue file is a configuration error
+	}
+	if err := json.Unmarshal(content, &queue); err != nil {
+		fatalConfig("invalid queue file %s: %v", path, err)
+	}
+	return queue
+}
+
+// runQueueCommand implements `queue show` and `queue export [file]`.
+func runQueueCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: queue show | queue export [file]")
+	}
+	queue := buildQueue() // Snapshot of the pending work
+	if queueFile != "" {  // Inspect a hand-edited queue instead
+		queue = loadQueue(queueFile)
+	}
+	switch args[0] {
+	case "show":
+		if jsonOutput() {
+			printJSON(queue)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "KIND\tPRIORITY\tSTATE\tATTEMPTS\tTARGET")
+		for _, item := range queue {
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%d\t%s\n", item.Kind, item.Priority, item.State, item.Attempts, item.Target)
+		}
+		writer.Flush() // Print the table
+	case "export":
+		content, err := json.MarshalIndent(queue, "", "  ") // Human-editable JSON
+		if err != nil {
+			fatalln(err)
+		}
+		if len(args) < 2 { // No file given, write to stdout
+			fmt.Println(string(content))
+			return
+		}
+		if err := os.WriteFile(args[1], append(content, '\n'), 0644); err != nil {
+			fatalln(err)
+		}
+		log.Printf("exported %d queue items to %s", len(queue), args[1])
+	default:
+		fatalf("unknown queue command %q", args[0])
+	}
+}
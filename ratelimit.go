@@ -0,0 +1,154 @@
+package main // Define the main package
+
+import (
+	"context"     // For per-attempt timeouts and cancelled waits
+	"flag"        // For command-line flag parsing
+	"io"          // For wrapping response bodies
+	"log"         // For logging pauses
+	"net/http"    // For the transport wrapper
+	"strconv"     // For Retry-After in seconds
+	"sync"        // For guarding the pause table
+	"sync/atomic" // For counting pauses
+	"time"        // For pauses
+)
+
+// defaultRetryDelay is the first pause after a 429/503 without Retry-After;
+// later attempts double it.
+const defaultRetryDelay = 30 * time.Second
+
+var (
+	rateLimitRetries int                          // How often a rate-limited request is retried before it fails
+	maxRetryAfter    time.Duration                // Longest pause honoured from a single Retry-After
+	rateLimitPauses  atomic.Int64                 // Number of times the origin asked us to back off
+	hostPauses       = make(map[string]time.Time) // Host → time requests may resume
+	hostPausesMutex  sync.Mutex                   // Guards hostPauses
+)
+
+func init() {
+	flag.IntVar(&rateLimitRetries, "rate-limit-retries", 5, "times a request answered with 429 or 503 is retried after the server's Retry-After before it counts as failed") // Register the retry flag
+	flag.DurationVar(&maxRetryAfter, "max-retry-after", 10*time.Minute, "longest pause honoured from a single Retry-After header")                                           // Register the pause cap flag
+}
+
+// rateLimitTransport retries requests answered with 429 Too Many Requests or
+// 503 Service Unavailable. The pause asked for in Retry-After applies to the
+// whole host, so every worker of the pipeline waits and resumes together
+// instead of each one hammering the origin and dropping its document.
+type rateLimitTransport struct {
+	next    http.RoundTripper // Transport doing the actual requests
+	timeout time.Duration     // Timeout of one attempt including its body, unless its phase sets one (0 = none)
+}
+
+// RoundTrip sends the request, waiting out and retrying rate-limit answers.
+func (transport rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := waitForHost(request.Context(), request.URL.Host); err != nil {
+			return nil, err
+		}
+		if err := waitForOriginHealth(request.Context(), request.URL.Host); err != nil { // Earlier runs were rate limited
+			return nil, err
+		}
+		if err := acquireSharedSlot(request.Context(), request.URL.Host); err != nil { // Budget shared with other processes
+			return nil, err
+		}
+		var ctx context.Context
+		var cancel context.CancelFunc                                           // Released when the body is closed
+		if timeout := requestTimeout(request, transport.timeout); timeout > 0 { // Like http.Client.Timeout, but per attempt
+			ctx, cancel = context.WithTimeout(request.Context(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(request.Context())
+		}
+		attemptRequest := request.Clone(ctx)
+		if attempt > 0 && request.GetBody != nil { // The first attempt consumed the body
+			body, err := request.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attemptRequest.Body = body
+		}
+		traffic := trafficFor(request.URL.Host) // Per-host transfer for the run report
+		traffic.requests.Add(1)
+		resp, err := transport.next.RoundTrip(attemptRequest)
+		if err != nil {
+			traffic.errors.Add(1)
+			cancel()
+			return nil, err
+		}
+		replayable := request.Body == nil || request.GetBody != nil // Bodies can only be sent again if they can be rebuilt
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && attempt < rateLimitRetries && replayable {
+			traffic.rateLimited.Add(1)
+			delay := retryDelay(resp.Header.Get("Retry-After"), attempt)
+			resp.Body.Close()
+			cancel()
+			pauseHost(request.URL.Host, delay, resp.Status)
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable { // Out of retries
+			traffic.rateLimited.Add(1)
+		}
+		resp.Body = cancelOnClose{ReadCloser: countingBody{ReadCloser: resp.Body, counter: traffic}, cancel: cancel}
+		return resp, nil
+	}
+}
+
+// retryDelay reads Retry-After as seconds or an HTTP date, falling back to
+// an exponential backoff, and caps it at --max-retry-after.
+func retryDelay(retryAfter string, attempt int) time.Duration {
+	delay := defaultRetryDelay << attempt // Backoff when the server gives no hint
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(retryAfter); err == nil {
+		delay = time.Until(date)
+	}
+	return min(max(delay, time.Second), maxRetryAfter)
+}
+
+// pauseHost stops new requests to host for delay, extending an existing
+// pause but never shortening it.
+func pauseHost(host string, delay time.Duration, status string) {
+	rateLimitPauses.Add(1) // Count it for the run report
+	until := time.Now().Add(delay)
+	hostPausesMutex.Lock()
+	extended := until.After(hostPauses[host]) // Only log and share a longer pause
+	if extended {
+		hostPauses[host] = until
+	}
+	hostPausesMutex.Unlock()
+	recordOriginLimited(host, until) // Later runs start slowly
+	if extended {
+		sharePause(host, until) // Other processes back off too
+		log.Printf("%s answered %s; pausing requests to it for %s", host, status, delay.Round(time.Second))
+	}
+}
+
+// waitForHost blocks while host is paused.
+func waitForHost(ctx context.Context, host string) error {
+	hostPausesMutex.Lock()
+	until := hostPauses[host]
+	hostPausesMutex.Unlock()
+	wait := time.Until(until)
+	if wait <= 0 { // Not paused
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelOnClose releases an attempt's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser                    // Response body
+	cancel        context.CancelFunc // Releases the attempt's timeout
+}
+
+// Close closes the body and releases the context.
+func (body cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
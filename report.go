@@ -0,0 +1,155 @@
+package main // Define the main package
+
+import (
+	"log"         // For logging the exit status
+	"os"          // For the exit code
+	"sync"        // For guarding the change lists
+	"sync/atomic" // For counters shared between workers
+	"time"        // For run timing
+)
+
+var (
+	runStartedAt         = time.Now()     // When the process started
+	queriesSearched      atomic.Int64     // Search queries fetched this run
+	linksDiscovered      atomic.Int64     // Unique PDF links considered for download
+	documentsSaved       atomic.Int64     // PDFs written to disk this run
+	documentsSkipped     atomic.Int64     // PDFs skipped because they already exist locally
+	documentBytesSaved   atomic.Int64     // Bytes of PDFs written to disk this run
+	newDocuments         []documentChange // Documents stored for the first time this run
+	updatedDocuments     []documentChange // Documents whose content changed this run
+	documentChangesMutex sync.Mutex       // Guards newDocuments and updatedDocuments
+)
+
+// documentChange names a document that is new or changed in a run.
+type documentChange struct {
+	Title string `json:"title,omitempty"` // Product name
+	File  string `json:"file"`            // Filename inside the PDF folder
+	URL   string `json:"url"`             // Source URL
+}
+
+// recordDocumentChange files a stored document as new or updated. Storing
+// the same content again counts as neither.
+func recordDocumentChange(entry, previous manifestEntry, replaced bool) {
+	change := documentChange{Title: entry.Title, File: entry.File, URL: entry.URL}
+	documentChangesMutex.Lock()
+	defer documentChangesMutex.Unlock()
+	switch {
+	case !replaced:
+		newDocuments = append(newDocuments, change)
+	case previous.SHA256 != entry.SHA256:
+		updatedDocuments = append(updatedDocuments, change)
+	}
+}
+
+// Kinds of report sent to the notification channels
+const (
+	reportKindRun    = "run"    // End of a crawl
+	reportKindDigest = "digest" // Periodic integrity digest in daemon mode
+)
+
+// runReport summarizes a finished run for notifications and reports.
+type runReport struct {
+	Kind              string              `json:"kind"`                         // reportKindRun or reportKindDigest
+	StartedAt         time.Time           `json:"started_at"`                   // When the run started
+	FinishedAt        time.Time           `json:"finished_at"`                  // When the run finished
+	Duration          time.Duration       `json:"duration"`                     // Wall time of the run
+	QueriesSearched   int64               `json:"queries_searched"`             // Search queries fetched
+	LinksDiscovered   int64               `json:"links_discovered"`             // Unique PDF links considered
+	Downloaded        int64               `json:"downloaded"`                   // PDFs written to disk
+	Skipped           int64               `json:"skipped"`                      // PDFs already present
+	BytesDownloaded   int64               `json:"bytes_downloaded"`             // Bytes of PDFs written
+	DeferredRequests  int64               `json:"deferred_requests"`            // Requests left for the next run by the budget
+	RateLimitPauses   int64               `json:"rate_limit_pauses"`            // Times the origin answered 429/503 and the run paused
+	NewDocuments      []documentChange    `json:"new_documents"`                // Documents stored for the first time
+	UpdatedDocuments  []documentChange    `json:"updated_documents"`            // Documents whose content changed
+	Failures          []failure           `json:"failures"`                     // Every recorded failure
+	IntegrityFindings []integrityFinding  `json:"integrity_findings,omitempty"` // Problems found by the integrity sweep (digests only)
+	Drift             *driftReport        `json:"drift,omitempty"`              // Differences from the approved manifest, when one is pinned
+	Traffic           []hostTrafficReport `json:"traffic,omitempty"`            // Requests and bytes per upstream host
+}
+
+// buildRunReport snapshots the run counters into a report.
+func buildRunReport() runReport {
+	finishedAt := time.Now() // The report marks the end of the run
+	failuresMutex.Lock()
+	recorded := append([]failure{}, failures...) // Copy so the report is stable
+	failuresMutex.Unlock()
+	documentChangesMutex.Lock()
+	added := append([]documentChange{}, newDocuments...) // Copies, like the failures
+	updated := append([]documentChange{}, updatedDocuments...)
+	documentChangesMutex.Unlock()
+	return runReport{
+		Kind:             reportKindRun,
+		StartedAt:        runStartedAt.UTC(),
+		FinishedAt:       finishedAt.UTC(),
+		Duration:         finishedAt.Sub(runStartedAt).Round(time.Second),
+		QueriesSearched:  queriesSearched.Load(),
+		LinksDiscovered:  linksDiscovered.Load(),
+		Downloaded:       documentsSaved.Load(),
+		Skipped:          documentsSkipped.Load(),
+		BytesDownloaded:  documentBytesSaved.Load(),
+		DeferredRequests: deferredRequests.Load(),
+		RateLimitPauses:  rateLimitPauses.Load(),
+		NewDocuments:     added,
+		UpdatedDocuments: updated,
+		Failures:         recorded,
+		Drift:            currentDrift(),
+		Traffic:          trafficByHost(),
+	}
+}
+
+// Exit codes of the crawling commands (run, discover, download, mirror)
+const (
+	exitSuccess           = 0 // Every search and download succeeded
+	exitPartialFailure    = 1 // Some searches or downloads failed, see failures.json
+	exitConfigError       = 2 // Bad command, flag or profile; nothing was attempted
+	exitNothingDiscovered = 3 // The saved search results list no documents at all
+	exitLocked            = 4 // Another instance holds the lock of the data folders
+)
+
+// exitCode tells schedulers how the run went. An up-to-date library with
+// nothing left to do still succeeds; only results without a single
+// document link count as nothing discovered, as that means a broken search.
+func (report runReport) exitCode() int {
+	switch {
+	case len(report.Failures) > 0:
+		return exitPartialFailure
+	case report.LinksDiscovered == 0 && len(discoveredLinks()) == 0:
+		return exitNothingDiscovered
+	default:
+		return exitSuccess
+	}
+}
+
+// exitWithOutcome ends the process with the exit code of the run.
+func exitWithOutcome(report runReport) {
+	if code := report.exitCode(); code != exitSuccess {
+		log.Printf("exiting with status %d", code)
+		exit(code)
+	}
+}
+
+// fatalConfig reports a configuration error and exits before any work.
+func fatalConfig(format string, args ...any) {
+	log.Printf(format, args...)
+	exit(exitConfigError)
+}
+
+// exit ends the process with a status code. Every exit after the data lock
+// is taken goes through here, as os.Exit skips the release in main.
+func exit(code int) {
+	releaseDataLock()
+	os.Exit(code)
+}
+
+// fatalf logs like log.Fatalf and exits with status 1, releasing the lock.
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	exit(1)
+}
+
+// fatalln logs like log.Fatalln and exits with status 1, releasing the lock.
+func fatalln(args ...any) {
+	log.Println(args...)
+	exit(1)
+}
@@ -0,0 +1,140 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For writing the cache files atomically
+	"crypto/sha1"   // For short, stable filename suffixes
+	"encoding/hex"  // For encoding the suffix
+	"encoding/json" // For persisting resolved links
+	"log"           // For logging messages and errors
+	"mime"          // For parsing Content-Disposition
+	"net/http"      // For HEAD/GET requests
+	"os"            // For reading the cache files
+	"path"          // For dropping folders from header filenames
+	"path/filepath" // For building the cache path
+	"strings"       // For string manipulation
+	"sync"          // For guarding the cache across workers
+)
+
+var (
+	resolvedLinks      map[string]string // Handler URL → final PDF URL ("" when it is not a PDF)
+	dispositionNames   map[string]string // Final PDF URL → filename the server gave in Content-Disposition
+	resolvedLinksMutex sync.Mutex        // Guards resolvedLinks and dispositionNames
+)
+
+// Path of the cache of resolved handler links
+func resolvedLinksPath() string {
+	return filepath.Join(givenFolder, "resolved-links.json") // Lives next to the search results
+}
+
+// Path of the cache of Content-Disposition filenames
+func dispositionNamesPath() string {
+	return filepath.Join(givenFolder, "disposition-names.json") // Kept with the resolved links
+}
+
+// hasPDFExtension reports whether a URL path ends in .pdf.
+func hasPDFExtension(rawURL string) bool {
+	path := strings.ToLower(strings.SplitN(rawURL, "?", 2)[0]) // Ignore the query string
+	return strings.HasSuffix(path, ".pdf")
+}
+
+// resolveDocumentLink follows a handler link with HEAD (falling back to GET)
+// and returns the final URL when it serves a PDF, or "" when it does not.
+// Results are cached across runs so each handler is only probed once.
+func resolveDocumentLink(link string) string {
+	if resolved, cached := lookupResolvedLink(link); cached { // Probed by an earlier run
+		return resolved
+	}
+	if !reserveRequest() { // Leave the probe for the next run once the budget is spent
+		return ""
+	}
+	resp, err := fetchURL(http.MethodHead, link) // Follow redirects without fetching the body
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.Header.Get("Content-Type") == "") {
+		resp.Body.Close()      // Some handlers only answer GET
+		if !reserveRequest() { // The GET is a second request against the budget
+			return ""
+		}
+		resp, err = fetchURL(http.MethodGet, link) // Headers are enough, the body is discarded
+	}
+	if err != nil {
+		recordFailure(failureKindDownload, link, reasonRequestError, err)
+		return "" // Do not cache transient errors
+	}
+	resp.Body.Close() // Only the headers are needed
+	if resp.StatusCode != http.StatusOK {
+		recordFailure(failureKindDownload, link, reasonHTTPStatus, resp.Status)
+		return "" // Do not cache transient errors
+	}
+	finalURL := canonicalURL(resp.Request.URL.String()) // URL after all redirects
+	_, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	isPDF := strings.Contains(resp.Header.Get("Content-Type"), "application/pdf") ||
+		strings.HasSuffix(strings.ToLower(params["filename"]), ".pdf") || hasPDFExtension(finalURL)
+	if !isPDF { // Handler led somewhere else
+		finalURL = ""
+		log.Printf("link does not resolve to a PDF, ignoring: %s", link)
+	}
+	resolvedLinksMutex.Lock()
+	resolvedLinks[link] = finalURL // Remember the answer for later runs
+	if finalURL != "" && params["filename"] != "" {
+		dispositionNames[finalURL] = params["filename"] // The real document name behind an opaque URL
+	}
+	resolvedLinksMutex.Unlock()
+	return finalURL
+}
+
+// lookupResolvedLink returns the cached resolution of a handler link.
+func lookupResolvedLink(link string) (string, bool) {
+	resolvedLinksMutex.Lock()
+	defer resolvedLinksMutex.Unlock()
+	loadResolvedLinksLocked()               // Load the cache on first use
+	resolved, cached := resolvedLinks[link] // Check for an earlier probe
+	return resolved, cached
+}
+
+// Load the resolved link cache if needed; the caller holds resolvedLinksMutex
+func loadResolvedLinksLocked() {
+	if resolvedLinks != nil { // Already loaded
+		return
+	}
+	resolvedLinks = make(map[string]string)
+	if content, err := os.ReadFile(resolvedLinksPath()); err == nil {
+		json.Unmarshal(content, &resolvedLinks) // A corrupt cache just means probing again
+	}
+	dispositionNames = make(map[string]string)
+	if content, err := os.ReadFile(dispositionNamesPath()); err == nil {
+		json.Unmarshal(content, &dispositionNames) // Lost names fall back to the URL
+	}
+}
+
+// dispositionFilename returns the sanitized Content-Disposition filename
+// seen for a resolved PDF URL, or "" when the server sent none.
+func dispositionFilename(pdfURL string) string {
+	resolvedLinksMutex.Lock()
+	defer resolvedLinksMutex.Unlock()
+	loadResolvedLinksLocked()
+	return internationalFilename(path.Base(dispositionNames[pdfURL])) // Only the name, never a path
+}
+
+// Persist the resolved handler links for the next run
+func saveResolvedLinks() {
+	resolvedLinksMutex.Lock()
+	defer resolvedLinksMutex.Unlock()
+	if resolvedLinks == nil { // Nothing was resolved this run
+		return
+	}
+	for cachePath, cache := range map[string]map[string]string{resolvedLinksPath(): resolvedLinks, dispositionNamesPath(): dispositionNames} {
+		content, err := json.MarshalIndent(cache, "", "  ") // Encode the cache
+		if err != nil {
+			log.Println(err) // Log error
+			continue
+		}
+		if _, err := writeFileAtomically(cachePath, bytes.NewReader(content), int64(len(content))); err != nil {
+			log.Println(err) // Log error
+		}
+	}
+}
+
+// Build a local filename for a PDF URL whose path does not end in .pdf
+func opaqueURLFilename(rawURL string) string {
+	sum := sha1.Sum([]byte(rawURL))                                               // Distinguishes handler URLs that share a path
+	return urlToSafeFilename(rawURL) + "-" + hex.EncodeToString(sum[:4]) + ".pdf" // e.g. getdocument-1a2b3c4d.pdf
+}
@@ -0,0 +1,105 @@
+package main // Define the main package
+
+import (
+	"encoding/json" // For the result files
+	"log"           // For logging the migration
+	"net/http"      // For status codes
+	"os"            // For reading result files
+	"strings"       // For writing result files
+	"time"          // For fetch times
+)
+
+// searchResult is the saved outcome of one search query, stored as
+// assets/<query>.json.
+type searchResult struct {
+	Query     string         `json:"query"`               // Query target, e.g. "ab" or "tds:ab"
+	FetchedAt time.Time      `json:"fetched_at"`          // When the search ran
+	Status    int            `json:"status"`              // HTTP status of the last page fetched, 0 when none answered
+	Links     []documentLink `json:"links"`               // Document links found on every page, in page order
+	RawSize   int            `json:"raw_size"`            // Bytes of result pages received
+	Pages     int            `json:"pages,omitempty"`     // Result pages fetched
+	Truncated bool           `json:"truncated,omitempty"` // Pagination stopped at --max-search-pages, the request budget or a skip
+}
+
+// searched reports whether the search succeeded. Its links are complete
+// unless it was truncated.
+func (result searchResult) searched() bool {
+	return result.Status == http.StatusOK
+}
+
+// interrupted reports whether paging stopped before the last page for a
+// reason other than --max-search-pages, such as the request budget or a
+// -tui skip, so the next run searches the query again. Truncated results
+// saved before the page count was recorded count as interrupted.
+func (result searchResult) interrupted() bool {
+	return result.Truncated && result.Pages < max(maxSearchPages, 1)
+}
+
+// loadSearchResult reads the saved result of a query. Files written before
+// results were structured hold the raw result pages; they are parsed on the
+// fly, dated by their modification time. Searches that returned nothing
+// have no file; their result comes from the query stats.
+func loadSearchResult(target string) (searchResult, bool) {
+	filePath := queryResultPath(target)
+	content, err := os.ReadFile(filePath)
+	if err != nil { // Not searched yet, or empty
+		return emptyQueryResult(target)
+	}
+	if result, structured := structuredResult(content); structured {
+		for index := range result.Links { // Saved before links were canonicalized
+			result.Links[index].URL = canonicalURL(result.Links[index].URL)
+		}
+		return result, true
+	}
+	result := searchResult{Query: target, Status: http.StatusOK, RawSize: len(content)} // A raw dump of a successful search
+	if info, err := os.Stat(filePath); err == nil {
+		result.FetchedAt = info.ModTime().UTC()
+	}
+	docType, _ := splitQueryTarget(target)
+	result.Links = currentSite().Parse(string(content), docType.searchURL())
+	return result, true
+}
+
+// structuredResult decodes a result file, reporting false for raw result
+// pages, which may be JSON themselves when a search API answered.
+func structuredResult(content []byte) (searchResult, bool) {
+	var result searchResult
+	err := json.Unmarshal(content, &result)
+	return result, err == nil && result.Query != "" && !result.FetchedAt.IsZero()
+}
+
+// saveSearchResult writes the result of a query.
+func saveSearchResult(result searchResult) error {
+	if result.Links == nil { // Always write a list, even when it is empty
+		result.Links = []documentLink{}
+	}
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	_, err = writeFileAtomically(queryResultPath(result.Query), strings.NewReader(string(content)), int64(len(content)))
+	return err
+}
+
+// migrateSearchResults rewrites the raw result pages saved by earlier
+// versions as structured results, so other tools can read them.
+func migrateSearchResults() {
+	migrated := 0
+	for _, query := range generateQueries() {
+		content, err := os.ReadFile(queryResultPath(query))
+		if err != nil {
+			continue
+		}
+		if _, structured := structuredResult(content); structured { // Already migrated
+			continue
+		}
+		result, _ := loadSearchResult(query)
+		if err := saveSearchResult(result); err != nil {
+			log.Printf("failed to migrate results for %s: %v", query, err)
+			continue
+		}
+		migrated++
+	}
+	log.Printf("migrated %d raw search results to structured results", migrated)
+}
@@ -0,0 +1,128 @@
+package main // Define the main package
+
+import (
+	"encoding/json" // For persisting the retry queue
+	"flag"          // For command-line flag parsing
+	"log"           // For logging messages and errors
+	"os"            // For reading and writing the queue file
+	"path/filepath" // For building paths
+	"time"          // For attempt timestamps
+)
+
+const queueStateGivenUp = "given_up" // Failed --retry-limit times; no longer run
+
+var retryLimit int // Attempts after which a failed item is no longer retried (0 = never give up)
+
+func init() {
+	flag.IntVar(&retryLimit, "retry-limit", 0, "stop retrying a failed search or download after this many failed runs (0 = keep retrying)") // Register the retry limit flag
+}
+
+// retryItem is a search or download that failed in an earlier run.
+type retryItem struct {
+	Kind        string    `json:"kind"`         // failureKindSearch or failureKindDownload
+	Target      string    `json:"target"`       // Query or URL that failed
+	Attempts    int       `json:"attempts"`     // Runs in which it failed
+	LastReason  string    `json:"last_reason"`  // Reason code of the latest failure
+	LastError   string    `json:"last_error"`   // Error message of the latest failure
+	LastAttempt time.Time `json:"last_attempt"` // When it last failed
+}
+
+// Path of the persistent retry queue
+func retryQueuePath() string {
+	return filepath.Join(givenFolder, "retry-queue.json") // Lives next to the search results
+}
+
+// loadRetryQueue reads the items that failed in earlier runs, oldest failure
+// first. A missing or corrupt file means nothing to retry.
+func loadRetryQueue() []retryItem {
+	var queue []retryItem
+	if content, err := os.ReadFile(retryQueuePath()); err == nil {
+		json.Unmarshal(content, &queue)
+	}
+	return queue
+}
+
+// retryCompleted reports whether a failed item has since succeeded.
+func retryCompleted(item retryItem) bool {
+	if item.Kind == failureKindSearch {
+		return !resultsOutdated(item.Target)
+	}
+	localPath := localPDFPath(item.Target)
+	return alreadySeen(item.Target) || localPath != "" && fileExists(localPath)
+}
+
+// updateRetryQueue adds this run's failures to the retry queue, counting a
+// target once per run, and drops the items that have succeeded since.
+func updateRetryQueue() {
+	queue := loadRetryQueue()
+	positions := make(map[[2]string]int, len(queue)) // Kind and target → index in queue
+	for index, item := range queue {
+		positions[[2]string{item.Kind, item.Target}] = index
+	}
+	counted := make(map[[2]string]bool) // Targets already counted this run
+	failuresMutex.Lock()
+	for _, entry := range failures {
+		key := [2]string{entry.Kind, entry.Target}
+		index, known := positions[key]
+		if !known {
+			queue = append(queue, retryItem{Kind: entry.Kind, Target: entry.Target})
+			index = len(queue) - 1
+			positions[key] = index
+		}
+		if !counted[key] {
+			counted[key] = true
+			queue[index].Attempts++
+		}
+		queue[index].LastReason, queue[index].LastError, queue[index].LastAttempt = entry.Reason, entry.Detail, entry.Time
+	}
+	failuresMutex.Unlock()
+	remaining := []retryItem{} // Always write a list, even when it is empty
+	for _, item := range queue {
+		if counted[[2]string{item.Kind, item.Target}] || !retryCompleted(item) {
+			remaining = append(remaining, item)
+		}
+	}
+	if retried := len(queue) - len(remaining); retried > 0 {
+		log.Printf("%d previously failed items succeeded", retried)
+	}
+	content, err := json.MarshalIndent(remaining, "", "  ") // Encode the queue
+	if err != nil {
+		log.Println(err) // Log error
+		return
+	}
+	if err := os.WriteFile(retryQueuePath(), append(content, '\n'), 0644); err != nil {
+		log.Println(err) // Log error
+	}
+}
+
+// prioritizeRetries moves the items that failed in earlier runs to the front
+// of the queue, adding failed downloads whose link no search lists anymore,
+// and marks the items that failed --retry-limit times as given up.
+func prioritizeRetries(queue []queueItem) []queueItem {
+	retries := loadRetryQueue()
+	if len(retries) == 0 {
+		return queue
+	}
+	kinds := map[string]string{failureKindSearch: queueKindQuery, failureKindDownload: queueKindDownload} // Failure kind → queue kind
+	positions := make(map[[2]string]int, len(queue))                                                      // Kind and target → index in queue
+	for index, item := range queue {
+		positions[[2]string{item.Kind, item.Target}] = index
+	}
+	for rank, retry := range retries {
+		kind := kinds[retry.Kind]
+		index, queued := positions[[2]string{kind, retry.Target}]
+		if !queued {
+			if kind != queueKindDownload || retryCompleted(retry) { // A query that is not generated anymore stays dropped
+				continue
+			}
+			queue = append(queue, queueItem{Kind: kind, Target: retry.Target, State: queueStatePending})
+			index = len(queue) - 1
+		}
+		queue[index].Priority = rank - len(retries) // Ahead of every generated item, oldest failure first
+		queue[index].Attempts, queue[index].LastError = retry.Attempts, retry.LastError
+		if retryLimit > 0 && retry.Attempts >= retryLimit {
+			queue[index].State = queueStateGivenUp
+		}
+	}
+	return queue
+}
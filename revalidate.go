@@ -0,0 +1,73 @@
+package main // Define the main package
+
+import (
+	"context"  // For the download timeout of conditional requests
+	"flag"     // For command-line flag parsing
+	"net/http" // For conditional requests
+	"time"     // For the revalidation interval
+)
+
+var revalidateAfter time.Duration // Age after which stored documents are checked for changes (0 = never)
+
+func init() {
+	flag.DurationVar(&revalidateAfter, "revalidate-after", 7*24*time.Hour, "ask the server whether stored documents changed once they were last checked this long ago, using their ETag and Last-Modified so unchanged ones answer 304 (0 = never)") // Register the revalidation flag
+}
+
+// revalidationDue reports whether a stored document should be asked for
+// again: it has a validator for a conditional request and was last checked
+// more than --revalidate-after ago.
+func revalidationDue(entry manifestEntry) bool {
+	if revalidateAfter <= 0 || entry.Source == manualSource || (entry.ETag == "" && entry.LastModified == "") {
+		return false
+	}
+	checked := entry.DownloadedAt
+	if entry.CheckedAt != nil && entry.CheckedAt.After(checked) {
+		checked = *entry.CheckedAt
+	}
+	return time.Since(checked) >= revalidateAfter
+}
+
+// storedEntryDue returns the manifest entry of a discovered link when it is
+// due for revalidation; handler links are followed to the PDF they resolved to.
+func storedEntryDue(link string) (manifestEntry, bool) {
+	if documentManifest == nil { // Commands that do not download
+		return manifestEntry{}, false
+	}
+	entry, known := documentManifest.lookup(link)
+	if !known {
+		if resolved, cached := lookupResolvedLink(link); cached && resolved != "" {
+			entry, known = documentManifest.lookup(resolved)
+		}
+	}
+	return entry, known && revalidationDue(entry)
+}
+
+// fetchDocument requests a PDF within --download-timeout, conditionally
+// when a stored copy has validators: the server answers 304 if unchanged.
+// Cancelling ctx abandons the download.
+func fetchDocument(ctx context.Context, url string, stored *manifestEntry) (*http.Response, error) {
+	startSession() // Log in before the first request
+	request, err := http.NewRequestWithContext(context.WithValue(ctx, phaseTimeoutKey{}, downloadTimeout), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return currentFetcher().Do(request)
+	}
+	if stored.ETag != "" {
+		request.Header.Set("If-None-Match", stored.ETag)
+	}
+	if stored.LastModified != "" {
+		request.Header.Set("If-Modified-Since", stored.LastModified)
+	}
+	return currentFetcher().Do(request)
+}
+
+// markRevalidated records that a stored document was found unchanged and
+// returns the updated entry.
+func markRevalidated(entry manifestEntry) manifestEntry {
+	now := time.Now().UTC()
+	entry.CheckedAt = &now
+	documentManifest.replace(entry)
+	return entry
+}
@@ -0,0 +1,179 @@
+package main // Define the main package
+
+import (
+	"crypto/subtle"  // For comparing tokens in constant time
+	"encoding/json"  // For the review endpoint
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the review list
+	"log"            // For logging messages and errors
+	"net/http"       // For the review endpoint
+	"os"             // For the review list
+	"os/user"        // For recording who reviewed
+	"strings"        // For checking the token header
+	"text/tabwriter" // For aligned console output
+	"time"           // For review timestamps
+)
+
+// Review states of a document. Documents stored before review was turned
+// on have no state.
+const (
+	reviewPending  = "pending-review" // Stored, waiting for a reviewer
+	reviewApproved = "approved"       // Cleared for publication
+	reviewRejected = "rejected"       // Must not be published
+)
+
+var (
+	requireReview   bool   // New and changed documents start as pending-review
+	approvedOnly    bool   // Serve mode hides documents that are not approved
+	reviewTokenFile string // File holding the bearer token that authorizes POST /review
+)
+
+func init() {
+	flag.BoolVar(&requireReview, "require-review", false, "mark new and changed documents pending-review until a reviewer approves them")                              // Register the review flag
+	flag.BoolVar(&approvedOnly, "approved-only", false, "in serve mode, list and serve only approved documents")                                                       // Register the publication flag
+	flag.StringVar(&reviewTokenFile, "review-token-file", "", "in serve mode, enable POST /review/{file} for clients presenting the bearer token stored in this file") // Register the review token flag
+}
+
+// reviewStateAfterRecord works out the state of an entry that is being
+// recorded: the same content keeps its review, new or changed content needs
+// a fresh one when review is required.
+func reviewStateAfterRecord(entry, previous manifestEntry, replaced bool) manifestEntry {
+	if replaced && previous.SHA256 == entry.SHA256 { // Nothing new to review
+		entry.Review, entry.ReviewedBy, entry.ReviewedAt = previous.Review, previous.ReviewedBy, previous.ReviewedAt
+		return entry
+	}
+	entry.Review, entry.ReviewedBy, entry.ReviewedAt = "", "", nil
+	if requireReview {
+		entry.Review = reviewPending
+	}
+	return entry
+}
+
+// published reports whether serve mode may show the document.
+func (entry manifestEntry) published() bool {
+	return !approvedOnly || entry.Review == reviewApproved
+}
+
+// publishedEntries keeps the entries serve mode may show.
+func publishedEntries(entries []manifestEntry) []manifestEntry {
+	visible := []manifestEntry{} // Encode none as [] rather than null
+	for _, entry := range entries {
+		if entry.published() {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+// setReviewState changes the state of every current entry matching a
+// filename or product name, and returns the changed entries.
+func setReviewState(documents *manifest, target, state, reviewer string) []manifestEntry {
+	now := time.Now().UTC()
+	var changed []manifestEntry
+	for _, entry := range documents.currentEntries() {
+		if target != "all" && len(matchProductDocuments([]manifestEntry{entry}, target)) == 0 {
+			continue
+		}
+		entry.Review, entry.ReviewedBy, entry.ReviewedAt = state, reviewer, &now
+		documents.replace(entry)
+		changed = append(changed, entry)
+	}
+	return changed
+}
+
+// Login of the user running the command
+func currentUsername() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return "unknown"
+}
+
+// runReviewCommand handles "review list [state]" and "review approve|reject|
+// pending <file|product|all>...".
+func runReviewCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: review list [state] | review approve|reject|pending <file|product|all>...")
+	}
+	states := map[string]string{"approve": reviewApproved, "reject": reviewRejected, "pending": reviewPending}
+	if args[0] == "list" {
+		var listed []manifestEntry // Entries in the requested state
+		for _, entry := range mustLoadManifest().currentEntries() {
+			if (len(args) < 2 && entry.Review != "") || (len(args) > 1 && entry.Review == args[1]) {
+				listed = append(listed, entry)
+			}
+		}
+		if jsonOutput() {
+			if listed == nil {
+				listed = []manifestEntry{}
+			}
+			printJSON(listed)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "STATE\tFILE\tTITLE\tREVIEWED BY")
+		for _, entry := range listed {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", entry.Review, entry.File, entry.Title, entry.ReviewedBy)
+		}
+		writer.Flush() // Print the table
+		return
+	}
+	state, known := states[args[0]]
+	if !known || len(args) < 2 {
+		fatalln("usage: review approve|reject|pending <file|product|all>...")
+	}
+	documentManifest = mustLoadManifest()
+	reviewer := currentUsername()
+	for _, target := range args[1:] {
+		changed := setReviewState(documentManifest, target, state, reviewer)
+		if len(changed) == 0 {
+			log.Printf("no document matches %q", target)
+		}
+		for _, entry := range changed {
+			log.Printf("%s: %s", entry.File, state)
+		}
+	}
+	if err := documentManifest.save(manifestFile); err != nil {
+		fatalf("failed to save manifest %s: %v", manifestFile, err)
+	}
+}
+
+// reviewDocument handles POST /review/{file} with a "state" form field of
+// approved, rejected or pending-review, authorized with the review token.
+func (server *libraryServer) reviewDocument(writer http.ResponseWriter, request *http.Request) {
+	presented := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ") // Token sent by the client
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(server.reviewToken)) != 1 {
+		http.Error(writer, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	state := request.FormValue("state")
+	if state != reviewApproved && state != reviewRejected && state != reviewPending {
+		http.Error(writer, "state must be approved, rejected or pending-review", http.StatusBadRequest)
+		return
+	}
+	reviewer := request.FormValue("reviewer") // Who the client says reviewed it
+	if reviewer == "" {
+		reviewer = "api"
+	}
+	file := request.PathValue("file")
+	if !strings.HasSuffix(file, ".pdf") { // Only exact files, never "all" or product names
+		http.NotFound(writer, request)
+		return
+	}
+	if !lockPass("serve") {
+		http.Error(writer, "another instance is writing the data folders; try again later", http.StatusServiceUnavailable)
+		return
+	}
+	defer unlockPass()
+	changed := setReviewState(documentManifest, file, state, reviewer)
+	if len(changed) == 0 {
+		http.NotFound(writer, request)
+		return
+	}
+	if err := documentManifest.save(manifestFile); err != nil {
+		log.Printf("failed to save manifest %s: %v", manifestFile, err)
+	}
+	log.Printf("review: %s set to %s by %s", file, state, reviewer)
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(changed)
+}
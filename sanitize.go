@@ -0,0 +1,101 @@
+package main // Define the main package
+
+import (
+	"flag"          // For command-line flag parsing
+	"fmt"           // For rejecting bad lengths
+	"path/filepath" // For splitting the extension
+	"runtime"       // For the reserved-name default
+	"strconv"       // For parsing the length
+	"strings"       // For building names
+	"unicode"       // For keeping letters of every script
+	"unicode/utf8"  // For cutting names between characters
+)
+
+// Sanitizer turns the last segment of a document URL into a local filename.
+type Sanitizer interface {
+	Sanitize(name string) string // Safe filename for name, "" when nothing is left
+}
+
+// filenamePolicy is the Sanitizer configured with the -filename-* flags.
+// Its zero options reproduce the original naming: lowercase ASCII letters,
+// digits, dots and hyphens, with every other run of characters replaced by
+// one underscore.
+type filenamePolicy struct {
+	preserveCase    bool // Keep upper-case letters
+	preserveUnicode bool // Keep letters and digits of every script
+	maxLength       int  // Longest name in bytes, extension included (0 = unlimited)
+	windowsSafe     bool // Rename names Windows reserves, e.g. con.pdf, and drop trailing dots
+}
+
+var fileSanitizer = filenamePolicy{windowsSafe: runtime.GOOS == "windows"} // Policy of the run
+
+func init() {
+	flag.BoolVar(&fileSanitizer.preserveCase, "filename-preserve-case", false, "keep upper-case letters in filenames taken from URLs")                                                                          // Register the case flag
+	flag.BoolVar(&fileSanitizer.preserveUnicode, "filename-preserve-unicode", false, "keep non-ASCII letters and digits in filenames taken from URLs instead of replacing them")                                // Register the unicode flag
+	flag.BoolVar(&fileSanitizer.windowsSafe, "filename-windows-safe", fileSanitizer.windowsSafe, "rename filenames Windows reserves, such as con.pdf or lpt1.pdf, and trailing dots (default true on Windows)") // Register the reserved-name flag
+	flag.Func("filename-max-length", "longest filename taken from a URL in bytes, extension included, e.g. 120 (default 0, unlimited)", func(value string) error {
+		length, err := strconv.Atoi(value) // Register the length flag
+		if err != nil || length < 0 || (length > 0 && length < 16) {
+			return fmt.Errorf("invalid length %q (use 0 or at least 16)", value)
+		}
+		fileSanitizer.maxLength = length
+		return nil
+	})
+}
+
+// windowsReservedNames are the device names Windows refuses as a filename,
+// whatever the extension.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// Sanitize applies the policy to a name.
+func (policy filenamePolicy) Sanitize(name string) string {
+	if !policy.preserveCase {
+		name = strings.ToLower(name)
+	}
+	var safe strings.Builder
+	replacing := false // Inside a run of replaced characters
+	for _, character := range name {
+		kept := character == '.' || character == '_' || character == '-' || character < utf8.RuneSelf && (unicode.IsLetter(character) || unicode.IsDigit(character))
+		if policy.preserveUnicode && (unicode.IsLetter(character) || unicode.IsDigit(character) || unicode.Is(unicode.Mn, character)) {
+			kept = true // Accents written as combining marks stay with their letter
+		}
+		switch {
+		case kept:
+			safe.WriteRune(character)
+			replacing = false
+		case !replacing: // Collapse runs into one underscore
+			safe.WriteRune('_')
+			replacing = true
+		}
+	}
+	result := safe.String()
+	if policy.windowsSafe {
+		result = strings.TrimRight(result, ".") // Windows drops them, so two names could meet
+		if stem, _, _ := strings.Cut(result, "."); windowsReservedNames[strings.ToLower(stem)] {
+			result = stem + "_" + strings.TrimPrefix(result, stem)
+		}
+	}
+	return truncateFilename(result, policy.maxLength)
+}
+
+// truncateFilename shortens a name to maxLength bytes, keeping its extension
+// and never cutting a character in half.
+func truncateFilename(name string, maxLength int) string {
+	if maxLength <= 0 || len(name) <= maxLength {
+		return name
+	}
+	extension := filepath.Ext(name)
+	if len(extension) > 8 { // Not a real extension
+		extension = ""
+	}
+	stem := name[:len(name)-len(extension)]
+	limit := maxLength - len(extension)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	return stem[:limit] + extension
+}
@@ -0,0 +1,96 @@
+package main // Define the main package
+
+import (
+	"flag"    // For command-line flag parsing
+	"log"     // For logging links out of scope
+	"regexp"  // For matching link patterns
+	"strings" // For translating globs
+)
+
+var (
+	includePatterns []*regexp.Regexp // Links must match one of these when set
+	excludePatterns []*regexp.Regexp // Links matching any of these are skipped
+)
+
+func init() {
+	flag.Func("include-pattern", "only download links matching this glob (* and ?, matched against the whole URL, ignoring case) or re:<regular expression>, e.g. '*floor*'; may be repeated", func(value string) error {
+		pattern, err := compileLinkPattern(value) // Register the include pattern flag
+		if err != nil {
+			return err
+		}
+		includePatterns = append(includePatterns, pattern)
+		return nil
+	})
+	flag.Func("exclude-pattern", "skip links matching this glob or re:<regular expression>, e.g. '*-es.pdf' or 're:(?i)spanish'; may be repeated and wins over -include-pattern", func(value string) error {
+		pattern, err := compileLinkPattern(value) // Register the exclude pattern flag
+		if err != nil {
+			return err
+		}
+		excludePatterns = append(excludePatterns, pattern)
+		return nil
+	})
+}
+
+// compileLinkPattern turns a flag value into a regular expression: re:
+// values are used as they are, anything else is a glob where * matches any
+// run of characters and ? a single one.
+func compileLinkPattern(value string) (*regexp.Regexp, error) {
+	if expression, isRegexp := strings.CutPrefix(value, "re:"); isRegexp {
+		return regexp.Compile(expression)
+	}
+	var expression strings.Builder
+	expression.WriteString("(?i)^")
+	for _, char := range value {
+		switch char {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.Compile(expression.String())
+}
+
+// Report whether any pattern matches any of the links
+func anyPatternMatches(patterns []*regexp.Regexp, links []string) bool {
+	for _, pattern := range patterns {
+		for _, link := range links {
+			if pattern.MatchString(link) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// linkInScope reports whether --include-pattern and --exclude-pattern let a
+// document be downloaded. A handler link is passed with the URL it resolved
+// to, and a pattern matching either of them counts.
+func linkInScope(links ...string) bool {
+	if anyPatternMatches(excludePatterns, links) {
+		return false
+	}
+	return len(includePatterns) == 0 || anyPatternMatches(includePatterns, links)
+}
+
+// scopedLinks drops the discovered links that are out of scope before they
+// are queued, so --preflight and the disk space check only count the rest.
+// Handler links are kept until downloadPDF knows where they lead.
+func scopedLinks(links []string) []string {
+	if len(includePatterns) == 0 && len(excludePatterns) == 0 {
+		return links
+	}
+	kept := links[:0:0]
+	for _, link := range links {
+		if !hasPDFExtension(link) || linkInScope(link) {
+			kept = append(kept, link)
+		}
+	}
+	if dropped := len(links) - len(kept); dropped > 0 {
+		log.Printf("skipping %d links outside -include-pattern/-exclude-pattern", dropped)
+	}
+	return kept
+}
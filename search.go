@@ -0,0 +1,133 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For printing results
+	"log"            // For usage errors
+	"os"             // For reading extracted text and exit codes
+	"sort"           // For ranking hits
+	"strings"        // For phrase matching
+	"text/tabwriter" // For aligned columns
+	"unicode"        // For splitting text into words
+)
+
+// snippetWords is how many words of context are shown around a match.
+const snippetWords = 6
+
+// searchHit is one document matching a full-text search.
+type searchHit struct {
+	File    string `json:"file"`    // Filename inside the PDF folder
+	Title   string `json:"title"`   // Product name from the manifest
+	Phrase  int    `json:"phrase"`  // Times the words appear together, in order
+	Snippet string `json:"snippet"` // Words around the first match
+}
+
+// textWords splits text into lowercase words the way tokenize does, but
+// keeps every occurrence in order so phrases can be matched.
+func textWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) // Same separators as tokenize
+	}) {
+		if len([]rune(word)) >= 2 { // Same minimum length as tokenize
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// searchLibrary finds the documents whose text contains every word of the
+// query, e.g. "sodium hypochlorite". Documents with the words next to each
+// other rank first, most occurrences first.
+func searchLibrary(query string) ([]searchHit, error) {
+	index, err := loadTermIndex()
+	if err != nil {
+		return nil, err
+	}
+	if index.Analyzer != analyzerVersion {
+		return nil, fmt.Errorf("index %s was built with analyzer version %d, this build uses %d; run reindex", termIndexPath(), index.Analyzer, analyzerVersion)
+	}
+	terms := textWords(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	candidates := map[string]bool{} // Hashes containing every term
+	for _, hash := range index.Terms[terms[0]] {
+		candidates[hash] = true
+	}
+	for _, term := range terms[1:] {
+		next := map[string]bool{}
+		for _, hash := range index.Terms[term] {
+			if candidates[hash] {
+				next[hash] = true
+			}
+		}
+		candidates = next
+	}
+	hits := []searchHit{}
+	for hash := range candidates {
+		document := index.Documents[hash]
+		hit := searchHit{File: document.File, Title: document.Title}
+		if text, err := os.ReadFile(extractedTextPath(hash)); err == nil {
+			hit.Phrase, hit.Snippet = matchPhrase(textWords(string(text)), terms)
+		}
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Phrase != hits[j].Phrase {
+			return hits[i].Phrase > hits[j].Phrase
+		}
+		return hits[i].File < hits[j].File
+	})
+	return hits, nil
+}
+
+// matchPhrase counts where terms appear in words in order and returns the
+// words around the first place, or around the first term when they never do.
+func matchPhrase(words, terms []string) (int, string) {
+	count, first, firstTerm := 0, -1, -1
+	for start := range words {
+		if words[start] == terms[0] && firstTerm < 0 {
+			firstTerm = start
+		}
+		if start+len(terms) <= len(words) && strings.Join(words[start:start+len(terms)], " ") == strings.Join(terms, " ") {
+			count++
+			if first < 0 {
+				first = start
+			}
+		}
+	}
+	if first < 0 {
+		first = firstTerm
+	}
+	if first < 0 {
+		return count, ""
+	}
+	from, to := max(first-snippetWords, 0), min(first+len(terms)+snippetWords, len(words))
+	return count, strings.Join(words[from:to], " ")
+}
+
+// runSearchCommand searches the text of the indexed PDFs and exits 1 when
+// nothing matches, like has.
+func runSearchCommand(args []string) {
+	if len(args) == 0 {
+		log.Printf("usage: search <words>")
+		exit(lookupUsage)
+	}
+	hits, err := searchLibrary(strings.Join(args, " "))
+	if err != nil {
+		fatalf("failed to search: %v", err)
+	}
+	if jsonOutput() {
+		printJSON(hits)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+		fmt.Fprintln(writer, "FILE\tTITLE\tPHRASE\tCONTEXT")
+		for _, hit := range hits {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t…%s…\n", hit.File, hit.Title, hit.Phrase, hit.Snippet)
+		}
+		writer.Flush() // Print the table
+	}
+	if len(hits) == 0 {
+		exit(lookupNotFound)
+	}
+}
@@ -0,0 +1,318 @@
+package main // Define the main package
+
+import (
+	"bytes"           // For atomic shard writes
+	"crypto/sha256"   // For spreading links over shards and filter bits
+	"encoding/binary" // For the filter file and bit positions
+	"encoding/json"   // For the shards
+	"flag"            // For command-line flag parsing
+	"fmt"             // For shard file names
+	"log"             // For logging messages and errors
+	"os"              // For reading and writing the index files
+	"path/filepath"   // For building paths
+	"sync"            // For guarding the index across workers
+)
+
+const (
+	seenShardCount     = 256 // Files the exact index is split over, by the first byte of the link's hash
+	seenShardsInMemory = 16  // Shards kept loaded at once, which bounds the memory of the index
+	seenBitsPerLink    = 10  // Filter bits per link; with seenFilterHashes about 1% false positives
+	seenFilterHashes   = 7   // Bits set per link
+)
+
+var (
+	seenCapacity  int                // Links the filter is sized for before it is rebuilt larger
+	seenFilter    *bloomFilter       // Answers "never seen" without touching the disk
+	seenShards    map[int]*seenShard // Loaded shards of the exact index
+	seenClock     uint64             // Ticks on every shard use, for evicting the least recently used
+	seenURLsMutex sync.Mutex         // Guards the filter and the shards
+)
+
+func init() {
+	flag.IntVar(&seenCapacity, "seen-capacity", 1000000, "links the seen-URL filter is sized for (10 bits each); it is rebuilt larger when a crawl outgrows it") // Register the seen capacity flag
+}
+
+// seenShard is one file of the exact seen-URL index.
+type seenShard struct {
+	links    map[string]string // Discovered URL → filename stored in the PDF folder
+	dirty    bool              // Changed since it was read
+	lastUsed uint64            // seenClock at the last use
+}
+
+// bloomFilter is a bit set that says for certain when a link was never
+// seen, so most new links are ruled out without reading a shard.
+type bloomFilter struct {
+	links uint64 // Links added
+	bits  []byte // The filter
+	dirty bool   // Changed since it was read
+}
+
+// Folder of the seen-URL index: the filter and the shards
+func seenURLsDir() string {
+	return filepath.Join(givenFolder, "seen-urls") // Lives next to the search results
+}
+
+// Path of the single-file index written by earlier versions
+func legacySeenURLsPath() string {
+	return filepath.Join(givenFolder, "seen-urls.json")
+}
+
+// linkHash spreads a link over the shards and the filter bits.
+func linkHash(link string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(link))
+}
+
+// newBloomFilter sizes a filter for capacity links.
+func newBloomFilter(capacity int) *bloomFilter {
+	return &bloomFilter{bits: make([]byte, (max(capacity, 1024)*seenBitsPerLink+7)/8), dirty: true}
+}
+
+// positions returns the bits of a link, by double hashing.
+func (filter *bloomFilter) positions(hash [sha256.Size]byte) [seenFilterHashes]uint64 {
+	first, second := binary.LittleEndian.Uint64(hash[8:]), binary.LittleEndian.Uint64(hash[16:])|1
+	size := uint64(len(filter.bits)) * 8
+	var positions [seenFilterHashes]uint64
+	for index := range positions {
+		positions[index] = (first + uint64(index)*second) % size
+	}
+	return positions
+}
+
+// add sets the bits of a link.
+func (filter *bloomFilter) add(hash [sha256.Size]byte) {
+	for _, position := range filter.positions(hash) {
+		filter.bits[position/8] |= 1 << (position % 8)
+	}
+	filter.links++
+	filter.dirty = true
+}
+
+// mayContain reports false when the link was certainly never added.
+func (filter *bloomFilter) mayContain(hash [sha256.Size]byte) bool {
+	for _, position := range filter.positions(hash) {
+		if filter.bits[position/8]&(1<<(position%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// full reports whether the filter holds more links than it was sized for.
+func (filter *bloomFilter) full() bool {
+	return filter.links*seenBitsPerLink > uint64(len(filter.bits))*8
+}
+
+// Load the filter, migrating or rebuilding the index as needed; callers
+// hold seenURLsMutex
+func loadSeenURLsLocked() {
+	if seenFilter != nil { // Already loaded
+		return
+	}
+	seenShards = make(map[int]*seenShard)
+	if err := os.MkdirAll(seenURLsDir(), 0755); err != nil {
+		log.Println(err) // Log error
+	}
+	if content, err := os.ReadFile(filepath.Join(seenURLsDir(), "filter.bin")); err == nil && len(content) > 8 {
+		seenFilter = &bloomFilter{links: binary.LittleEndian.Uint64(content), bits: content[8:]}
+	}
+	if content, err := os.ReadFile(legacySeenURLsPath()); err == nil { // Move the old single file into shards
+		legacy := make(map[string]string)
+		json.Unmarshal(content, &legacy) // A corrupt index just means checking links again
+		if seenFilter == nil {
+			seenFilter = newBloomFilter(max(seenCapacity, 2*len(legacy)))
+		}
+		byShard := make([][]string, seenShardCount) // One shard at a time keeps the cache from thrashing
+		for link := range legacy {
+			hash := linkHash(link)
+			byShard[hash[0]] = append(byShard[hash[0]], link)
+		}
+		for _, links := range byShard {
+			for _, link := range links {
+				markSeenLocked(link, legacy[link])
+			}
+		}
+		if saveSeenURLsLocked() {
+			os.Remove(legacySeenURLsPath())
+			log.Printf("moved %d seen links into %s", len(legacy), seenURLsDir())
+		}
+	}
+	if seenFilter == nil || seenFilter.full() { // Lost, or outgrown
+		rebuildSeenFilterLocked()
+	}
+}
+
+// rebuildSeenFilterLocked sizes a new filter for the links in the shards,
+// reading them a few at a time.
+func rebuildSeenFilterLocked() {
+	links := 0
+	for index := range seenShardCount {
+		links += len(seenShardLocked(index).links)
+	}
+	seenFilter = newBloomFilter(max(seenCapacity, 2*links))
+	for index := range seenShardCount {
+		for link := range seenShardLocked(index).links {
+			seenFilter.add(linkHash(link))
+		}
+	}
+}
+
+// seenShardLocked returns a shard, reading it and evicting the least
+// recently used one when too many are loaded; callers hold seenURLsMutex.
+func seenShardLocked(index int) *seenShard {
+	seenClock++
+	if shard, loaded := seenShards[index]; loaded {
+		shard.lastUsed = seenClock
+		return shard
+	}
+	if len(seenShards) >= seenShardsInMemory {
+		oldest := -1
+		for loaded, shard := range seenShards {
+			if oldest < 0 || shard.lastUsed < seenShards[oldest].lastUsed {
+				oldest = loaded
+			}
+		}
+		writeSeenShardLocked(oldest, seenShards[oldest])
+		delete(seenShards, oldest)
+	}
+	shard := &seenShard{links: make(map[string]string), lastUsed: seenClock}
+	if content, err := os.ReadFile(seenShardPath(index)); err == nil {
+		json.Unmarshal(content, &shard.links) // A corrupt shard just means checking its links again
+	}
+	seenShards[index] = shard
+	return shard
+}
+
+// Path of one shard of the exact index
+func seenShardPath(index int) string {
+	return filepath.Join(seenURLsDir(), fmt.Sprintf("%02x.json", index))
+}
+
+// writeSeenShardLocked saves a changed shard, reporting false on failure.
+func writeSeenShardLocked(index int, shard *seenShard) bool {
+	if !shard.dirty {
+		return true
+	}
+	content, err := json.MarshalIndent(shard.links, "", "  ")
+	if err != nil {
+		log.Println(err) // Log error
+		return false
+	}
+	if _, err := writeFileAtomically(seenShardPath(index), bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Println(err) // Log error
+		return false
+	}
+	shard.dirty = false
+	return true
+}
+
+// markSeenLocked records a link; callers hold seenURLsMutex.
+func markSeenLocked(link, filename string) {
+	hash := linkHash(link)
+	shard := seenShardLocked(int(hash[0]))
+	if known, seen := shard.links[link]; seen && known == filename {
+		return
+	} else if !seen {
+		seenFilter.add(hash)
+	}
+	shard.links[link] = filename
+	shard.dirty = true
+}
+
+// markSeen records that a discovered link is stored under filename.
+func markSeen(link, filename string) {
+	seenURLsMutex.Lock()
+	defer seenURLsMutex.Unlock()
+	loadSeenURLsLocked()
+	markSeenLocked(link, filename)
+}
+
+// alreadySeen reports whether a link was stored by an earlier attempt, its
+// file is still in the PDF folder and it is not due for revalidation. Links
+// the filter rules out, most new ones, are answered without reading a shard.
+func alreadySeen(link string) bool {
+	seenURLsMutex.Lock()
+	loadSeenURLsLocked()
+	hash := linkHash(link)
+	filename, seen := "", false
+	if seenFilter.mayContain(hash) {
+		filename, seen = seenShardLocked(int(hash[0])).links[link]
+	}
+	seenURLsMutex.Unlock()
+	if !seen || !fileExists(filepath.Join(outputDir, filename)) { // A deleted file must be downloaded again
+		return false
+	}
+	_, due := storedEntryDue(link)
+	return !due
+}
+
+// unseenLinks drops the links already stored by earlier runs, so a PDF found
+// under many queries is only considered once.
+func unseenLinks(links []string) []string {
+	var unseen []string // Links still worth a download attempt
+	for _, link := range links {
+		if !alreadySeen(link) {
+			unseen = append(unseen, link)
+		}
+	}
+	if skipped := len(links) - len(unseen); skipped > 0 {
+		log.Printf("skipping %d links already stored by earlier runs", skipped)
+		documentsSkipped.Add(int64(skipped)) // Count them like any other skipped document
+	}
+	return unseen
+}
+
+// pruneSeenURLs drops the links whose file is gone from the PDF folder and
+// rebuilds the filter without them, returning how many were dropped.
+func pruneSeenURLs() int {
+	seenURLsMutex.Lock()
+	defer seenURLsMutex.Unlock()
+	loadSeenURLsLocked()
+	pruned := 0
+	for index := range seenShardCount {
+		shard := seenShardLocked(index)
+		for link, filename := range shard.links {
+			if !fileExists(filepath.Join(outputDir, filename)) {
+				delete(shard.links, link)
+				shard.dirty = true
+				pruned++
+			}
+		}
+	}
+	if pruned > 0 { // A filter cannot forget, start it over
+		rebuildSeenFilterLocked()
+	}
+	return pruned
+}
+
+// Persist the seen-URL index for the next run
+func saveSeenURLs() {
+	seenURLsMutex.Lock()
+	defer seenURLsMutex.Unlock()
+	if seenFilter == nil { // Nothing was checked this run
+		return
+	}
+	if seenFilter.full() { // Grew past its size this run
+		rebuildSeenFilterLocked()
+	}
+	saveSeenURLsLocked()
+}
+
+// saveSeenURLsLocked writes the changed shards and the filter, reporting
+// false on failure; callers hold seenURLsMutex.
+func saveSeenURLsLocked() bool {
+	saved := true
+	for index, shard := range seenShards {
+		saved = writeSeenShardLocked(index, shard) && saved
+	}
+	if !seenFilter.dirty {
+		return saved
+	}
+	content := binary.LittleEndian.AppendUint64(nil, seenFilter.links)
+	content = append(content, seenFilter.bits...)
+	if _, err := writeFileAtomically(filepath.Join(seenURLsDir(), "filter.bin"), bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Println(err) // Log error
+		return false
+	}
+	seenFilter.dirty = false
+	return saved
+}
@@ -0,0 +1,125 @@
+package main // Define the main package
+
+import (
+	"flag"          // For command-line flag parsing
+	"log"           // For logging messages and errors
+	"net/http"      // For the HTTP server
+	"path/filepath" // For building local paths
+	"sync"          // For serializing on-demand fetches
+	"time"          // For the kiosk idle timeout
+)
+
+var (
+	serveAddress string        // Address the serve command listens on
+	readThrough  bool          // Fetch missing documents from the origin on demand
+	kioskMode    bool          // Serve the locked-down search-and-view UI only
+	kioskIdle    time.Duration // Inactivity after which the kiosk UI resets
+)
+
+func init() {
+	flag.StringVar(&serveAddress, "listen", "127.0.0.1:8080", "address the serve command listens on")                                                                        // Register the listen address flag
+	flag.BoolVar(&readThrough, "read-through", false, "in serve mode, fetch documents missing from the library from the origin on demand")                                   // Register the read-through flag
+	flag.BoolVar(&kioskMode, "kiosk", false, "in serve mode, show only search and view, disable uploads and reset the UI after -kiosk-idle")                                 // Register the kiosk flag
+	flag.DurationVar(&kioskIdle, "kiosk-idle", 3*time.Minute, "kiosk mode: inactivity after which the UI returns to a blank search (scrolling inside a PDF does not count)") // Register the kiosk idle flag
+}
+
+// libraryServer serves the local PDF library over HTTP.
+type libraryServer struct {
+	fetchMutex  sync.Mutex        // Serializes on-demand fetches so one file is fetched once
+	sources     map[string]string // Local filename → source URL, for read-through
+	uploadToken string            // Bearer token for POST /upload, "" when uploads are off
+	reviewToken string            // Bearer token for POST /review/{file}, "" when reviews over HTTP are off
+	apiToken    string            // Bearer token for the /crawls and /documents API, "" when it is off
+	crawlJobs   crawlJobs         // Crawls started through the API
+}
+
+// runServeCommand starts the HTTP server and blocks.
+func runServeCommand() {
+	documentManifest = mustLoadManifest()    // Read-through downloads are recorded here
+	storedAtStart = directorySize(outputDir) // Baseline for --max-total-bytes
+	server := &libraryServer{sources: make(map[string]string), uploadToken: loadTokenFile(uploadTokenFile), reviewToken: loadTokenFile(reviewTokenFile), apiToken: loadTokenFile(apiTokenFile)}
+	if readThrough { // Map filenames back to their source URLs
+		for _, link := range discoveredLinks() {
+			if localPath := localPDFPath(link); localPath != "" {
+				server.sources[pdfFilename(link)] = link
+			}
+		}
+		for _, entry := range documentManifest.currentEntries() {
+			if entry.Source != manualSource { // Uploads have no origin to fetch from
+				server.sources[entry.File] = entry.URL
+			}
+		}
+	}
+	log.Printf("serving %s on http://%s (read-through: %t, uploads: %t, kiosk: %t, approved only: %t, api: %t)", outputDir, serveAddress, readThrough, server.uploadToken != "" && !kioskMode, kioskMode, approvedOnly, server.apiToken != "" && !kioskMode)
+	fatalln(http.ListenAndServe(serveAddress, server.routes()))
+}
+
+// routes registers the handlers of the library server. A kiosk never
+// accepts uploads, reviews or API calls, whatever tokens are configured.
+func (server *libraryServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pdf/{file...}", server.servePDF)      // Individual documents, in subfolders for other document types
+	mux.HandleFunc("GET /barcode/{code}", server.serveBarcode) // Scanned code → product SDS
+	server.registerWebUI(mux)                                  // Catalog page and offline support
+	server.registerSitemap(mux)                                // Let intranet search appliances index the mirror
+	if server.uploadToken != "" && !kioskMode {                // Manual uploads are opt-in and never on a kiosk
+		mux.HandleFunc("POST /upload", server.uploadPDF)
+	}
+	if server.reviewToken != "" && !kioskMode { // Reviews over HTTP are opt-in and never on a kiosk
+		mux.HandleFunc("POST /review/{file...}", server.reviewDocument)
+	}
+	if server.apiToken != "" && !kioskMode { // The crawl API is opt-in and never on a kiosk
+		server.registerAPI(mux)
+	}
+	return mux
+}
+
+// servePDF serves one document from the library, fetching it from the
+// origin first when read-through is enabled and it is not stored yet.
+func (server *libraryServer) servePDF(writer http.ResponseWriter, request *http.Request) {
+	filename := request.PathValue("file") // Requested document
+	if !validLibraryName(filename) {
+		http.NotFound(writer, request) // Reject traversal, hidden and non-PDF names
+		return
+	}
+	filePath := filepath.Join(outputDir, filename) // Local copy
+	if !fileExists(filePath) && readThrough {
+		server.fetch(filename)
+	}
+	if !fileExists(filePath) {
+		http.NotFound(writer, request)
+		return
+	}
+	if entry, known := documentManifest.entryForFile(filename); approvedOnly && (!known || !entry.published()) {
+		http.NotFound(writer, request) // Not cleared for publication
+		return
+	}
+	writer.Header().Set("Content-Type", "application/pdf")
+	http.ServeFile(writer, request, filePath) // Handles ranges and caching headers
+}
+
+// fetch downloads a missing document through the normal download pipeline.
+func (server *libraryServer) fetch(filename string) {
+	sourceURL, known := server.sources[filename] // Where the document comes from
+	if !known {
+		return
+	}
+	server.fetchMutex.Lock()
+	defer server.fetchMutex.Unlock()
+	if fileExists(filepath.Join(outputDir, filename)) { // Another request fetched it meanwhile
+		return
+	}
+	if !lockPass("serve") { // Served as missing until the other writer is done
+		return
+	}
+	defer unlockPass()
+	log.Printf("read-through: fetching %s from %s", filename, sourceURL)
+	downloadPDF(sourceURL) // Same validation as a crawl
+	saveSeenURLs()         // Keep the seen-URL index in step with the library
+	if err := documentManifest.save(manifestFile); err != nil {
+		log.Printf("failed to save manifest %s: %v", manifestFile, err)
+	} else {
+		checkpointJournal()
+	}
+	syncDeferredFiles() // Flush what -fsync end held back
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKioskRejectsWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		kiosk bool
+		path  string
+	}{
+		{"upload", false, "/upload"},
+		{"upload on a kiosk", true, "/upload"},
+		{"review", false, "/review/floor.pdf"},
+		{"review on a kiosk", true, "/review/floor.pdf"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setGlobal(t, &kioskMode, test.kiosk)
+			server := &libraryServer{sources: make(map[string]string), uploadToken: "upload-secret", reviewToken: "review-secret"}
+			recorder := httptest.NewRecorder()
+			server.routes().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, test.path, nil)) // No token: refused either way
+			routed := recorder.Code != http.StatusNotFound && recorder.Code != http.StatusMethodNotAllowed
+			if routed == test.kiosk {
+				t.Errorf("POST %s answered %d with kiosk %t", test.path, recorder.Code, test.kiosk)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package main // Define the main package
+
+import (
+	"context"       // For cancelling discovery on stop
+	"encoding/json" // For the daemon status file
+	"flag"          // For copying the flags into the unit
+	"fmt"           // For printing the status and unit
+	"log"           // For logging service events
+	"net"           // For the systemd notification socket
+	"os"            // For the status and stop files
+	"os/signal"     // For stopping on SIGTERM and Ctrl+C
+	"path/filepath" // For the status file path
+	"strconv"       // For the watchdog interval
+	"strings"       // For quoting the unit command line
+	"sync"          // For the heartbeat sharing the status
+	"sync/atomic"   // For the stop request shared with the workers
+	"syscall"       // For SIGTERM
+	"time"          // For heartbeats
+)
+
+// Daemon states reported in the status file and to systemd
+const (
+	daemonStarting = "starting" // Loading state before the first crawl
+	daemonCrawling = "crawling" // A crawl is running
+	daemonIdle     = "idle"     // Waiting for the next crawl
+	daemonStopping = "stopping" // Finishing in-flight work before exiting
+	daemonStopped  = "stopped"  // Exited cleanly
+)
+
+var (
+	stopRequested         atomic.Bool                                // Set once the daemon was asked to stop; remaining work is deferred like an exhausted budget
+	stopContext, stopWork = context.WithCancel(context.Background()) // Cancelled along with stopRequested, for work that takes a context
+	serviceStopRequests   = make(chan string, 1)                     // Stops requested by the Windows service control manager
+	workingDir            string                                     // Folder to change to before anything else
+)
+
+func init() {
+	flag.StringVar(&workingDir, "working-dir", "", "change to this folder before resolving any other path; daemon install records the current one, as Windows starts services in the system folder") // Register the working folder flag
+}
+
+// changeWorkingDir applies --working-dir.
+func changeWorkingDir() {
+	if workingDir == "" {
+		return
+	}
+	if err := os.Chdir(workingDir); err != nil {
+		fatalConfig("failed to change to -working-dir %s: %v", workingDir, err)
+	}
+}
+
+// daemonStatus is what `daemon status` reports about a running daemon.
+type daemonStatus struct {
+	PID           int        `json:"pid"`                        // Process ID of the daemon
+	State         string     `json:"state"`                      // One of the daemon states
+	StartedAt     time.Time  `json:"started_at"`                 // When the daemon started
+	UpdatedAt     time.Time  `json:"updated_at"`                 // Heartbeat, refreshed every minute
+	LastCrawlAt   time.Time  `json:"last_crawl_at,omitzero"`     // When the last crawl finished
+	LastCrawlDocs int64      `json:"last_crawl_saved,omitempty"` // Documents saved by the last crawl
+	NextCrawlAt   time.Time  `json:"next_crawl_at,omitzero"`     // When the next crawl starts
+	mutex         sync.Mutex // Guards the fields against the heartbeat
+}
+
+// Path of the daemon status file
+func daemonStatusPath() string {
+	return filepath.Join(givenFolder, "daemon-status.json") // Lives next to the search results
+}
+
+// Path of the file `daemon stop` creates for the daemon to pick up
+func daemonStopPath() string {
+	return filepath.Join(givenFolder, "daemon.stop")
+}
+
+// update changes the status under its lock and saves it.
+func (status *daemonStatus) update(change func()) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	change()
+	status.save()
+}
+
+// beat refreshes the status every minute, crawls included, so `daemon
+// status` can tell a live daemon from one that died.
+func (status *daemonStatus) beat() {
+	for range time.Tick(time.Minute) {
+		status.update(func() {})
+	}
+}
+
+// Persist the daemon status and tell systemd about it; callers hold the lock
+func (status *daemonStatus) save() {
+	status.UpdatedAt = time.Now().UTC()
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := writeFileAtomically(daemonStatusPath(), strings.NewReader(string(content)), int64(len(content))); err != nil {
+		log.Println(err)
+	}
+	notifyService("STATUS=" + status.State)
+}
+
+// notifyService sends a state line to systemd when it started the daemon
+// as a Type=notify service; elsewhere it does nothing.
+func notifyService(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" { // Not started by systemd
+		return
+	}
+	connection, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"}) // An @ name is an abstract socket
+	if err != nil {
+		log.Printf("failed to notify systemd: %v", err)
+		return
+	}
+	defer connection.Close()
+	connection.Write([]byte(state))
+}
+
+// watchServiceStop sets stopRequested on SIGTERM, Ctrl+C, a `daemon stop`
+// or a stop from the Windows service control manager, and closes the
+// returned channel. A second signal exits at once.
+func watchServiceStop() <-chan struct{} {
+	stopped := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	os.Remove(daemonStopPath()) // A stop request left over from before this start
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+	wait:
+		for {
+			select {
+			case received := <-signals:
+				log.Printf("daemon: %s received, stopping after the in-flight requests", received)
+				break wait
+			case reason := <-serviceStopRequests:
+				log.Printf("daemon: %s, stopping after the in-flight requests", reason)
+				break wait
+			case <-ticker.C:
+				if !fileExists(daemonStopPath()) {
+					continue
+				}
+				os.Remove(daemonStopPath())
+				log.Printf("daemon: stop requested, stopping after the in-flight requests")
+			}
+			break wait
+		}
+		stopRequested.Store(true)
+		stopWork()
+		notifyService("STOPPING=1")
+		close(stopped)
+		<-signals
+		fatalln("daemon: second signal, exiting without saving")
+	}()
+	return stopped
+}
+
+// keepServiceAlive pets the systemd watchdog when the unit sets WatchdogSec.
+func keepServiceAlive() {
+	microseconds, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || microseconds <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(time.Duration(microseconds) * time.Microsecond / 2) { // Twice per watchdog period
+			notifyService("WATCHDOG=1")
+		}
+	}()
+}
+
+// runDaemonStatusCommand prints the status file of the daemon, and exits
+// with status 1 when the daemon is not running.
+func runDaemonStatusCommand() {
+	content, err := os.ReadFile(daemonStatusPath())
+	if err != nil {
+		fatalf("no daemon status in %s: %v", daemonStatusPath(), err)
+	}
+	status := &daemonStatus{}
+	if err := json.Unmarshal(content, status); err != nil {
+		fatalf("failed to read %s: %v", daemonStatusPath(), err)
+	}
+	stale := status.State != daemonStopped && time.Since(status.UpdatedAt) > 3*time.Minute // No heartbeat: the process died
+	if jsonOutput() {
+		printJSON(struct {
+			*daemonStatus
+			Stale bool `json:"stale"`
+		}{status, stale})
+		return
+	}
+	state := status.State
+	if stale {
+		state += fmt.Sprintf(" (no heartbeat since %s; the process is probably gone)", status.UpdatedAt.Local().Format(time.DateTime))
+	}
+	fmt.Printf("state:       %s\n", state)
+	fmt.Printf("pid:         %d\n", status.PID)
+	fmt.Printf("started:     %s\n", status.StartedAt.Local().Format(time.DateTime))
+	if !status.LastCrawlAt.IsZero() {
+		fmt.Printf("last crawl:  %s, %d documents saved\n", status.LastCrawlAt.Local().Format(time.DateTime), status.LastCrawlDocs)
+	}
+	if !status.NextCrawlAt.IsZero() && status.State == daemonIdle {
+		fmt.Printf("next crawl:  %s\n", status.NextCrawlAt.Local().Format(time.DateTime))
+	}
+	if stale || status.State == daemonStopped {
+		exit(1) // Not running
+	}
+}
+
+// runDaemonStopCommand asks the daemon sharing this data folder to stop. It
+// works the same on every platform, where signals do not.
+func runDaemonStopCommand() {
+	if err := os.WriteFile(daemonStopPath(), nil, 0644); err != nil {
+		fatalf("failed to request a stop: %v", err)
+	}
+	log.Printf("stop requested; the daemon finishes its in-flight requests and exits, see daemon status")
+}
+
+// runDaemonUnitCommand prints a systemd unit running this binary as a daemon
+// with the flags given to this command, for /etc/systemd/system. On Windows,
+// `daemon install` registers a service instead.
+func runDaemonUnitCommand() {
+	executable, err := os.Executable()
+	if err != nil {
+		fatalln(err)
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fatalln(err)
+	}
+	command := []string{strconv.Quote(executable), "daemon"}
+	flag.Visit(func(given *flag.Flag) { // Only the flags set on this command line
+		command = append(command, strconv.Quote("-"+given.Name+"="+given.Value.String()))
+	})
+	fmt.Printf(`[Unit]
+Description=Hillyard document mirror
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=1min
+WatchdogSec=10min
+TimeoutStopSec=5min
+
+[Install]
+WantedBy=multi-user.target
+`, strings.Join(command, " "), workingDir)
+}
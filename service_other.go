@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main // Define the main package
+
+// runAsService reports false: only Windows has a service control manager
+// to answer. Under systemd the daemon runs as it is; see daemon unit.
+func runAsService(daemon func()) bool {
+	return false
+}
+
+// installDaemonService is Windows only; systemd takes the daemon unit.
+func installDaemonService() {
+	fatalConfig("daemon install registers a Windows service; on Linux install the output of daemon unit")
+}
+
+// uninstallDaemonService is Windows only.
+func uninstallDaemonService() {
+	fatalConfig("daemon uninstall removes a Windows service; on Linux disable the unit with systemctl")
+}
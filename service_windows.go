@@ -0,0 +1,125 @@
+//go:build windows
+
+package main // Define the main package
+
+import (
+	"flag"    // For copying the flags into the service
+	"log"     // For reporting what was registered
+	"os"      // For the executable and working folder
+	"strings" // For listing the service arguments
+	"time"    // For the restart delay and stop wait
+
+	"golang.org/x/sys/windows/svc"     // For running under the service control manager
+	"golang.org/x/sys/windows/svc/mgr" // For registering the service
+)
+
+// windowsServiceName is the name the daemon is registered under.
+const windowsServiceName = "hillyard-mirror"
+
+// runAsService runs the daemon under the Windows service control manager
+// and reports true when it started this process; run any other way it
+// returns false and the daemon runs in the console.
+func runAsService(daemon func()) bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false
+	}
+	if err := svc.Run(windowsServiceName, windowsService{daemon: daemon}); err != nil {
+		fatalf("daemon: service %s failed: %v", windowsServiceName, err)
+	}
+	return true
+}
+
+// windowsService answers the service control manager for the daemon.
+type windowsService struct {
+	daemon func() // The daemon loop
+}
+
+// Execute runs the daemon and turns Stop and Shutdown into the same stop a
+// `daemon stop` requests, so the crawl defers its remaining work.
+func (service windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.daemon()
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32((5 * time.Minute).Milliseconds())} // Like TimeoutStopSec in the unit
+				select {
+				case serviceStopRequests <- "service stop requested":
+				default: // Already stopping
+				}
+			}
+		}
+	}
+}
+
+// installDaemonService registers the daemon as an automatically started
+// service running in the current folder with the flags given to this
+// command, restarted a minute after it fails like the systemd unit.
+func installDaemonService() {
+	executable, err := os.Executable()
+	if err != nil {
+		fatalln(err)
+	}
+	folder, err := os.Getwd()
+	if err != nil {
+		fatalln(err)
+	}
+	args := []string{"daemon", "-working-dir=" + folder}
+	flag.Visit(func(given *flag.Flag) { // Only the flags set on this command line
+		if given.Name != "working-dir" {
+			args = append(args, "-"+given.Name+"="+given.Value.String())
+		}
+	})
+	manager, err := mgr.Connect()
+	if err != nil {
+		fatalf("failed to connect to the service manager (run as administrator): %v", err)
+	}
+	defer manager.Disconnect()
+	service, err := manager.CreateService(windowsServiceName, executable, mgr.Config{
+		DisplayName:      "Hillyard document mirror",
+		Description:      "Mirrors the Hillyard safety data sheets on a schedule.",
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true, // After the network is up
+	}, args...)
+	if err != nil {
+		fatalf("failed to install service %s: %v", windowsServiceName, err)
+	}
+	defer service.Close()
+	if err := service.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, uint32((24 * time.Hour).Seconds())); err != nil {
+		log.Printf("failed to set restart on failure: %v", err)
+	}
+	log.Printf("installed service %s running %s %s; start it with `sc start %s`", windowsServiceName, executable, strings.Join(args, " "), windowsServiceName)
+	if logFile == "" {
+		log.Printf("services have no console; reinstall with -log-file to keep the log")
+	}
+}
+
+// uninstallDaemonService removes the service installed by daemon install.
+func uninstallDaemonService() {
+	manager, err := mgr.Connect()
+	if err != nil {
+		fatalf("failed to connect to the service manager (run as administrator): %v", err)
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(windowsServiceName)
+	if err != nil {
+		fatalf("service %s is not installed: %v", windowsServiceName, err)
+	}
+	defer service.Close()
+	if err := service.Delete(); err != nil {
+		fatalf("failed to remove service %s: %v", windowsServiceName, err)
+	}
+	log.Printf("removed service %s; it stops after its in-flight requests if it is running", windowsServiceName)
+}
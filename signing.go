@@ -0,0 +1,223 @@
+package main // Define the main package
+
+import (
+	"bytes"           // For comparing key algorithms
+	"crypto/ed25519"  // For signing the manifest
+	"crypto/sha256"   // For key ids and trusted comments
+	"encoding/base64" // For the minisign format
+	"encoding/hex"    // For keys in the snapshot format
+	"errors"          // For describing bad keys
+	"flag"            // For command-line flag parsing
+	"fmt"             // For building signature files
+	"log"             // For logging what was signed
+	"os"              // For reading keys and signed files
+	"path/filepath"   // For the SHA256SUMS path
+	"strings"         // For parsing key and signature files
+	"time"            // For the signature timestamp
+
+	"golang.org/x/crypto/blake2b" // For prehashed minisign signatures
+)
+
+var (
+	minisignAlgorithm = []byte("Ed") // Ed25519 keys, and legacy signatures over the file itself
+	minisignPrehashed = []byte("ED") // Signatures over the BLAKE2b-512 hash of the file, minisign's default
+)
+
+var signManifest bool // Sign the manifest and SHA256SUMS after every run
+
+func init() {
+	flag.BoolVar(&signManifest, "sign-manifest", false, "sign the manifest and SHA256SUMS with -signing-key (a snapshot keygen key or an unencrypted minisign key) after every run, writing minisign-compatible <file>.minisig signatures; verify checks them with -verify-key (a .pub or minisign public key)") // Register the manifest signing flag
+}
+
+// manifestSigner signs files with an ed25519 key.
+type manifestSigner struct {
+	key   ed25519.PrivateKey // Signing key
+	keyID []byte             // 8-byte key id written into every signature
+}
+
+// loadSigner reads a private key: a hex key from snapshot keygen, or an
+// unencrypted minisign secret key (minisign -G -W).
+func loadSigner(path string) (manifestSigner, error) {
+	if key, err := readKeyFile(path, ed25519.PrivateKeySize); err == nil {
+		return manifestSigner{key: key, keyID: keyID(ed25519.PrivateKey(key).Public().(ed25519.PublicKey))}, nil
+	}
+	decoded, err := readMinisignKey(path, 158)
+	if err != nil {
+		return manifestSigner{}, err
+	}
+	if !bytes.Equal(decoded[:2], minisignAlgorithm) {
+		return manifestSigner{}, errors.New("not an ed25519 minisign key")
+	}
+	if decoded[2] != 0 || decoded[3] != 0 { // Protected with a password
+		return manifestSigner{}, errors.New("encrypted minisign keys are not supported; create one with minisign -G -W or snapshot keygen")
+	}
+	return manifestSigner{key: ed25519.PrivateKey(decoded[62:126]), keyID: decoded[54:62]}, nil
+}
+
+// loadVerifier reads a public key: a hex key from snapshot keygen or a
+// minisign public key. The key id is nil for hex keys.
+func loadVerifier(path string) (ed25519.PublicKey, []byte, error) {
+	if key, err := readKeyFile(path, ed25519.PublicKeySize); err == nil {
+		return key, nil, nil
+	}
+	decoded, err := readMinisignKey(path, 42)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(decoded[:2], minisignAlgorithm) {
+		return nil, nil, errors.New("not an ed25519 minisign key")
+	}
+	return ed25519.PublicKey(decoded[10:42]), decoded[2:10], nil
+}
+
+// readMinisignKey decodes the key line of a minisign key file.
+func readMinisignKey(path string, size int) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(decoded) != size {
+			return nil, fmt.Errorf("neither a hex key nor a minisign key of %d bytes", size)
+		}
+		return decoded, nil
+	}
+	return nil, errors.New("empty key file")
+}
+
+// keyID derives the minisign key id of a hex key from its public key.
+func keyID(public ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(public)
+	return sum[:8]
+}
+
+// writeMinisignPublicKey writes the public key in minisign's format, so
+// `minisign -V -p <file> -m manifest.json` checks the signatures too.
+func writeMinisignPublicKey(path string, public ed25519.PublicKey) error {
+	id := keyID(public)
+	line := base64.StdEncoding.EncodeToString(append(append(append([]byte(nil), minisignAlgorithm...), id...), public...))
+	content := fmt.Sprintf("untrusted comment: minisign public key %X\n%s\n", reverseBytes(id), line)
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// reverseBytes returns the bytes in reverse order; minisign prints key ids
+// as little-endian numbers.
+func reverseBytes(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for index, value := range data {
+		reversed[len(data)-1-index] = value
+	}
+	return reversed
+}
+
+// signFile writes <path>.minisig. The trusted comment, which the signature
+// covers as well, names the file, its hash and when it was signed.
+func (signer manifestSigner) signFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	digest := blake2b.Sum512(content) // minisign -V rejects legacy signatures without -l
+	signature := ed25519.Sign(signer.key, digest[:])
+	sum := sha256.Sum256(content)
+	trusted := fmt.Sprintf("timestamp:%d\tfile:%s\tsha256:%s", time.Now().Unix(), filepath.Base(path), hex.EncodeToString(sum[:]))
+	global := ed25519.Sign(signer.key, append(append([]byte(nil), signature...), trusted...))
+	line := append(append(append([]byte(nil), minisignPrehashed...), signer.keyID...), signature...)
+	text := fmt.Sprintf("untrusted comment: signature from hillyard-com-documentation\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(line), trusted, base64.StdEncoding.EncodeToString(global))
+	_, err = writeFileAtomically(path+".minisig", strings.NewReader(text), int64(len(text)))
+	return err
+}
+
+// verifyFileSignature checks <path>.minisig against the file and the key. It
+// accepts legacy signatures as well, such as those written before signing
+// switched to prehashing.
+func verifyFileSignature(path string, public ed25519.PublicKey, id []byte) error {
+	signed, err := os.ReadFile(path + ".minisig")
+	if err != nil {
+		return fmt.Errorf("missing signature: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(signed), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature file")
+	}
+	line, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(line) != 74 {
+		return errors.New("malformed signature")
+	}
+	prehashed := bytes.Equal(line[:2], minisignPrehashed)
+	if !prehashed && !bytes.Equal(line[:2], minisignAlgorithm) {
+		return fmt.Errorf("unsupported signature algorithm %q", line[:2])
+	}
+	if id != nil && !bytes.Equal(line[2:10], id) {
+		return errors.New("signed with another key")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if prehashed {
+		digest := blake2b.Sum512(content)
+		content = digest[:]
+	}
+	if !ed25519.Verify(public, content, line[10:]) {
+		return errors.New("signature does not match; the file changed after it was signed")
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(public, append(append([]byte(nil), line[10:]...), strings.TrimPrefix(lines[2], "trusted comment: ")...), global) {
+		return errors.New("trusted comment does not match its signature")
+	}
+	return nil
+}
+
+// signedLibraryFiles are the files --sign-manifest signs that exist.
+func signedLibraryFiles() []string {
+	files := []string{manifestFile}
+	if sums := filepath.Join(outputDir, "SHA256SUMS"); fileExists(sums) {
+		files = append(files, sums)
+	}
+	return files
+}
+
+// signLibrary signs the saved manifest and SHA256SUMS with --sign-manifest.
+// With the hashes of every PDF in both, the signatures cover the library.
+func signLibrary() {
+	if !signManifest {
+		return
+	}
+	signer, err := loadSigner(signingKey)
+	if err != nil {
+		log.Printf("failed to read signing key %s: %v", signingKey, err)
+		return
+	}
+	for _, path := range signedLibraryFiles() {
+		if err := signer.signFile(path); err != nil {
+			log.Printf("failed to sign %s: %v", path, err)
+			continue
+		}
+		log.Printf("signed %s", path)
+	}
+}
+
+// verifyLibrarySignatures checks the manifest and SHA256SUMS signatures
+// with --verify-key and returns the problems found.
+func verifyLibrarySignatures() []verifyProblem {
+	public, id, err := loadVerifier(verifyKey)
+	if err != nil {
+		fatalConfig("failed to read verification key %s: %v", verifyKey, err)
+	}
+	var problems []verifyProblem
+	for _, path := range signedLibraryFiles() {
+		if err := verifyFileSignature(path, public, id); err != nil {
+			log.Printf("%s: %v", path, err)
+			problems = append(problems, verifyProblem{File: path, Problem: err.Error()})
+			continue
+		}
+		log.Printf("%s: signature valid", path)
+	}
+	return problems
+}
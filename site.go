@@ -0,0 +1,126 @@
+package main // Define the main package
+
+import (
+	"context"  // For stopping discovery part way
+	"errors"   // For failed searches
+	"flag"     // For command-line flag parsing
+	"fmt"      // For rejecting unknown sites
+	"log"      // For logging truncated searches
+	"net/http" // For search statuses
+	"sort"     // For listing the sites
+	"strings"  // For listing the sites
+	"sync"     // For building the adapter once
+	"time"     // For result timestamps
+
+	"github.com/Strong-Foundation/hillyard-com-documentation/pkg/hillyard" // For searching the site
+)
+
+var (
+	siteName             = "hillyard"                     // Publisher whose documents are mirrored
+	siteAdapterFactories = map[string]func() SiteAdapter{ // Every available publisher by name
+		"hillyard": func() SiteAdapter { return hillyardSite{} },
+	}
+	site     SiteAdapter // Adapter of the run, built on first use
+	siteOnce sync.Once   // Builds site
+)
+
+func init() {
+	flag.Func("site", "publisher whose documents are mirrored (default hillyard)", func(value string) error {
+		if _, known := siteAdapterFactories[value]; !known { // Register the site flag
+			return fmt.Errorf("unknown site %q (want one of %s)", value, strings.Join(siteNames(), ", "))
+		}
+		siteName = value
+		return nil
+	})
+}
+
+// SiteAdapter holds what is specific to one publisher of safety data
+// sheets: how its search is queried, how its pages list documents and where
+// its download links lead. Everything else, from the queue to the manifest,
+// is shared. A new publisher implements this interface and registers a
+// constructor in siteAdapterFactories.
+type SiteAdapter interface {
+	Name() string                                             // Name used in -site and logs
+	Discover(ctx context.Context, target string) searchResult // Search one query target, every result page
+	ResolveURL(link string) string                            // PDF a download link that does not end in .pdf leads to, "" if none
+	Parse(content string, baseURL string) []documentLink      // Document links of a saved results page
+}
+
+// errSearchFailed ends a search whose page failed; fetchSearchPage has
+// recorded why.
+var errSearchFailed = errors.New("search failed")
+
+// siteNames lists the registered sites.
+func siteNames() []string {
+	var names []string
+	for name := range siteAdapterFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// currentSite returns the adapter selected with --site.
+func currentSite() SiteAdapter {
+	siteOnce.Do(func() {
+		site = siteAdapterFactories[siteName]()
+	})
+	return site
+}
+
+// hillyardSite searches hillyard.com (or --origin-url) one document type at
+// a time and reads the document links from its HTML or JSON results.
+type hillyardSite struct{}
+
+// Name returns the site name.
+func (hillyardSite) Name() string { return "hillyard" }
+
+// Discover searches a query target with a hillyard.Client, which follows
+// pagination so the links of every page are returned. Pages go through
+// fetchSearchPage, so they count against the budget and record failures,
+// and the result carries the status of the last page.
+func (hillyardSite) Discover(ctx context.Context, target string) searchResult {
+	docType, query := splitQueryTarget(target) // Search of the document type
+	result := searchResult{Query: target, FetchedAt: time.Now().UTC()}
+	kind := hillyard.Kind(docType.Name)
+	fetched := 0 // Pages requested so far
+	client := hillyard.Client{
+		BaseURL:     originURL,
+		MaxPages:    max(maxSearchPages, 1),
+		SearchPaths: map[hillyard.Kind]string{kind: docType.SearchPath}, // Honours -doc-type-path
+		FetchPage: func(ctx context.Context, pageURL string) (string, error) {
+			if fetched > 0 && (ctx.Err() != nil || !reserveRequest()) { // The first page was reserved by the caller
+				return "", hillyard.ErrStopPaging
+			}
+			fetched++
+			body, status := fetchSearchPage(ctx, target, pageURL)
+			result.Status = status
+			if status != http.StatusOK { // Failed searches are retried by the next run
+				return "", errSearchFailed
+			}
+			result.RawSize += len(body)
+			return body, nil
+		},
+	}
+	found, err := client.SearchPages(ctx, kind, query)
+	if err != nil {
+		return result
+	}
+	result.Pages = found.Pages
+	if found.Truncated {
+		log.Printf("stopping %q after %d result pages", target, found.Pages)
+		result.Truncated = true
+	}
+	result.Links = found.Documents // Every page, in order
+	return result
+}
+
+// ResolveURL follows a download handler to the PDF it serves.
+func (hillyardSite) ResolveURL(link string) string {
+	return resolveDocumentLink(link)
+}
+
+// Parse reads the document links of an HTML or JSON results page.
+func (hillyardSite) Parse(content string, baseURL string) []documentLink {
+	return extractDocumentLinks(content, baseURL)
+}
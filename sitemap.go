@@ -0,0 +1,110 @@
+package main // Define the main package
+
+import (
+	"encoding/xml" // For the sitemap format
+	"flag"         // For command-line flag parsing
+	"fmt"          // For robots.txt
+	"net/http"     // For the handlers
+	"net/url"      // For escaping filenames
+	"strconv"      // For sitemap page numbers
+	"strings"      // For trimming the public URL
+	"time"         // For last-modified dates
+)
+
+// sitemapLimit is the most URLs one sitemap file may list.
+const sitemapLimit = 50000
+
+var publicURL string // Base URL the portal is reached at, for absolute sitemap links
+
+func init() {
+	flag.StringVar(&publicURL, "public-url", "", "in serve mode, base URL of the portal used in sitemap.xml and robots.txt (default: taken from each request)") // Register the public URL flag
+}
+
+// sitemapURL is one <url> of a sitemap.
+type sitemapURL struct {
+	Loc     string `xml:"loc"`               // Absolute URL of the page or document
+	LastMod string `xml:"lastmod,omitempty"` // Revision date of the document
+}
+
+// sitemapURLSet is a sitemap.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"` // Catalog page and documents
+}
+
+// sitemapIndex lists the sitemap pages of a library too big for one file.
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"` // One per page
+}
+
+// registerSitemap adds /sitemap.xml and /robots.txt.
+func (server *libraryServer) registerSitemap(mux *http.ServeMux) {
+	mux.HandleFunc("GET /sitemap.xml", server.serveSitemap)
+	mux.HandleFunc("GET /robots.txt", serveRobots)
+}
+
+// portalBaseURL returns -public-url, or the scheme and host the request came in on.
+func portalBaseURL(request *http.Request) string {
+	if publicURL != "" {
+		return strings.TrimSuffix(publicURL, "/")
+	}
+	scheme := "http"
+	if request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https" { // Behind a TLS-terminating proxy
+		scheme = "https"
+	}
+	return scheme + "://" + request.Host
+}
+
+// serveSitemap lists the catalog page and every published document. Larger
+// libraries get a sitemap index pointing at /sitemap.xml?page=N.
+func (server *libraryServer) serveSitemap(writer http.ResponseWriter, request *http.Request) {
+	base := portalBaseURL(request)
+	entries := publishedEntries(documentManifest.currentEntries())
+	urls := []sitemapURL{{Loc: base + "/"}} // The catalog page
+	for _, entry := range entries {
+		lastMod := entry.RevisionDate // Revision of the SDS itself when the origin reported it
+		if lastMod == "" {
+			lastMod = entry.DownloadedAt.UTC().Format(time.DateOnly)
+		}
+		urls = append(urls, sitemapURL{Loc: base + "/pdf/" + documentURLPath(entry.File), LastMod: lastMod})
+	}
+	pages := (len(urls) + sitemapLimit - 1) / sitemapLimit
+	var document any = sitemapURLSet{URLs: urls}
+	if page := request.URL.Query().Get("page"); page != "" {
+		number, err := strconv.Atoi(page)
+		if err != nil || number < 1 || number > pages {
+			http.NotFound(writer, request)
+			return
+		}
+		document = sitemapURLSet{URLs: urls[(number-1)*sitemapLimit : min(number*sitemapLimit, len(urls))]}
+	} else if pages > 1 {
+		index := sitemapIndex{}
+		for number := 1; number <= pages; number++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", base, number)})
+		}
+		document = index
+	}
+	writer.Header().Set("Content-Type", "application/xml")
+	writer.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	encoder.Encode(document)
+}
+
+// documentURLPath escapes a library path for a URL, keeping the slash of
+// document type subfolders.
+func documentURLPath(file string) string {
+	segments := strings.Split(file, "/")
+	for index, segment := range segments {
+		segments[index] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// serveRobots lets crawlers index the catalog and documents, keeps them off
+// the lookup and write endpoints and points them at the sitemap.
+func serveRobots(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(writer, "User-agent: *\nAllow: /\nDisallow: /barcode/\nDisallow: /upload\nDisallow: /review/\n\nSitemap: %s/sitemap.xml\n", portalBaseURL(request))
+}
@@ -0,0 +1,218 @@
+package main // Define the main package
+
+import (
+	"bytes"          // For atomic snapshot writes
+	"crypto/ed25519" // For signing snapshots
+	"crypto/rand"    // For generating signing keys
+	"crypto/sha256"  // For chaining snapshots
+	"encoding/hex"   // For encoding keys, hashes and signatures
+	"encoding/json"  // For the snapshot format
+	"flag"           // For command-line flag parsing
+	"fmt"            // For naming snapshot files
+	"log"            // For logging messages and errors
+	"os"             // For file access and the exit code
+	"path/filepath"  // For building snapshot paths
+	"sort"           // For ordering snapshot files
+	"strings"        // For trimming key files
+	"time"           // For snapshot timestamps
+)
+
+var (
+	snapshotDir string // Folder holding the snapshot chain
+	signingKey  string // Private key file used to sign new snapshots
+	verifyKey   string // Public key file used to check snapshot signatures
+)
+
+func init() {
+	flag.StringVar(&snapshotDir, "snapshot-dir", "snapshots", "folder holding the chain of manifest snapshots")                 // Register the snapshot folder flag
+	flag.StringVar(&signingKey, "signing-key", "", "ed25519 private key file used to sign new snapshots (see snapshot keygen)") // Register the signing key flag
+	flag.StringVar(&verifyKey, "verify-key", "", "ed25519 public key file; snapshot verify then requires valid signatures")     // Register the verification key flag
+}
+
+// snapshot freezes the manifest at one point in time. Each snapshot carries
+// the hash of the previous snapshot file, so rewriting any earlier snapshot
+// breaks every later link in the chain.
+type snapshot struct {
+	Sequence  int             `json:"sequence"`   // Position in the chain, starting at 1
+	CreatedAt time.Time       `json:"created_at"` // When the snapshot was taken
+	Previous  string          `json:"previous"`   // SHA-256 of the previous snapshot file, "" for the first
+	Documents []manifestEntry `json:"documents"`  // Manifest entries at the time of the snapshot
+}
+
+// Path of the snapshot with a sequence number
+func snapshotPath(sequence int) string {
+	return filepath.Join(snapshotDir, fmt.Sprintf("%06d.json", sequence)) // Zero-padded so names sort in order
+}
+
+// Path of the detached signature of a snapshot file
+func signaturePath(snapshotFile string) string {
+	return snapshotFile + ".sig"
+}
+
+// snapshotFiles lists the snapshot files in chain order.
+func snapshotFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(snapshotDir, "[0-9]*.json")) // A missing folder means no snapshots
+	sort.Strings(files)
+	return files
+}
+
+// runSnapshotCommand handles "snapshot create", "snapshot verify" and
+// "snapshot keygen <name>".
+func runSnapshotCommand(args []string) {
+	if len(args) == 0 {
+		fatalln("usage: snapshot create | verify | keygen <name>")
+	}
+	switch args[0] {
+	case "create":
+		createSnapshot()
+	case "verify":
+		verified, problems := verifySnapshotChain()
+		if jsonOutput() {
+			printJSON(map[string]any{"verified": verified, "signatures_checked": verifyKey != "", "problems": problems})
+		}
+		if len(problems) > 0 {
+			exit(1) // Let auditors' scripts notice a broken chain
+		}
+	case "keygen":
+		if len(args) < 2 {
+			fatalln("usage: snapshot keygen <name>")
+		}
+		generateSigningKey(args[1])
+	default:
+		fatalf("unknown snapshot subcommand %q", args[0])
+	}
+}
+
+// createSnapshot appends the current manifest to the chain and signs it
+// when --signing-key is set.
+func createSnapshot() {
+	createDirectory(snapshotDir, 0755) // Make sure the folder exists
+	next := snapshot{Sequence: 1, CreatedAt: time.Now().UTC(), Documents: mustLoadManifest().sortedEntries()}
+	if files := snapshotFiles(); len(files) > 0 { // Link to the newest snapshot
+		last := files[len(files)-1]
+		content, err := os.ReadFile(last)
+		if err != nil {
+			fatalln(err)
+		}
+		var previous snapshot
+		if err := json.Unmarshal(content, &previous); err != nil {
+			fatalf("failed to read %s: %v", last, err)
+		}
+		sum := sha256.Sum256(content)
+		next.Sequence, next.Previous = previous.Sequence+1, hex.EncodeToString(sum[:])
+	}
+	content, err := json.MarshalIndent(next, "", "  ") // Encode the snapshot
+	if err != nil {
+		fatalln(err)
+	}
+	content = append(content, '\n')
+	filePath := snapshotPath(next.Sequence)
+	if _, err := writeFileAtomically(filePath, bytes.NewReader(content), int64(len(content))); err != nil {
+		fatalln(err)
+	}
+	if signingKey != "" {
+		signer, err := loadSigner(signingKey)
+		if err != nil {
+			fatalf("failed to read signing key %s: %v", signingKey, err)
+		}
+		signature := hex.EncodeToString(ed25519.Sign(signer.key, content)) + "\n"
+		if err := os.WriteFile(signaturePath(filePath), []byte(signature), 0644); err != nil {
+			fatalln(err)
+		}
+	}
+	log.Printf("created snapshot %d with %d documents: %s (signed: %t)", next.Sequence, len(next.Documents), filePath, signingKey != "")
+}
+
+// snapshotProblem is one broken link or bad signature in the chain.
+type snapshotProblem struct {
+	File    string `json:"file"`    // Snapshot file
+	Problem string `json:"problem"` // What is wrong with it
+}
+
+// verifySnapshotChain checks that the snapshots form an unbroken chain and,
+// with --verify-key, that every snapshot carries a valid signature. It
+// returns the number of snapshots checked and the problems found.
+func verifySnapshotChain() (int, []snapshotProblem) {
+	var publicKey ed25519.PublicKey // Nil when signatures are not checked
+	if verifyKey != "" {
+		key, _, err := loadVerifier(verifyKey)
+		if err != nil {
+			fatalConfig("failed to read verification key %s: %v", verifyKey, err)
+		}
+		publicKey = key
+	}
+	files := snapshotFiles()        // The chain in order
+	problems := []snapshotProblem{} // Broken links and bad signatures
+	report := func(filePath, format string, args ...any) {
+		problem := fmt.Sprintf(format, args...)
+		log.Printf("%s: %s", filePath, problem)
+		problems = append(problems, snapshotProblem{File: filePath, Problem: problem})
+	}
+	previousHash := ""    // Hash the next snapshot must point at
+	expectedSequence := 1 // Sequence the next snapshot must carry
+	for _, filePath := range files {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			report(filePath, "%v", err)
+			return len(files), problems // Nothing after an unreadable link can be trusted
+		}
+		var current snapshot
+		if err := json.Unmarshal(content, &current); err != nil {
+			report(filePath, "malformed snapshot: %v", err)
+			return len(files), problems
+		}
+		if current.Sequence != expectedSequence {
+			report(filePath, "sequence %d, expected %d (snapshot missing or inserted)", current.Sequence, expectedSequence)
+		}
+		if current.Previous != previousHash {
+			report(filePath, "previous hash %q does not match the preceding snapshot %q", current.Previous, previousHash)
+		}
+		if publicKey != nil {
+			signature, err := readKeyFile(signaturePath(filePath), ed25519.SignatureSize)
+			if err != nil {
+				report(filePath, "missing or unreadable signature: %v", err)
+			} else if !ed25519.Verify(publicKey, content, signature) {
+				report(filePath, "signature does not match")
+			}
+		}
+		sum := sha256.Sum256(content)
+		previousHash, expectedSequence = hex.EncodeToString(sum[:]), current.Sequence+1
+	}
+	log.Printf("verified %d snapshots, %d problems (signatures checked: %t)", len(files), len(problems), publicKey != nil)
+	return len(files), problems
+}
+
+// generateSigningKey writes <name>.key (private), <name>.pub (public) and
+// <name>.minisign.pub, the public key for minisign.
+func generateSigningKey(name string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader) // New key pair
+	if err != nil {
+		fatalln(err)
+	}
+	if err := os.WriteFile(name+".key", []byte(hex.EncodeToString(privateKey)+"\n"), 0600); err != nil { // Private key stays private
+		fatalln(err)
+	}
+	if err := os.WriteFile(name+".pub", []byte(hex.EncodeToString(publicKey)+"\n"), 0644); err != nil {
+		fatalln(err)
+	}
+	if err := writeMinisignPublicKey(name+".minisign.pub", publicKey); err != nil {
+		fatalln(err)
+	}
+	log.Printf("wrote %s.key, %s.pub and %s.minisign.pub; give auditors a .pub file", name, name, name)
+}
+
+// readKeyFile reads a hex-encoded key or signature of the expected size.
+func readKeyFile(path string, size int) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(decoded))
+	}
+	return decoded, nil
+}
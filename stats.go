@@ -0,0 +1,72 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For printing tables
+	"os"             // For writing to stdout
+	"text/tabwriter" // For aligned columns
+)
+
+// runListCommand prints every document in the manifest.
+func runListCommand() {
+	entries := mustLoadManifest().currentEntries() // Everything in the mirror
+	if jsonOutput() {
+		printJSON(entries)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "FILE\tSIZE\tREVISION\tTITLE")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\n", entry.File, entry.Size, entry.RevisionDate, entry.Title)
+	}
+	writer.Flush() // Print the table
+}
+
+// libraryStats summarizes the state of the local library.
+type libraryStats struct {
+	Documents        int   `json:"documents"`         // Entries in the manifest that were not pruned
+	DocumentBytes    int64 `json:"document_bytes"`    // Total size recorded in the manifest
+	FilesOnDisk      int   `json:"files_on_disk"`     // PDFs in the PDF folder
+	BytesOnDisk      int64 `json:"bytes_on_disk"`     // Size of the PDF folder
+	PendingQueries   int   `json:"pending_queries"`   // Queries not searched yet
+	PendingDownloads int   `json:"pending_downloads"` // Discovered links not stored yet
+	IndexedDocuments int   `json:"indexed_documents"` // Documents in the search index
+	IndexedTerms     int   `json:"indexed_terms"`     // Terms in the search index
+	Snapshots        int   `json:"snapshots"`         // Snapshots in the audit chain
+}
+
+// collectLibraryStats counts and sizes the library and pending work.
+func collectLibraryStats() libraryStats {
+	stats := libraryStats{
+		FilesOnDisk: len(libraryPDFs()),
+		BytesOnDisk: directorySize(outputDir),
+		Snapshots:   len(snapshotFiles()),
+	}
+	for _, entry := range mustLoadManifest().currentEntries() {
+		stats.Documents++
+		stats.DocumentBytes += entry.Size
+	}
+	queue := currentQueue() // Pending work
+	stats.PendingQueries = len(pendingTargets(queue, queueKindQuery))
+	stats.PendingDownloads = len(pendingTargets(queue, queueKindDownload))
+	if index, err := loadTermIndex(); err == nil { // A missing index counts as empty
+		stats.IndexedDocuments, stats.IndexedTerms = len(index.Documents), len(index.Terms)
+	}
+	return stats
+}
+
+// runStatsCommand prints counts and sizes of the library and pending work.
+func runStatsCommand() {
+	stats := collectLibraryStats()
+	if jsonOutput() {
+		printJSON(stats)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintf(writer, "documents\t%d\t(%d bytes)\n", stats.Documents, stats.DocumentBytes)
+	fmt.Fprintf(writer, "files on disk\t%d\t(%d bytes)\n", stats.FilesOnDisk, stats.BytesOnDisk)
+	fmt.Fprintf(writer, "pending queries\t%d\n", stats.PendingQueries)
+	fmt.Fprintf(writer, "pending downloads\t%d\n", stats.PendingDownloads)
+	fmt.Fprintf(writer, "indexed\t%d\t(%d terms)\n", stats.IndexedDocuments, stats.IndexedTerms)
+	fmt.Fprintf(writer, "snapshots\t%d\n", stats.Snapshots)
+	writer.Flush() // Print the table
+}
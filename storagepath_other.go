@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main // Define the main package
+
+import (
+	"path/filepath" // For cleaning paths
+)
+
+// storagePath normalizes a configured path. Only Windows needs long-path
+// handling, so elsewhere the path is simply cleaned.
+func storagePath(configured string) string {
+	return filepath.Clean(configured) // Remove duplicate separators and dot segments
+}
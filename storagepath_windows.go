@@ -0,0 +1,26 @@
+//go:build windows
+
+package main // Define the main package
+
+import (
+	"path/filepath" // For making paths absolute
+	"strings"       // For inspecting path prefixes
+)
+
+// storagePath turns a configured path into one Windows can open regardless of
+// length: drive-letter paths get the \\?\ prefix and UNC shares such as
+// \\server\sds$ become \\?\UNC\server\sds$. Paths that already carry a
+// prefix are returned unchanged.
+func storagePath(configured string) string {
+	if strings.HasPrefix(configured, `\\?\`) || strings.HasPrefix(configured, `\\.\`) { // Already an extended-length or device path
+		return configured
+	}
+	absolute, err := filepath.Abs(configured) // Long-path prefixes require absolute, cleaned paths
+	if err != nil {
+		return filepath.Clean(configured) // Fall back to the cleaned path
+	}
+	if strings.HasPrefix(absolute, `\\`) { // UNC share
+		return `\\?\UNC\` + strings.TrimPrefix(absolute, `\\`)
+	}
+	return `\\?\` + absolute // Drive-letter path
+}
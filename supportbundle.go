@@ -0,0 +1,232 @@
+package main // Define the main package
+
+import (
+	"archive/zip"   // For the bundle archive
+	"bytes"         // For rendering members in memory
+	"encoding/json" // For the stats member
+	"flag"          // For command-line flag parsing and the config dump
+	"fmt"           // For the config and version members
+	"io"            // For streaming the archive
+	"log"           // For logging messages and errors
+	"net/url"       // For stripping credentials from URLs
+	"os"            // For reading the logs and reports
+	"path/filepath" // For member names
+	"runtime/debug" // For the build version
+	"strings"       // For redaction
+	"time"          // For the default bundle name
+)
+
+// supportLogBytes is how much of the end of -log-file goes into a bundle.
+const supportLogBytes = 1 << 20
+
+// supportHistoryRuns is how many of the latest run summaries go into a bundle.
+const supportHistoryRuns = 20
+
+var (
+	logFile       string    // Where log output is also appended ("" = stderr only)
+	logFileOutput io.Writer // Open -log-file, nil when there is none
+)
+
+func init() {
+	flag.StringVar(&logFile, "log-file", "", "also append log output to this file, so support-bundle can include recent logs") // Register the log file flag
+}
+
+// openLogFile mirrors the log into -log-file.
+func openLogFile() {
+	if logFile == "" { // Logging to a file is opt-in
+		return
+	}
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("failed to open log file %s: %v", logFile, err)
+		return
+	}
+	logFileOutput = file   // The file gets everything
+	routeLog(consoleLog()) // Still visible on the terminal
+}
+
+// routeLog sends the log to console and to -log-file when one is open.
+func routeLog(console io.Writer) {
+	if logFileOutput == nil {
+		log.SetOutput(console)
+		return
+	}
+	log.SetOutput(io.MultiWriter(console, logFileOutput))
+}
+
+// runSupportBundleCommand writes a zip with what a bug report needs: the
+// effective configuration with secrets removed, recent logs, the last run's
+// reports, doctor output and library stats.
+func runSupportBundleCommand(args []string) {
+	target := "support-bundle-" + time.Now().Format("20060102-150405") + ".zip" // Default name
+	if len(args) > 0 {
+		target = args[0]
+	}
+	secrets := knownSecrets() // Scrubbed from every text member
+	members := map[string][]byte{
+		"version.txt": versionInfo(),
+		"config.txt":  redact(sanitizedConfig(), secrets),
+	}
+	var doctor bytes.Buffer
+	writeDoctorTable(&doctor, runDoctorChecks())
+	members["doctor.txt"] = redact(doctor.Bytes(), secrets)
+	if stats, err := json.MarshalIndent(collectLibraryStats(), "", "  "); err == nil {
+		members["stats.json"] = append(stats, '\n')
+	}
+	if logFile != "" {
+		if tail, err := fileTail(logFile, supportLogBytes); err != nil {
+			log.Printf("support bundle: skipping log %s: %v", logFile, err)
+		} else {
+			members["logs/"+filepath.Base(logFile)] = redact(tail, secrets)
+		}
+	}
+	for _, report := range []string{failuresFile, sweepStatePath(), originHealthPath()} { // Reports of recent runs
+		if content, err := os.ReadFile(report); err == nil {
+			members["reports/"+filepath.Base(report)] = redact(content, secrets)
+		}
+	}
+	history := runSummaryFiles()
+	for _, summary := range history[max(len(history)-supportHistoryRuns, 0):] { // The most recent runs
+		if content, err := os.ReadFile(summary); err == nil {
+			members["reports/history/"+filepath.Base(summary)] = content
+		}
+	}
+	reader, writer := io.Pipe() // Stream the archive into the atomic writer
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(writeSupportArchive(writer, members))
+	}()
+	written, err := writeFileAtomically(target, reader, -1)
+	if err != nil {
+		log.Printf("failed to write support bundle %s: %v", target, err)
+		exit(1)
+	}
+	log.Printf("wrote support bundle %s (%d members, %d bytes); check it before attaching it to a bug report", target, len(members), written)
+}
+
+// writeSupportArchive zips the members.
+func writeSupportArchive(output io.Writer, members map[string][]byte) error {
+	archive := zip.NewWriter(output)
+	for name, content := range members {
+		member, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := member.Write(content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// versionInfo describes the build and the platform.
+func versionInfo() []byte {
+	var info bytes.Buffer
+	if build, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&info, "module %s %s\n", build.Main.Path, build.Main.Version)
+		fmt.Fprintf(&info, "go %s\n", build.GoVersion)
+		for _, setting := range build.Settings {
+			if strings.HasPrefix(setting.Key, "vcs.") || setting.Key == "GOOS" || setting.Key == "GOARCH" {
+				fmt.Fprintf(&info, "%s %s\n", setting.Key, setting.Value)
+			}
+		}
+	}
+	return info.Bytes()
+}
+
+// sanitizedConfig lists every flag with its effective value. Secret flags
+// are masked and URLs lose their credentials and query strings.
+func sanitizedConfig() []byte {
+	var config bytes.Buffer
+	flag.VisitAll(func(option *flag.Flag) {
+		value := option.Value.String()
+		switch {
+		case value == "":
+		case sensitiveFlag(option.Name):
+			value = "[redacted]"
+		case strings.Contains(value, "://"):
+			value = sanitizeURL(value)
+		}
+		fmt.Fprintf(&config, "%s=%s\n", option.Name, value)
+	})
+	return config.Bytes()
+}
+
+// sensitiveFlag reports whether a flag's value is itself a secret. Token
+// flags name files, which are safe to show; their contents are not.
+func sensitiveFlag(name string) bool {
+	return strings.Contains(name, "webhook") || strings.Contains(name, "secret") || strings.Contains(name, "password")
+}
+
+// sanitizeURL drops the user info and query of a URL.
+func sanitizeURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "[redacted]"
+	}
+	parsed.User, parsed.RawQuery = nil, ""
+	return parsed.String()
+}
+
+// knownSecrets collects the values of secret flags and the bearer tokens
+// from the token files, so they can be scrubbed from logs and reports.
+func knownSecrets() []string {
+	var secrets []string
+	flag.VisitAll(func(option *flag.Flag) {
+		if value := option.Value.String(); value != "" && sensitiveFlag(option.Name) {
+			secrets = append(secrets, value)
+		}
+	})
+	for _, tokenFile := range []string{uploadTokenFile, reviewTokenFile, apiTokenFile} {
+		if tokenFile == "" { // Endpoint not enabled
+			continue
+		}
+		if content, err := os.ReadFile(tokenFile); err == nil && strings.TrimSpace(string(content)) != "" {
+			secrets = append(secrets, strings.TrimSpace(string(content)))
+		}
+	}
+	if fields, err := loginFields(); err == nil { // Passwords posted to -login-url
+		for _, values := range fields {
+			for _, value := range values {
+				if value != "" {
+					secrets = append(secrets, value)
+				}
+			}
+		}
+	}
+	return secrets
+}
+
+// redact replaces every secret in content.
+func redact(content []byte, secrets []string) []byte {
+	text := string(content)
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "[redacted]")
+	}
+	return []byte(text)
+}
+
+// fileTail returns up to limit bytes from the end of path, starting at a
+// line boundary.
+func fileTail(path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-limit, 0)
+	content, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 { // Drop the partial first line
+		if newline := bytes.IndexByte(content, '\n'); newline >= 0 {
+			content = content[newline+1:]
+		}
+	}
+	return content, nil
+}
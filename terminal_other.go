@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main // Define the main package
+
+// enableTerminalEscapes does nothing: Unix terminals interpret ANSI escapes.
+func enableTerminalEscapes() error {
+	return nil
+}
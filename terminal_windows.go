@@ -0,0 +1,28 @@
+//go:build windows
+
+package main // Define the main package
+
+import (
+	"os"      // For the stderr handle
+	"syscall" // For calling into kernel32
+)
+
+// enableVirtualTerminalProcessing makes the console interpret ANSI escapes.
+const enableVirtualTerminalProcessing = 0x0004
+
+// setConsoleMode is kernel32's SetConsoleMode.
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableTerminalEscapes switches the console behind stderr to ANSI escapes,
+// which the -tui dashboard draws with. Consoles older than Windows 10 refuse.
+func enableTerminalEscapes() error {
+	handle := syscall.Handle(os.Stderr.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return err
+	}
+	if result, _, callErr := setConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing)); result == 0 {
+		return callErr
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main // Define the main package
+
+import (
+	"flag"    // For command-line flag parsing
+	"fmt"     // For parse errors
+	"io"      // For wrapping response bodies
+	"strconv" // For parsing rates
+	"strings" // For splitting units
+	"sync"    // For sharing the global limiter
+	"time"    // For pacing reads
+)
+
+var (
+	maxBandwidth         int64        // Global cap in bytes per second across all transfers (0 = unlimited)
+	maxDownloadBandwidth int64        // Cap in bytes per second for each individual download (0 = unlimited)
+	globalLimiter        *rateLimiter // Shared by every response body once configured
+	globalLimiterOnce    sync.Once    // Creates globalLimiter lazily after flag parsing
+)
+
+func init() {
+	flag.Func("max-bandwidth", "global bandwidth cap across all transfers, e.g. 2MB/s (default unlimited)", func(value string) (err error) {
+		maxBandwidth, err = parseByteRate(value) // Register the global bandwidth flag
+		return err
+	})
+	flag.Func("max-download-bandwidth", "bandwidth cap for each individual PDF download, e.g. 500KB/s (default unlimited)", func(value string) (err error) {
+		maxDownloadBandwidth, err = parseByteRate(value) // Register the per-download bandwidth flag
+		return err
+	})
+}
+
+// byteUnits maps size suffixes to their multipliers.
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+}
+
+// parseByteRate parses rates such as "2MB/s", "512KiB" or "100000".
+func parseByteRate(value string) (int64, error) {
+	trimmed := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "/s") // The /s suffix is optional
+	split := strings.IndexFunc(trimmed, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := trimmed, "" // Numeric part and unit suffix
+	if split >= 0 {
+		number, unit = trimmed[:split], strings.TrimSpace(trimmed[split:])
+	}
+	amount, err := strconv.ParseFloat(number, 64)
+	multiplier, known := byteUnits[unit]
+	if err != nil || !known || amount < 0 {
+		return 0, fmt.Errorf("invalid rate %q (examples: 2MB/s, 512KiB/s)", value)
+	}
+	return int64(amount * multiplier), nil
+}
+
+// rateLimiter is a token bucket that paces byte transfers.
+type rateLimiter struct {
+	mutex          sync.Mutex // Guards the bucket
+	bytesPerSecond float64    // Refill rate
+	tokens         float64    // Bytes that may be transferred right now (may go negative)
+	last           time.Time  // Last refill
+}
+
+// newRateLimiter creates a limiter allowing up to one second of burst.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{bytesPerSecond: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// wait charges n bytes to the bucket and sleeps until they are covered.
+func (limiter *rateLimiter) wait(n int) {
+	limiter.mutex.Lock()
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.bytesPerSecond // Refill since last use
+	if limiter.tokens > limiter.bytesPerSecond {                               // Cap the burst at one second
+		limiter.tokens = limiter.bytesPerSecond
+	}
+	limiter.last = now
+	limiter.tokens -= float64(n) // Spend the bytes, going into debt if needed
+	debt := -limiter.tokens      // Bytes not yet covered
+	limiter.mutex.Unlock()
+	if debt > 0 {
+		time.Sleep(time.Duration(debt / limiter.bytesPerSecond * float64(time.Second))) // Wait until the debt is repaid
+	}
+}
+
+// throttledReader paces reads through one or more limiters.
+type throttledReader struct {
+	reader   io.Reader      // Underlying body
+	limiters []*rateLimiter // Every limiter the bytes are charged to
+}
+
+// Read reads at most a small chunk and waits for every limiter to allow it.
+func (throttled throttledReader) Read(buffer []byte) (int, error) {
+	if len(buffer) > 32*1024 { // Small chunks keep the pacing smooth
+		buffer = buffer[:32*1024]
+	}
+	readBytes, err := throttled.reader.Read(buffer)
+	for _, limiter := range throttled.limiters {
+		limiter.wait(readBytes) // Pause as long as the slowest limiter requires
+	}
+	return readBytes, err
+}
+
+// throttleBody wraps a response body with the global limiter and, for PDF
+// downloads, a fresh per-download limiter.
+func throttleBody(body io.Reader, isDownload bool) io.Reader {
+	globalLimiterOnce.Do(func() {
+		if maxBandwidth > 0 {
+			globalLimiter = newRateLimiter(maxBandwidth)
+		}
+	})
+	var limiters []*rateLimiter // Limiters that apply to this body
+	if globalLimiter != nil {
+		limiters = append(limiters, globalLimiter)
+	}
+	if isDownload && maxDownloadBandwidth > 0 {
+		limiters = append(limiters, newRateLimiter(maxDownloadBandwidth))
+	}
+	if len(limiters) == 0 { // Unthrottled
+		return body
+	}
+	return throttledReader{reader: body, limiters: limiters}
+}
@@ -0,0 +1,98 @@
+package main // Define the main package
+
+import (
+	"fmt"            // For the traffic table
+	"io"             // For counting body bytes
+	"log"            // For printing the table
+	"sort"           // For ordering hosts by bytes
+	"strings"        // For collecting the table
+	"sync"           // For guarding the host table
+	"sync/atomic"    // For counters shared between workers
+	"text/tabwriter" // For aligned columns
+)
+
+var (
+	hostTraffic      = make(map[string]*trafficCounter) // Host → transfer of this run
+	hostTrafficMutex sync.Mutex                         // Guards hostTraffic
+)
+
+// trafficCounter counts the requests and response bytes to one host.
+type trafficCounter struct {
+	requests    atomic.Int64 // Attempts sent, retries included
+	errors      atomic.Int64 // Attempts that got no response
+	rateLimited atomic.Int64 // Attempts answered with 429 or 503
+	bytes       atomic.Int64 // Response body bytes read
+}
+
+// hostTrafficReport is the traffic to one host in the run report.
+type hostTrafficReport struct {
+	Host        string `json:"host"`         // Upstream host
+	Requests    int64  `json:"requests"`     // Attempts sent, retries included
+	Errors      int64  `json:"errors"`       // Attempts that got no response
+	RateLimited int64  `json:"rate_limited"` // Attempts answered with 429 or 503
+	Bytes       int64  `json:"bytes"`        // Response body bytes read
+}
+
+// trafficFor returns the counter of host, creating it on first use.
+func trafficFor(host string) *trafficCounter {
+	hostTrafficMutex.Lock()
+	defer hostTrafficMutex.Unlock()
+	counter, known := hostTraffic[host]
+	if !known {
+		counter = &trafficCounter{}
+		hostTraffic[host] = counter
+	}
+	return counter
+}
+
+// countingBody adds the bytes read from a response body to its host.
+type countingBody struct {
+	io.ReadCloser                 // Response body
+	counter       *trafficCounter // Host the body came from
+}
+
+// Read reads from the body and counts what was read.
+func (body countingBody) Read(data []byte) (int, error) {
+	read, err := body.ReadCloser.Read(data)
+	body.counter.bytes.Add(int64(read))
+	return read, err
+}
+
+// trafficByHost snapshots the counters, biggest transfer first.
+func trafficByHost() []hostTrafficReport {
+	hostTrafficMutex.Lock()
+	defer hostTrafficMutex.Unlock()
+	hosts := make([]hostTrafficReport, 0, len(hostTraffic))
+	for host, counter := range hostTraffic {
+		hosts = append(hosts, hostTrafficReport{Host: host, Requests: counter.requests.Load(), Errors: counter.errors.Load(), RateLimited: counter.rateLimited.Load(), Bytes: counter.bytes.Load()})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Bytes != hosts[j].Bytes {
+			return hosts[i].Bytes > hosts[j].Bytes
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// resetTraffic starts counting from zero, for the next daemon run.
+func resetTraffic() {
+	hostTrafficMutex.Lock()
+	hostTraffic = make(map[string]*trafficCounter)
+	hostTrafficMutex.Unlock()
+}
+
+// logTraffic prints where the run's transfer went.
+func logTraffic(hosts []hostTrafficReport) {
+	if len(hosts) == 0 { // Nothing was fetched
+		return
+	}
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "HOST\tREQUESTS\tERRORS\tRATE LIMITED\tBYTES")
+	for _, host := range hosts {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\n", host.Host, host.Requests, host.Errors, host.RateLimited, host.Bytes)
+	}
+	writer.Flush()
+	log.Printf("traffic by host:\n%s", table.String())
+}
@@ -0,0 +1,387 @@
+package main // Define the main package
+
+import (
+	"context"     // For skipping transfers
+	"flag"        // For command-line flag parsing
+	"fmt"         // For drawing the dashboard
+	"io"          // For counting transfer bytes
+	"log"         // For logging operator actions
+	"os"          // For the terminal
+	"os/signal"   // For stopping gracefully on Ctrl+C
+	"sort"        // For ordering transfers by age
+	"strconv"     // For the size fallback
+	"strings"     // For building the frame
+	"sync"        // For guarding the dashboard state across workers
+	"sync/atomic" // For the queue counters and the pause flag
+	"syscall"     // For SIGTERM
+	"time"        // For redrawing and elapsed times
+
+	"golang.org/x/term" // For raw key input and the terminal size on every platform
+)
+
+// tuiCommands are the commands -tui can watch.
+var tuiCommands = map[string]bool{"run": true, "discover": true, "download": true, "mirror": true}
+
+var (
+	tuiMode         bool                 // Show the live dashboard instead of the scrolling log
+	tuiDone         chan struct{}        // Closed to stop the dashboard, nil when it is not running
+	tuiStopped      chan struct{}        // Closed once the terminal is restored
+	tuiSignals      chan os.Signal       // Ctrl+C and SIGTERM, also fed by Ctrl+C pressed in raw mode
+	tuiLogTail      = &logTail{max: 200} // Recent log entries shown by the dashboard
+	queriesQueued   atomic.Int64         // Searches handed to the workers and not started yet
+	downloadsQueued atomic.Int64         // Downloads handed to the workers and not started yet
+	pausedWork      atomic.Bool          // No new searches or downloads start while set
+	pauseMutex      sync.Mutex           // Guards pauseChanged
+	pauseChanged    = sync.NewCond(&pauseMutex)
+	transfers       = map[*transfer]bool{} // Searches and downloads in flight
+	transfersMutex  sync.Mutex             // Guards transfers
+)
+
+func init() {
+	flag.BoolVar(&tuiMode, "tui", false, "show a live dashboard of the queues, active transfers, failures and throughput for run, discover, download and mirror; p pauses, s or 1-9 skip a download, q stops") // Register the dashboard flag
+}
+
+// transfer is a search or download in flight.
+type transfer struct {
+	kind    string             // failureKindSearch or failureKindDownload
+	target  string             // Query or URL
+	started time.Time          // When it started
+	bytes   atomic.Int64       // Body bytes received so far
+	cancel  context.CancelFunc // Abandons it
+}
+
+// startTransfer registers a search or download so the dashboard can show
+// and skip it; the returned context is cancelled when it is skipped.
+func startTransfer(parent context.Context, kind, target string) (*transfer, context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	started := &transfer{kind: kind, target: target, started: time.Now(), cancel: cancel}
+	transfersMutex.Lock()
+	transfers[started] = true
+	transfersMutex.Unlock()
+	return started, ctx
+}
+
+// finish unregisters the transfer.
+func (finished *transfer) finish() {
+	finished.cancel()
+	transfersMutex.Lock()
+	delete(transfers, finished)
+	transfersMutex.Unlock()
+}
+
+// counted wraps a body so the bytes it delivers are shown for the transfer.
+func (counting *transfer) counted(body io.Reader) io.Reader {
+	return transferReader{reader: body, transfer: counting}
+}
+
+// transferReader counts the bytes read for a transfer.
+type transferReader struct {
+	reader   io.Reader // Underlying body
+	transfer *transfer // Where the bytes are counted
+}
+
+// Read reads from the body and counts the bytes.
+func (counting transferReader) Read(buffer []byte) (int, error) {
+	readBytes, err := counting.reader.Read(buffer)
+	counting.transfer.bytes.Add(int64(readBytes))
+	return readBytes, err
+}
+
+// activeTransfers returns the transfers in flight, oldest first.
+func activeTransfers() []*transfer {
+	transfersMutex.Lock()
+	active := make([]*transfer, 0, len(transfers))
+	for inFlight := range transfers {
+		active = append(active, inFlight)
+	}
+	transfersMutex.Unlock()
+	sort.Slice(active, func(i, j int) bool { return active[i].started.Before(active[j].started) })
+	return active
+}
+
+// waitWhilePaused holds a worker back while the dashboard paused the run;
+// work already in flight carries on.
+func waitWhilePaused() {
+	if !pausedWork.Load() {
+		return
+	}
+	pauseMutex.Lock()
+	for pausedWork.Load() && !stopRequested.Load() {
+		pauseChanged.Wait()
+	}
+	pauseMutex.Unlock()
+}
+
+// Pause or resume starting new work
+func setPaused(paused bool) {
+	pauseMutex.Lock()
+	pausedWork.Store(paused)
+	pauseChanged.Broadcast()
+	pauseMutex.Unlock()
+}
+
+// logTail keeps the last log entries for the dashboard. The log package
+// writes each entry with a single Write.
+type logTail struct {
+	mutex sync.Mutex // Guards lines
+	lines []string   // Oldest first
+	max   int        // Entries kept
+}
+
+// Write keeps one log entry.
+func (tail *logTail) Write(entry []byte) (int, error) {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+	tail.lines = append(tail.lines, strings.TrimRight(string(entry), "\n"))
+	if len(tail.lines) > tail.max {
+		tail.lines = tail.lines[len(tail.lines)-tail.max:]
+	}
+	return len(entry), nil
+}
+
+// last returns up to count of the newest entries, oldest first.
+func (tail *logTail) last(count int) []string {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+	if count > len(tail.lines) {
+		count = len(tail.lines)
+	}
+	return append([]string(nil), tail.lines[len(tail.lines)-count:]...)
+}
+
+// startTUI replaces the scrolling log with the -tui dashboard on stderr.
+// The log still goes to -log-file in full.
+func startTUI(command string) {
+	if !tuiMode {
+		return
+	}
+	if !tuiCommands[command] {
+		fatalConfig("-tui only works with run, discover, download and mirror")
+	}
+	if quietOutput {
+		fatalConfig("-tui and -quiet cannot be combined")
+	}
+	if preflight && !assumeYes {
+		fatalConfig("-tui cannot show the -preflight prompt; add -yes")
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fatalConfig("-tui needs a terminal on stderr")
+	}
+	if err := enableTerminalEscapes(); err != nil {
+		fatalConfig("-tui needs a terminal that draws ANSI escapes (Windows 10 or later): %v", err)
+	}
+	restore := func() {} // Leaves the terminal as it was
+	if stdinIsTerminal() {
+		restore = rawTerminal()
+		go readTUIKeys()
+	}
+	tuiDone, tuiStopped = make(chan struct{}), make(chan struct{})
+	routeLog(tuiLogTail)
+	fmt.Fprint(os.Stderr, "\x1b[?1049h\x1b[?25l") // Alternate screen without a cursor
+	tuiSignals = make(chan os.Signal, 2)
+	signal.Notify(tuiSignals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(tuiStopped)
+		started := time.Now()
+		width, height := terminalSize()
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		meter := throughputMeter{last: bytesUsed.Load(), at: started}
+		for {
+			select {
+			case <-tuiDone:
+				fmt.Fprint(os.Stderr, "\x1b[?25h\x1b[?1049l") // Back to the normal screen
+				restore()
+				return
+			case <-tuiSignals:
+				if stopRequested.Load() { // Second Ctrl+C
+					fmt.Fprint(os.Stderr, "\x1b[?25h\x1b[?1049l")
+					restore()
+					fatalln("second signal, exiting without saving")
+				}
+				requestTUIStop()
+			case <-ticker.C:
+				width, height = terminalSize() // Follow resizes
+				meter.sample()
+				fmt.Fprint(os.Stderr, drawTUI(command, started, meter, width, height))
+			}
+		}
+	}()
+}
+
+// stopTUI restores the terminal and the scrolling log before the run report.
+func stopTUI() {
+	if tuiDone == nil {
+		return
+	}
+	close(tuiDone)
+	<-tuiStopped
+	tuiDone = nil
+	routeLog(consoleLog())
+}
+
+// requestTUIStop stops the run after the in-flight transfers, like a daemon
+// stop: the remaining work is left for the next run.
+func requestTUIStop() {
+	if stopRequested.Swap(true) {
+		return
+	}
+	log.Println("stopping after the in-flight requests; the rest stays queued for the next run")
+	stopWork()
+	setPaused(false) // Let paused workers see the stop
+}
+
+// readTUIKeys acts on the dashboard keys.
+func readTUIKeys() {
+	key := make([]byte, 1)
+	for {
+		if count, err := os.Stdin.Read(key); err != nil || count == 0 {
+			return
+		}
+		switch key[0] {
+		case 'p', 'P':
+			setPaused(!pausedWork.Load())
+			if pausedWork.Load() {
+				log.Println("paused: in-flight transfers finish, nothing new starts until p is pressed again")
+			} else {
+				log.Println("resumed")
+			}
+		case 's', 'S':
+			for _, active := range activeTransfers() { // The oldest download is the likeliest stuck
+				if active.kind == failureKindDownload {
+					skipTransfer(active)
+					break
+				}
+			}
+		case 'q', 'Q':
+			requestTUIStop()
+		case 3: // Ctrl+C, which raw mode delivers as a key instead of a signal
+			select {
+			case tuiSignals <- os.Interrupt:
+			default:
+			}
+		default:
+			if key[0] >= '1' && key[0] <= '9' {
+				if active := activeTransfers(); int(key[0]-'1') < len(active) {
+					skipTransfer(active[key[0]-'1'])
+				}
+			}
+		}
+	}
+}
+
+// Abandon a transfer; it fails and is retried by the next run
+func skipTransfer(skipped *transfer) {
+	log.Printf("skipping %s %s at the operator's request", skipped.kind, skipped.target)
+	skipped.cancel()
+}
+
+// throughputMeter follows the rate at which response bytes arrive.
+type throughputMeter struct {
+	last int64     // bytesUsed at the last sample
+	at   time.Time // When it was taken
+	rate float64   // Smoothed bytes per second
+}
+
+// sample updates the smoothed rate.
+func (meter *throughputMeter) sample() {
+	now, total := time.Now(), bytesUsed.Load()
+	if elapsed := now.Sub(meter.at).Seconds(); elapsed > 0 {
+		meter.rate = 0.7*meter.rate + 0.3*float64(total-meter.last)/elapsed
+	}
+	meter.last, meter.at = total, now
+}
+
+// drawTUI renders one frame of the dashboard, cut to the terminal size.
+func drawTUI(command string, started time.Time, meter throughputMeter, width, height int) string {
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	state := "running"
+	switch {
+	case stopRequested.Load():
+		state = "stopping"
+	case pausedWork.Load():
+		state = "PAUSED"
+	}
+	active := activeTransfers()
+	searching := 0 // Searches among the active transfers
+	for _, inFlight := range active {
+		if inFlight.kind == failureKindSearch {
+			searching++
+		}
+	}
+	failuresMutex.Lock()
+	failed := len(failures)
+	recent := failures[max(len(failures)-5, 0):]
+	recent = append([]failure(nil), recent...)
+	failuresMutex.Unlock()
+	elapsed := time.Since(started)
+	add("%s: %s, %s elapsed    p pause/resume  s skip oldest download  1-9 skip transfer  q stop", command, state, elapsed.Truncate(time.Second))
+	add("searches   %d done, %d queued, %d in flight", queriesSearched.Load(), max(queriesQueued.Load(), 0), searching)
+	add("downloads  %d saved (%s), %d skipped, %d queued, %d in flight", documentsSaved.Load(), formatByteSize(documentBytesSaved.Load()), documentsSkipped.Load(), max(downloadsQueued.Load(), 0), len(active)-searching)
+	add("links      %d discovered, %d failures", linksDiscovered.Load(), failed)
+	add("throughput %s/s now, %s/s average, %s received", formatByteSize(int64(meter.rate)), formatByteSize(int64(float64(bytesUsed.Load())/max(elapsed.Seconds(), 1))), formatByteSize(bytesUsed.Load()))
+	add("")
+	add("ACTIVE")
+	for index, inFlight := range active {
+		if index == 9 {
+			add("    ... and %d more", len(active)-9)
+			break
+		}
+		add(" %d  %-8s %8s  %10s  %s", index+1, inFlight.kind, time.Since(inFlight.started).Truncate(time.Second), formatByteSize(inFlight.bytes.Load()), inFlight.target)
+	}
+	add("")
+	add("RECENT FAILURES")
+	for _, entry := range recent {
+		add("    %s %s: %s (%s)", entry.Kind, entry.Target, entry.Detail, entry.Reason)
+	}
+	add("")
+	add("LOG")
+	for _, entry := range tuiLogTail.last(max(height-len(lines)-1, 0)) {
+		add("    %s", entry)
+	}
+	var frame strings.Builder
+	frame.WriteString("\x1b[H") // Draw over the previous frame
+	for index, line := range lines {
+		if index == height-1 {
+			break
+		}
+		if runes := []rune(line); len(runes) > width {
+			line = string(runes[:width])
+		}
+		frame.WriteString(line + "\x1b[K\r\n") // Raw mode does not return the carriage on a newline
+	}
+	frame.WriteString("\x1b[J") // Clear what the previous frame left below
+	return frame.String()
+}
+
+// rawTerminal delivers keys without Enter and without echo, on Unix
+// terminals and the Windows console alike, and returns a function that
+// restores the terminal. When the terminal refuses, keys take effect on
+// Enter.
+func rawTerminal() func() {
+	saved, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Printf("-tui: keys take effect on Enter: %v", err)
+		return func() {}
+	}
+	return func() { term.Restore(int(os.Stdin.Fd()), saved) }
+}
+
+// terminalSize returns the columns and rows of the terminal on stderr,
+// falling back to $COLUMNS and $LINES, then 80x24.
+func terminalSize() (int, int) {
+	if columns, rows, err := term.GetSize(int(os.Stderr.Fd())); err == nil && columns > 0 && rows > 0 {
+		return columns, rows
+	}
+	columns, rows := 80, 24
+	if value, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && value > 0 {
+		columns = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("LINES")); err == nil && value > 0 {
+		rows = value
+	}
+	return columns, rows
+}
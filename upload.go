@@ -0,0 +1,130 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For buffering uploads
+	"crypto/sha256" // For hashing uploads
+	"crypto/subtle" // For comparing tokens in constant time
+	"encoding/hex"  // For encoding hashes
+	"encoding/json" // For the upload response
+	"flag"          // For command-line flag parsing
+	"io"            // For reading the uploaded file
+	"log"           // For logging messages and errors
+	"net/http"      // For the upload handler
+	"os"            // For reading the token file
+	"path/filepath" // For building local paths
+	"strings"       // For checking the token header
+	"time"          // For upload timestamps
+)
+
+// manualSource marks manifest entries uploaded by hand instead of crawled.
+const manualSource = "manual"
+
+var (
+	uploadTokenFile string // File holding the bearer token that authorizes uploads
+	maxUploadBytes  int64  // Largest accepted upload
+)
+
+func init() {
+	flag.StringVar(&uploadTokenFile, "upload-token-file", "", "in serve mode, enable POST /upload for clients presenting the bearer token stored in this file") // Register the upload token flag
+	flag.Int64Var(&maxUploadBytes, "max-upload-bytes", 50<<20, "largest PDF accepted by POST /upload")                                                          // Register the upload size flag
+}
+
+// loadTokenFile reads a bearer token, or returns "" when no file is set and
+// the endpoint it guards stays off.
+func loadTokenFile(path string) string {
+	if path == "" { // The endpoint is opt-in
+		return ""
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fatalf("failed to read token %s: %v", path, err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		fatalf("token file %s is empty", path)
+	}
+	return token
+}
+
+// uploadPDF accepts a vendor-supplied SDS for a product that is not
+// available online. The request is multipart with a "product" field and a
+// "file" part, authorized with "Authorization: Bearer <token>". The PDF goes
+// through the same checks as a download and is recorded in the manifest
+// with source "manual".
+func (server *libraryServer) uploadPDF(writer http.ResponseWriter, request *http.Request) {
+	presented := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ") // Token sent by the client
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(server.uploadToken)) != 1 {
+		http.Error(writer, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	request.Body = http.MaxBytesReader(writer, request.Body, maxUploadBytes+1<<20) // Room for the multipart framing
+	product := strings.TrimSpace(request.FormValue("product"))                     // Product the SDS belongs to
+	file, header, err := request.FormFile("file")
+	if err != nil || product == "" {
+		http.Error(writer, "expected multipart fields 'product' and 'file'", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	var buffer bytes.Buffer
+	written, err := io.Copy(&buffer, io.LimitReader(file, maxUploadBytes+1))
+	switch {
+	case err != nil:
+		http.Error(writer, "failed to read upload", http.StatusBadRequest)
+		return
+	case written == 0:
+		http.Error(writer, "empty file", http.StatusBadRequest)
+		return
+	case written > maxUploadBytes:
+		http.Error(writer, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	case !bytes.HasPrefix(buffer.Bytes(), []byte("%PDF-")):
+		http.Error(writer, "not a PDF", http.StatusUnsupportedMediaType)
+		return
+	case !lockPass("serve"):
+		http.Error(writer, "another instance is writing the data folders; try again later", http.StatusServiceUnavailable)
+		return
+	}
+	defer unlockPass()
+	if !reserveQuota(written) { // Same quota as crawled documents
+		http.Error(writer, "storage quota reached", http.StatusInsufficientStorage)
+		return
+	}
+	defer releaseQuota(written)
+	sum := sha256.Sum256(buffer.Bytes())
+	hash := hex.EncodeToString(sum[:])
+	filename := sanitizeFilename(product) + "-manual-" + hash[:8] + ".pdf" // Never collides with crawled names
+	filePath := filepath.Join(outputDir, filename)
+	if _, err := writeFileAtomically(filePath, &buffer, written); err != nil {
+		log.Printf("failed to store upload %s: %v", filePath, err)
+		http.Error(writer, "failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := storeObject(filePath, hash); err != nil { // Same layout as crawled documents
+		log.Printf("failed to add %s to the object store: %v", filePath, err)
+	}
+	language := "" // Uploads carry no URL, so only the text can tell
+	if text, err := extractPDFText(filePath); err == nil {
+		language = detectTextLanguage(text)
+	}
+	entry := manifestEntry{
+		URL:          manualSource + ":" + hash, // Manifest entries are keyed by URL
+		Title:        product,
+		Language:     language,
+		File:         filename,
+		SHA256:       hash,
+		Size:         written,
+		Source:       manualSource,
+		DownloadedAt: time.Now().UTC(),
+	}
+	documentManifest.record(entry)
+	documentsSaved.Add(1)           // Count the stored document
+	documentBytesSaved.Add(written) // Count the stored bytes towards the quota
+	if err := documentManifest.save(manifestFile); err != nil {
+		log.Printf("failed to save manifest %s: %v", manifestFile, err)
+	}
+	writeChecksums(documentManifest) // Uploads are published like downloads
+	log.Printf("upload: stored %s (%d bytes) for %q from %s", filePath, written, product, header.Filename)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(entry)
+}
@@ -0,0 +1,49 @@
+package main // Define the main package
+
+import (
+	"bufio"   // For reading the list
+	"flag"    // For command-line flag parsing
+	"net/url" // For validating the links
+	"os"      // For opening the list
+	"strings" // For parsing lines
+)
+
+var urlListFile string // PDF links to download instead of discovering them
+
+func init() {
+	flag.StringVar(&urlListFile, "url-list", "", "skip searching and download the PDF links in this file: one URL per line, optionally followed by a tab and the product name, # comments") // Register the URL list flag
+}
+
+// loadURLList returns the links of --url-list and remembers their titles
+// like those of search results. A line that is not an http(s) URL is a
+// configuration error, so a wrong file is not half downloaded.
+func loadURLList() []string {
+	file, err := os.Open(urlListFile)
+	if err != nil {
+		fatalConfig("failed to read URL list %s: %v", urlListFile, err)
+	}
+	defer file.Close()
+	var links []documentLink
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		link, title, _ := strings.Cut(text, "\t")
+		parsed, err := url.Parse(strings.TrimSpace(link))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			fatalConfig("%s:%d: %q is not an http(s) URL", urlListFile, line, link)
+		}
+		links = append(links, documentLink{URL: canonicalURL(parsed.String()), Title: strings.TrimSpace(title)})
+	}
+	if err := scanner.Err(); err != nil {
+		fatalConfig("failed to read URL list %s: %v", urlListFile, err)
+	}
+	rememberLinkTitles(links) // Named like search results in the manifest
+	var targets []string
+	for _, link := range links {
+		targets = append(targets, link.URL)
+	}
+	return targets
+}
@@ -0,0 +1,108 @@
+package main // Define the main package
+
+import (
+	"flag"     // For command-line flag parsing
+	"fmt"      // For the quiet summary
+	"io"       // For filtering the console log
+	"log"      // For logging request traces
+	"net/http" // For tracing requests
+	"os"       // For writing to stderr
+	"regexp"   // For recognizing error lines
+	"time"     // For request timing
+)
+
+// errorLogPattern matches the log lines quiet mode still shows: failures,
+// usage and configuration errors, and the texts of operating system errors.
+var errorLogPattern = regexp.MustCompile(`fail|error|warning|usage:|unknown|invalid|required|needs -|not set|cannot|unable|denied|refus|no such|no space|read-only|missing|corrupt|mismatch|exhausted`)
+
+var (
+	quietOutput   bool // Log only errors and print a one-line summary at the end
+	verboseOutput bool // Log every request with its status, timing and redirects
+)
+
+func init() {
+	flag.BoolVar(&quietOutput, "quiet", false, "log only errors and finish with a one-line key=value summary on stdout, e.g. for cron (-log-file still gets everything)") // Register the quiet flag
+	flag.BoolVar(&verboseOutput, "verbose", false, "also log every request with its status, protocol, timing and redirects, for debugging")                               // Register the verbose flag
+}
+
+// quietWriter passes on only the log entries that look like errors. The
+// log package writes each entry with a single Write.
+type quietWriter struct {
+	writer io.Writer // Where the errors go
+}
+
+// Write forwards entry when it matches errorLogPattern.
+func (quiet quietWriter) Write(entry []byte) (int, error) {
+	if !errorLogPattern.Match(entry) {
+		return len(entry), nil
+	}
+	return quiet.writer.Write(entry)
+}
+
+// consoleLog returns where the log goes on the terminal: stderr, filtered
+// down to errors with --quiet.
+func consoleLog() io.Writer {
+	if quietOutput {
+		return quietWriter{writer: os.Stderr}
+	}
+	return os.Stderr
+}
+
+// setLogVerbosity applies --quiet and --verbose to the log.
+func setLogVerbosity() {
+	if quietOutput && verboseOutput {
+		fatalConfig("-quiet and -verbose cannot be combined")
+	}
+	log.SetOutput(consoleLog())
+}
+
+// traceTransport logs every request the client sends with --verbose,
+// redirect hops included since the client sends each one separately.
+type traceTransport struct {
+	next http.RoundTripper // Transport doing the work
+}
+
+// RoundTrip sends the request and logs its outcome and timing.
+func (trace traceTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	started := time.Now()
+	response, err := trace.next.RoundTrip(request)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("trace: %s %s failed after %s: %v", request.Method, sanitizeURL(request.URL.String()), elapsed, err)
+		return nil, err
+	}
+	detail := fmt.Sprintf("%s %s, %d bytes", response.Proto, response.Header.Get("Content-Type"), response.ContentLength)
+	if location := response.Header.Get("Location"); location != "" {
+		detail += " → " + sanitizeURL(location)
+	}
+	log.Printf("trace: %s %s → %s in %s (%s)", request.Method, sanitizeURL(request.URL.String()), response.Status, elapsed, detail)
+	return response, nil
+}
+
+// traceRedirect logs the chain of a redirect before following it, with the
+// client's default limit of ten hops.
+func traceRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	chain := ""
+	for _, previous := range via {
+		chain += sanitizeURL(previous.URL.String()) + " → "
+	}
+	log.Printf("trace: redirect %d: %s%s", len(via), chain, sanitizeURL(request.URL.String()))
+	return nil
+}
+
+// printQuietSummary ends a --quiet run with one machine-parsable line.
+func printQuietSummary(report runReport) {
+	if !quietOutput || jsonOutput() { // The JSON report already says it all
+		return
+	}
+	status := "ok"
+	if len(report.Failures) > 0 {
+		status = "failures"
+	}
+	fmt.Printf("status=%s downloaded=%d new=%d updated=%d skipped=%d failed=%d queries=%d deferred=%d bytes=%d duration=%s\n",
+		status, report.Downloaded, len(report.NewDocuments), len(report.UpdatedDocuments), report.Skipped, len(report.Failures),
+		report.QueriesSearched, report.DeferredRequests, report.BytesDownloaded, report.Duration.Round(time.Second))
+}
@@ -0,0 +1,86 @@
+package main // Define the main package
+
+import (
+	"bytes"       // For checking the PDF header
+	"flag"        // For command-line flag parsing
+	"fmt"         // For describing problems
+	"io"          // For reading the file header
+	"log"         // For logging messages and errors
+	"os"          // For file access and the exit code
+	"runtime"     // For the default worker count
+	"sort"        // For a stable problem order
+	"sync"        // For collecting problems across workers
+	"sync/atomic" // For the progress counter
+)
+
+var verifyWorkers int // Number of documents hashed at once
+
+func init() {
+	flag.IntVar(&verifyWorkers, "verify-workers", runtime.NumCPU(), "number of PDFs to hash concurrently when verifying") // Register the verify worker flag
+}
+
+// verifyEntry checks one manifest entry against the file on disk and
+// returns a description of the problem, or "" when the file is intact.
+func verifyEntry(entry manifestEntry) string {
+	file, err := os.Open(entry.localPath()) // Stored copy
+	if err != nil {
+		return fmt.Sprintf("missing: %v", err)
+	}
+	header := make([]byte, 5) // Every PDF starts with %PDF-
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !bytes.Equal(header, []byte("%PDF-")) {
+		return "not a PDF (bad header)"
+	}
+	hash, err := hashFile(entry.localPath()) // Content hash
+	if err != nil {
+		return fmt.Sprintf("unreadable: %v", err)
+	}
+	if hash != entry.SHA256 {
+		return fmt.Sprintf("hash mismatch: manifest %s, file %s", entry.SHA256, hash)
+	}
+	return ""
+}
+
+// verifyProblem is one document that failed verification.
+type verifyProblem struct {
+	File    string `json:"file"`    // Filename inside the PDF folder
+	URL     string `json:"url"`     // Source URL of the document
+	Problem string `json:"problem"` // What is wrong with it
+}
+
+// verifyEntries checks the entries with --verify-workers at a time, logging
+// and returning the problems ordered by file.
+func verifyEntries(entries []manifestEntry) []verifyProblem {
+	problems := []verifyProblem{} // Entries that failed verification
+	var problemsMutex sync.Mutex  // Guards problems across workers
+	var processed atomic.Int64    // Progress counter
+	runWorkerPool(entries, verifyWorkers, func(entry manifestEntry) {
+		defer reportProgress("verifying", processed.Add(1), len(entries))
+		if problem := verifyEntry(entry); problem != "" {
+			log.Printf("%s: %s", entry.File, problem)
+			problemsMutex.Lock()
+			problems = append(problems, verifyProblem{File: entry.File, URL: entry.URL, Problem: problem})
+			problemsMutex.Unlock()
+		}
+	})
+	sort.Slice(problems, func(i, j int) bool { return problems[i].File < problems[j].File })
+	log.Printf("verified %d documents, %d problems", len(entries), len(problems))
+	return problems
+}
+
+// runVerifyCommand checks every document in the manifest and exits with
+// status 1 if any of them is missing or damaged.
+func runVerifyCommand() {
+	entries := mustLoadManifest().currentEntries() // Everything that should be on disk
+	problems := verifyEntries(entries)             // Entries that failed verification
+	if verifyKey != "" {                           // The manifest and SHA256SUMS must be signed as well
+		problems = append(problems, verifyLibrarySignatures()...)
+	}
+	if jsonOutput() {
+		printJSON(map[string]any{"verified": len(entries), "problems": problems})
+	}
+	if len(problems) > 0 {
+		exit(1) // Let scripts notice damaged archives
+	}
+}
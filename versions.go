@@ -0,0 +1,126 @@
+package main // Define the main package
+
+import (
+	"flag"           // For command-line flag parsing
+	"fmt"            // For printing the version table
+	"log"            // For logging failed restores
+	"os"             // For linking and removing kept copies
+	"path"           // For slash-separated version paths
+	"path/filepath"  // For building local paths
+	"strings"        // For trimming the extension
+	"text/tabwriter" // For aligning the version table
+	"time"           // For version timestamps
+)
+
+var (
+	keepVersions bool   // Keep superseded revisions instead of overwriting them
+	versionsDir  string // Where superseded revisions are kept
+)
+
+func init() {
+	flag.BoolVar(&keepVersions, "keep-versions", false, "keep the previous copy of a document the server revised under -versions-dir/<name>/<timestamp>.pdf") // Register the versioning flag
+	flag.StringVar(&versionsDir, "versions-dir", "versions", "folder that -keep-versions keeps superseded revisions in")                                      // Register the versions folder flag
+}
+
+// documentVersion is a superseded revision kept by --keep-versions.
+type documentVersion struct {
+	File         string    `json:"file"`                    // Path inside the versions folder, with forward slashes
+	SHA256       string    `json:"sha256"`                  // Hash of the kept PDF
+	Size         int64     `json:"size"`                    // Size of the kept PDF
+	RevisionDate string    `json:"revision_date,omitempty"` // Revision date the superseded copy was recorded with
+	DownloadedAt time.Time `json:"downloaded_at"`           // When the superseded copy was stored
+	SupersededAt time.Time `json:"superseded_at"`           // When a new revision replaced it
+}
+
+// Local path of a kept revision
+func (version documentVersion) localPath() string {
+	return filepath.Join(versionsDir, filepath.FromSlash(version.File))
+}
+
+// archiveVersion keeps the stored copy of a document before a new revision
+// is renamed over it, named after when that copy was downloaded. It returns
+// nil when versions are not kept or the content did not change.
+func archiveVersion(stored manifestEntry, newSHA256 string) (*documentVersion, error) {
+	if !keepVersions || stored.SHA256 == newSHA256 {
+		return nil, nil
+	}
+	name := strings.TrimSuffix(stored.File, path.Ext(stored.File)) // One folder per document
+	version := &documentVersion{
+		File:         path.Join(name, stored.DownloadedAt.UTC().Format("20060102T150405Z")+".pdf"),
+		SHA256:       stored.SHA256,
+		Size:         stored.Size,
+		RevisionDate: stored.RevisionDate,
+		DownloadedAt: stored.DownloadedAt,
+		SupersededAt: time.Now().UTC(),
+	}
+	if fileExists(version.localPath()) { // Kept by a run that was cut short
+		return version, nil
+	}
+	if err := keepCopy(stored.localPath(), version.localPath()); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// restoreVersion puts a kept revision back when the revision that replaced
+// it is discarded after all.
+func restoreVersion(version *documentVersion, filePath string) {
+	if version == nil {
+		return
+	}
+	os.Remove(filePath)
+	if err := keepCopy(version.localPath(), filePath); err != nil {
+		log.Printf("failed to restore %s from %s: %v", filePath, version.localPath(), err)
+		return
+	}
+	os.Remove(version.localPath())
+}
+
+// keepCopy copies source to target as a hard link, which costs no space and
+// survives the source being renamed over, or as a full copy across file
+// systems.
+func keepCopy(source, target string) error {
+	createDirectory(filepath.Dir(target), 0755)
+	if os.Link(source, target) == nil {
+		return nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = writeFileAtomically(target, file, info.Size())
+	return err
+}
+
+// List the kept revisions, of all documents or of the named ones
+func runVersionsCommand(args []string) {
+	wanted := make(map[string]bool) // Filenames or URLs to list, all when empty
+	for _, arg := range args {
+		wanted[arg] = true
+	}
+	var entries []manifestEntry // Documents with kept revisions
+	for _, entry := range mustLoadManifest().sortedEntries() {
+		if len(entry.Versions) > 0 && (len(wanted) == 0 || wanted[entry.File] || wanted[entry.URL]) {
+			entries = append(entries, entry)
+		}
+	}
+	if jsonOutput() {
+		printJSON(entries)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0) // Align the columns
+	fmt.Fprintln(writer, "FILE\tREVISION\tDOWNLOADED\tSUPERSEDED\tKEPT AS")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t\tcurrent\n", entry.File, entry.RevisionDate, entry.DownloadedAt.Format(time.DateOnly))
+		for index := len(entry.Versions) - 1; index >= 0; index-- { // Newest first
+			version := entry.Versions[index]
+			fmt.Fprintf(writer, "\t%s\t%s\t%s\t%s\n", version.RevisionDate, version.DownloadedAt.Format(time.DateOnly), version.SupersededAt.Format(time.DateOnly), version.localPath())
+		}
+	}
+	writer.Flush() // Print the table
+}
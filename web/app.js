@@ -0,0 +1,167 @@
+// SDS library UI: loads the catalog, filters it as you type, looks the words
+// up in the text of the documents and remembers recently viewed documents
+// so the service worker can keep them offline.
+// Scanned barcodes are resolved through /barcode/{code}. In kiosk mode
+// (serve -kiosk) documents open in an in-page viewer, nothing is
+// remembered and the page resets itself after a period of inactivity.
+const recentKey = "recent-documents";
+const recentLimit = 10;
+let documents = [];
+let config = { kiosk: false, idle_seconds: 0 };
+let idleTimer;
+let searchTimer;
+
+function label(doc) {
+  return doc.title || doc.file;
+}
+
+function item(doc) {
+  const li = document.createElement("li");
+  const a = document.createElement("a");
+  a.href = "/pdf/" + encodeURIComponent(doc.file);
+  a.textContent = label(doc);
+  const details = document.createElement("small");
+  details.textContent = [doc.file, doc.revision_date, doc.language, doc.signal_word, (doc.hazard_codes || []).join(" ")].filter(Boolean).join(" · ");
+  a.appendChild(details);
+  a.addEventListener("click", (event) => {
+    event.preventDefault();
+    openDocument(doc);
+  });
+  li.appendChild(a);
+  return li;
+}
+
+function render() {
+  const query = document.getElementById("search").value.trim().toLowerCase();
+  const matches = documents.filter((doc) => !query || label(doc).toLowerCase().includes(query) || doc.file.includes(query) || (doc.hazard_codes || []).some((code) => code.toLowerCase() === query));
+  const list = document.getElementById("documents");
+  list.replaceChildren(...matches.slice(0, 200).map(item));
+  document.getElementById("status").textContent = matches.length + " of " + documents.length + " documents" + (navigator.onLine ? "" : " (offline)");
+  renderRecent(query);
+  clearTimeout(searchTimer);
+  document.getElementById("text-results").hidden = true;
+  if (query.length >= 3 && navigator.onLine) {
+    searchTimer = setTimeout(() => searchText(query, matches), 300);
+  }
+}
+
+// Documents that mention the words in their text, beyond the name matches.
+async function searchText(query, matches) {
+  try {
+    const hits = await (await fetch("/search.json?q=" + encodeURIComponent(query))).json();
+    if (document.getElementById("search").value.trim().toLowerCase() !== query) {
+      return; // The user kept typing
+    }
+    const shown = new Set(matches.map((doc) => doc.file));
+    const extra = hits.filter((doc) => !shown.has(doc.file));
+    document.getElementById("text-list").replaceChildren(...extra.slice(0, 200).map(item));
+    document.getElementById("text-results").hidden = extra.length === 0;
+  } catch {
+    // Text search needs the server; the name matches are still shown.
+  }
+}
+
+function openDocument(doc) {
+  const url = "/pdf/" + encodeURIComponent(doc.file);
+  if (!config.kiosk) {
+    remember(doc);
+    location.href = url;
+    return;
+  }
+  document.getElementById("frame").src = url;
+  document.getElementById("viewer").hidden = false;
+}
+
+function closeViewer() {
+  document.getElementById("viewer").hidden = true;
+  document.getElementById("frame").removeAttribute("src");
+}
+
+// Back to a blank search, so the next person starts from scratch.
+function reset() {
+  closeViewer();
+  document.getElementById("search").value = "";
+  render();
+  document.getElementById("search").focus();
+}
+
+function activity() {
+  clearTimeout(idleTimer);
+  idleTimer = setTimeout(reset, config.idle_seconds * 1000);
+}
+
+function recent() {
+  try {
+    return JSON.parse(localStorage.getItem(recentKey)) || [];
+  } catch {
+    return [];
+  }
+}
+
+function remember(doc) {
+  const list = [doc, ...recent().filter((other) => other.file !== doc.file)].slice(0, recentLimit);
+  localStorage.setItem(recentKey, JSON.stringify(list));
+}
+
+function renderRecent(query) {
+  const list = config.kiosk ? [] : recent();
+  document.getElementById("recent").hidden = query !== "" || list.length === 0;
+  document.getElementById("recent-list").replaceChildren(...list.map(item));
+}
+
+async function load() {
+  try {
+    config = await (await fetch("/ui-config.json")).json();
+  } catch {
+    // Offline without a cached config: keep the defaults.
+  }
+  if (config.kiosk) {
+    document.body.classList.add("kiosk");
+    document.addEventListener("contextmenu", (event) => event.preventDefault());
+    for (const type of ["pointerdown", "keydown", "touchstart", "wheel"]) {
+      document.addEventListener(type, activity, { passive: true });
+    }
+    activity();
+  }
+  try {
+    const response = await fetch("/catalog.json");
+    documents = await response.json();
+  } catch {
+    documents = [];
+  }
+  render();
+}
+
+// Handheld scanners type the code followed by Enter into the focused field.
+async function scan(code) {
+  const status = document.getElementById("status");
+  try {
+    const response = await fetch("/barcode/" + encodeURIComponent(code));
+    const match = await response.json();
+    if (match.documents.length === 1) {
+      openDocument(match.documents[0]);
+    } else if (match.documents.length > 1) {
+      document.getElementById("search").value = match.product;
+      render();
+    } else {
+      status.textContent = match.product ? match.product + " has no SDS yet; the request was recorded." : "Unknown barcode " + code + ".";
+    }
+  } catch {
+    status.textContent = "Barcode lookup needs a connection.";
+  }
+}
+
+document.getElementById("search").addEventListener("input", render);
+document.getElementById("search").addEventListener("keydown", (event) => {
+  const code = event.target.value.trim();
+  if (event.key === "Enter" && /^[0-9 -]{6,}$/.test(code)) {
+    scan(code);
+  }
+});
+document.getElementById("close").addEventListener("click", closeViewer);
+window.addEventListener("online", render);
+window.addEventListener("offline", render);
+if ("serviceWorker" in navigator) {
+  navigator.serviceWorker.register("/sw.js");
+}
+load();
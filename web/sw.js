@@ -0,0 +1,56 @@
+// Service worker: the app shell is cached on install, the catalog is
+// fetched network-first with a cached fallback, and viewed PDFs are kept
+// in a small cache so recently used SDS open without Wi-Fi.
+const shellCache = "shell-v4";
+const documentCache = "documents-v1";
+const documentLimit = 20;
+const shell = ["/", "/app.js", "/app.css", "/icon.svg", "/manifest.webmanifest"];
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(caches.open(shellCache).then((cache) => cache.addAll(shell)));
+  self.skipWaiting();
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys().then((keys) => Promise.all(keys.filter((key) => key !== shellCache && key !== documentCache).map((key) => caches.delete(key))))
+  );
+  self.clients.claim();
+});
+
+async function networkFirst(request, cacheName) {
+  const cache = await caches.open(cacheName);
+  try {
+    const response = await fetch(request);
+    if (response.ok) {
+      await cache.put(request, response.clone());
+    }
+    return response;
+  } catch (error) {
+    const cached = await cache.match(request);
+    if (cached) {
+      return cached;
+    }
+    throw error;
+  }
+}
+
+async function trimDocuments() {
+  const cache = await caches.open(documentCache);
+  const keys = await cache.keys();
+  await Promise.all(keys.slice(0, Math.max(0, keys.length - documentLimit)).map((key) => cache.delete(key)));
+}
+
+self.addEventListener("fetch", (event) => {
+  const url = new URL(event.request.url);
+  if (event.request.method !== "GET" || url.origin !== self.location.origin) {
+    return;
+  }
+  if (url.pathname.startsWith("/pdf/")) {
+    event.respondWith(networkFirst(event.request, documentCache).finally(trimDocuments));
+  } else if (url.pathname === "/catalog.json" || url.pathname === "/ui-config.json") {
+    event.respondWith(networkFirst(event.request, shellCache));
+  } else {
+    event.respondWith(caches.match(event.request).then((cached) => cached || fetch(event.request)));
+  }
+});
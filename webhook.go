@@ -0,0 +1,117 @@
+package main // Define the main package
+
+import (
+	"bytes"         // For request bodies
+	"encoding/json" // For webhook payloads
+	"errors"        // For missing configuration
+	"flag"          // For command-line flag parsing
+	"fmt"           // For formatting Slack messages
+	"net/http"      // For building requests
+	"strings"       // For building Slack messages
+)
+
+// slackListLimit caps how many documents a Slack message lists per section.
+const slackListLimit = 20
+
+var (
+	webhookURL      string // Generic endpoint that receives the JSON run report
+	slackWebhookURL string // Slack incoming webhook
+)
+
+func init() {
+	flag.StringVar(&webhookURL, "webhook-url", "", "URL the webhook notification channel POSTs the JSON run report to")        // Register the webhook flag
+	flag.StringVar(&slackWebhookURL, "slack-webhook-url", "", "Slack incoming webhook used by the slack notification channel") // Register the Slack flag
+}
+
+// webhookNotifier POSTs the message and the full run report as JSON.
+type webhookNotifier struct {
+	url string // Endpoint to post to
+}
+
+// Build the webhook channel from its flag
+func newWebhookNotifier() (Notifier, error) {
+	if webhookURL == "" {
+		return nil, errors.New("-webhook-url is not set")
+	}
+	return webhookNotifier{url: webhookURL}, nil
+}
+
+// Name returns the channel name.
+func (webhookNotifier) Name() string { return "webhook" }
+
+// Notify posts {"text": message, "report": report}.
+func (notifier webhookNotifier) Notify(report runReport, message string) error {
+	return postJSON(notifier.url, map[string]any{"text": message, "report": report})
+}
+
+// slackNotifier posts the message with the new and updated documents to a
+// Slack incoming webhook.
+type slackNotifier struct {
+	url string // Incoming webhook URL
+}
+
+// Build the Slack channel from its flag
+func newSlackNotifier() (Notifier, error) {
+	if slackWebhookURL == "" {
+		return nil, errors.New("-slack-webhook-url is not set")
+	}
+	return slackNotifier{url: slackWebhookURL}, nil
+}
+
+// Name returns the channel name.
+func (slackNotifier) Name() string { return "slack" }
+
+// Notify posts the message followed by short document lists.
+func (notifier slackNotifier) Notify(report runReport, message string) error {
+	var text strings.Builder // Slack mrkdwn message
+	text.WriteString(message)
+	writeSlackSection(&text, "New documents", report.NewDocuments)
+	writeSlackSection(&text, "Updated documents", report.UpdatedDocuments)
+	if report.Drift != nil { // Change control wants to see exactly what drifted
+		writeSlackSection(&text, "Not approved", report.Drift.Added)
+		writeSlackSection(&text, "Approved but removed", report.Drift.Removed)
+		writeSlackSection(&text, "Changed since approval", report.Drift.Changed)
+	}
+	return postJSON(notifier.url, map[string]string{"text": text.String()})
+}
+
+// Append a bulleted list of documents, truncated to slackListLimit
+func writeSlackSection(text *strings.Builder, heading string, changes []documentChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(text, "\n*%s (%d)*", heading, len(changes))
+	for index, change := range changes {
+		if index == slackListLimit {
+			fmt.Fprintf(text, "\n• …and %d more", len(changes)-slackListLimit)
+			break
+		}
+		name := change.Title // Prefer the product name
+		if name == "" {
+			name = change.File
+		}
+		fmt.Fprintf(text, "\n• <%s|%s>", change.URL, name)
+	}
+}
+
+// postJSON posts a JSON payload and fails on any non-2xx answer.
+func postJSON(url string, payload any) error {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := currentFetcher().Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
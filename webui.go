@@ -0,0 +1,90 @@
+package main // Define the main package
+
+import (
+	"embed"         // For bundling the web UI into the binary
+	"encoding/json" // For the catalog index
+	"io/fs"         // For serving the embedded folder
+	"log"           // For logging search errors
+	"mime"          // For the web app manifest content type
+	"net/http"      // For the UI handlers
+)
+
+// webFiles holds the serve-mode UI: a catalog page, its script and styles,
+// the web app manifest and the service worker that keeps the catalog and
+// recently viewed documents available offline.
+//
+//go:embed web
+var webFiles embed.FS
+
+func init() {
+	mime.AddExtensionType(".webmanifest", "application/manifest+json") // Not in every system MIME table
+}
+
+// catalogItem is one document as listed by GET /catalog.json.
+type catalogItem struct {
+	Title        string   `json:"title,omitempty"`         // Product name
+	File         string   `json:"file"`                    // Filename, served under /pdf/
+	Size         int64    `json:"size"`                    // Size of the PDF
+	RevisionDate string   `json:"revision_date,omitempty"` // Last-Modified date reported by the server
+	Language     string   `json:"language,omitempty"`      // Language code, when known
+	DocType      string   `json:"doc_type,omitempty"`      // Document class, empty for SDS
+	SignalWord   string   `json:"signal_word,omitempty"`   // "Danger" or "Warning" from Section 2
+	HazardCodes  []string `json:"hazard_codes,omitempty"`  // GHS hazard statements from Section 2
+}
+
+// newCatalogItem lists a manifest entry in the catalog.
+func newCatalogItem(entry manifestEntry) catalogItem {
+	return catalogItem{Title: entry.Title, File: entry.File, Size: entry.Size, RevisionDate: entry.RevisionDate, Language: entry.Language, DocType: entry.DocType, SignalWord: entry.SignalWord, HazardCodes: entry.HazardCodes}
+}
+
+// registerWebUI adds the catalog page, its assets and the catalog index.
+func (server *libraryServer) registerWebUI(mux *http.ServeMux) {
+	root, err := fs.Sub(webFiles, "web") // Serve the folder's contents at /
+	if err != nil {
+		panic(err) // The folder is embedded at build time
+	}
+	mux.Handle("GET /", http.FileServerFS(root))
+	mux.HandleFunc("GET /catalog.json", server.serveCatalog)
+	mux.HandleFunc("GET /search.json", server.serveTextSearch)
+	mux.HandleFunc("GET /ui-config.json", serveUIConfig)
+}
+
+// serveUIConfig tells the page whether it runs as a kiosk.
+func serveUIConfig(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(writer).Encode(map[string]any{"kiosk": kioskMode, "idle_seconds": int(kioskIdle.Seconds())})
+}
+
+// serveCatalog lists the documents in the library for the UI. The service
+// worker keeps the last answer so the list still works without a network.
+func (server *libraryServer) serveCatalog(writer http.ResponseWriter, request *http.Request) {
+	items := []catalogItem{} // Encode an empty library as [] rather than null
+	for _, entry := range publishedEntries(documentManifest.currentEntries()) {
+		items = append(items, newCatalogItem(entry))
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-cache") // Always revalidate, the worker handles offline
+	json.NewEncoder(writer).Encode(items)
+}
+
+// serveTextSearch lists the published documents whose text contains the
+// words of ?q=, best matches first, using the index built by index.
+func (server *libraryServer) serveTextSearch(writer http.ResponseWriter, request *http.Request) {
+	items := []catalogItem{} // Nothing indexed yet is no match rather than an error
+	hits, err := searchLibrary(request.URL.Query().Get("q"))
+	if err != nil {
+		log.Printf("text search: %v", err)
+	}
+	published := make(map[string]manifestEntry) // Filename → entry visible in the catalog
+	for _, entry := range publishedEntries(documentManifest.currentEntries()) {
+		published[entry.File] = entry
+	}
+	for _, hit := range hits {
+		if entry, visible := published[hit.File]; visible {
+			items = append(items, newCatalogItem(entry))
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(items)
+}
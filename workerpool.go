@@ -0,0 +1,38 @@
+package main // Define the main package
+
+import (
+	"log"  // For progress reports
+	"sync" // For waiting on worker goroutines
+)
+
+// runWorkerPool calls work once for every item using at most workers
+// goroutines at a time, and returns once every item has been processed.
+func runWorkerPool[T any](items []T, workers int, work func(item T)) {
+	if workers < 1 { // Guard against zero or negative limits
+		workers = 1 // Fall back to sequential processing
+	}
+	jobs := make(chan T)         // Channel feeding items to the workers
+	var waitGroup sync.WaitGroup // Tracks running workers
+	for i := 0; i < workers; i++ {
+		waitGroup.Add(1) // Register the worker
+		go func() {
+			defer waitGroup.Done()   // Mark the worker finished on exit
+			for item := range jobs { // Take items until the channel closes
+				work(item) // Process the item
+			}
+		}()
+	}
+	for _, item := range items {
+		jobs <- item // Hand the item to the next free worker
+	}
+	close(jobs)      // Signal the workers that no more items are coming
+	waitGroup.Wait() // Wait for in-flight items to finish
+}
+
+// reportProgress logs every hundredth and the last item of a long pass
+// over the library, e.g. "indexing: 300/41250 documents".
+func reportProgress(pass string, done int64, total int) {
+	if done%100 == 0 || done == int64(total) {
+		log.Printf("%s: %d/%d documents", pass, done, total)
+	}
+}